@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codemap/internal/export"
+	"codemap/internal/graph"
+)
+
+// runExport implements "codemap export": it writes the indexed graph, or part
+// of it, to a file or stdout. It reads the existing database and does not
+// index; run codemap on the project first.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	projectDir := fs.String("project-dir", "", "Project directory whose graph to export (default: current working directory)")
+	format := fs.String("format", export.FormatDOT, "Output format: "+strings.Join(export.Formats, ", "))
+	symbol := fs.String("symbol", "", "Export the neighbourhood of this symbol (bare or qualified name)")
+	dir := fs.String("dir", "", "Export the symbols under this directory")
+	depth := fs.Int("depth", 0, "Hops to expand around the symbol or directory (default 1 for -symbol, 0 for -dir)")
+	relations := fs.String("relations", "", "Comma-separated relations to include (default: all)")
+	cluster := fs.String("cluster", "", "Group nodes by file or package")
+	output := fs.String("o", "", "Write to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: codemap export [flags]\n\nExports the code graph, or a subgraph around a symbol or directory.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	opts := export.Options{Format: *format, Cluster: *cluster}
+	if err := opts.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	var rels []string
+	if *relations != "" {
+		rels = strings.Split(*relations, ",")
+	}
+	if err := graph.ValidateRelations(rels); err != nil {
+		log.Fatal(err)
+	}
+	if *dir != "" {
+		abs, err := filepath.Abs(*dir)
+		if err != nil {
+			log.Fatalf("Failed to resolve directory: %v", err)
+		}
+		*dir = abs
+	}
+
+	enterProjectDir(*projectDir)
+	database := openDatabase()
+	defer database.Close()
+	store := graph.NewStore(database)
+
+	g, err := store.Subgraph(context.Background(), graph.SubgraphOptions{
+		Symbol:    *symbol,
+		Dir:       *dir,
+		Depth:     *depth,
+		Relations: rels,
+	})
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	if len(g.Nodes) == 0 {
+		log.Println("Warning: no symbols selected; has the project been indexed?")
+	}
+
+	if *output == "" {
+		if err := export.Write(os.Stdout, g, opts); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		return
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", *output, err)
+	}
+	// log.Fatalf skips deferred calls, and a failed Close may mean the file
+	// was not written in full, so the file is closed explicitly.
+	err = export.Write(f, g, opts)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("Export to %s failed: %v", *output, err)
+	}
+}
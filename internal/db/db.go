@@ -29,8 +29,10 @@ func New(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	// Enable WAL mode for performance
-	dsn := fmt.Sprintf("file:%s?cache=shared&mode=rwc&_journal_mode=WAL", dbPath)
+	// Enable WAL mode for performance. Foreign keys are off by default in
+	// SQLite; the DSN turns them on for every pooled connection so that
+	// ON DELETE CASCADE actually removes edges.
+	dsn := fmt.Sprintf("file:%s?cache=shared&mode=rwc&_journal_mode=WAL&_foreign_keys=on", dbPath)
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -49,45 +51,6 @@ func New(dbPath string) (*DB, error) {
 	return sdb, nil
 }
 
-func (db *DB) migrate() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS nodes (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		kind TEXT NOT NULL,
-		file_path TEXT NOT NULL,
-		line_start INTEGER NOT NULL,
-		line_end INTEGER NOT NULL,
-		col_start INTEGER NOT NULL,
-		col_end INTEGER NOT NULL,
-		symbol_uri TEXT,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_nodes_file_path ON nodes(file_path);
-	CREATE INDEX IF NOT EXISTS idx_nodes_name ON nodes(name);
-
-	CREATE TABLE IF NOT EXISTS edges (
-		source_id TEXT NOT NULL,
-		target_id TEXT NOT NULL,
-		relation TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		PRIMARY KEY (source_id, target_id, relation),
-		FOREIGN KEY (source_id) REFERENCES nodes(id) ON DELETE CASCADE,
-		FOREIGN KEY (target_id) REFERENCES nodes(id) ON DELETE CASCADE
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_edges_source ON edges(source_id);
-	CREATE INDEX IF NOT EXISTS idx_edges_target ON edges(target_id);
-	`
-
-	_, err := db.Exec(schema)
-	if err != nil {
-		return fmt.Errorf("schema execution failed: %w", err)
-	}
-	return nil
-}
-
 func (db *DB) Close() error {
 	return db.DB.Close()
 }
@@ -0,0 +1,446 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"codemap/util"
+)
+
+// ErrSearchUnavailable is returned when the database's symbol search index
+// uses an SQLite full-text module that is not compiled into this binary.
+var ErrSearchUnavailable = errors.New("database search index needs an SQLite module this binary lacks")
+
+// ErrSchemaTooNew is returned when the database was written by a newer binary
+// whose schema this binary does not know how to read.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// schemaVersionTable records one row per applied migration.
+const schemaVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`
+
+// migration is a single, ordered schema change. Versions must start at 1 and
+// increase by one; each migration runs in its own transaction together with
+// the schema_version bookkeeping.
+type migration struct {
+	version     int
+	description string
+	up          func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations is the ordered list of schema changes. Never edit or reorder a
+// released migration; append a new one instead.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create nodes and edges",
+		up: execStatements(`
+		CREATE TABLE IF NOT EXISTS nodes (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			file_path TEXT NOT NULL,
+			line_start INTEGER NOT NULL,
+			line_end INTEGER NOT NULL,
+			col_start INTEGER NOT NULL,
+			col_end INTEGER NOT NULL,
+			symbol_uri TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_nodes_file_path ON nodes(file_path);
+		CREATE INDEX IF NOT EXISTS idx_nodes_name ON nodes(name);
+
+		CREATE TABLE IF NOT EXISTS edges (
+			source_id TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			relation TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (source_id, target_id, relation),
+			FOREIGN KEY (source_id) REFERENCES nodes(id) ON DELETE CASCADE,
+			FOREIGN KEY (target_id) REFERENCES nodes(id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_edges_source ON edges(source_id);
+		CREATE INDEX IF NOT EXISTS idx_edges_target ON edges(target_id);
+		`),
+	},
+	{
+		// Node IDs are now derived from qualified names, so rows written by
+		// older binaries can no longer be matched and are discarded.
+		version:     2,
+		description: "add nodes.qualified_name",
+		up: execStatements(`
+		DELETE FROM edges;
+		DELETE FROM nodes;
+		ALTER TABLE nodes ADD COLUMN qualified_name TEXT NOT NULL DEFAULT '';
+		CREATE INDEX IF NOT EXISTS idx_nodes_qualified_name ON nodes(qualified_name);
+		`),
+	},
+	{
+		// Foreign keys were never enabled before, so cascades did not fire and
+		// deleted nodes may have left dangling edges behind.
+		version:     3,
+		description: "remove orphaned edges",
+		up: execStatements(`
+		DELETE FROM edges
+		WHERE source_id NOT IN (SELECT id FROM nodes)
+		   OR target_id NOT IN (SELECT id FROM nodes);
+		`),
+	},
+	{
+		version:     4,
+		description: "add symbol search index",
+		up:          addSymbolSearch,
+	},
+	{
+		version:     5,
+		description: "add call sites and edges.line",
+		up: execStatements(`
+		ALTER TABLE edges ADD COLUMN line INTEGER NOT NULL DEFAULT 0;
+
+		CREATE TABLE IF NOT EXISTS call_sites (
+			file_path TEXT NOT NULL,
+			line INTEGER NOT NULL,
+			col INTEGER NOT NULL,
+			caller_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			qualifier TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (file_path, line, col),
+			FOREIGN KEY (caller_id) REFERENCES nodes(id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_call_sites_caller ON call_sites(caller_id);
+		`),
+	},
+	{
+		version:     6,
+		description: "add edge provenance and confidence",
+		up: execStatements(`
+		ALTER TABLE edges ADD COLUMN provenance TEXT NOT NULL DEFAULT 'lsp';
+		ALTER TABLE edges ADD COLUMN confidence REAL NOT NULL DEFAULT 1;
+		`),
+	},
+	{
+		// Existing rows get the symbol kind their node kind usually denotes;
+		// the next scan refines it (e.g. Python methods).
+		version:     7,
+		description: "add nodes.symbol_kind",
+		up: execStatements(`
+		ALTER TABLE nodes ADD COLUMN symbol_kind TEXT NOT NULL DEFAULT '';
+		UPDATE nodes SET symbol_kind = CASE
+			WHEN kind IN ('function_declaration', 'function_definition', 'generator_function_declaration') THEN 'function'
+			WHEN kind IN ('method_declaration', 'method_definition') THEN 'method'
+			WHEN kind IN ('class_declaration', 'class_definition', 'abstract_class_declaration', 'class',
+				'type_declaration', 'type_alias_declaration') THEN 'class'
+			WHEN kind = 'interface_declaration' THEN 'interface'
+			WHEN kind = 'struct_declaration' THEN 'struct'
+			WHEN kind = 'enum_declaration' THEN 'enum'
+			WHEN kind IN ('variable_declarator', 'variable_declaration', 'assignment_statement') THEN 'variable'
+			WHEN kind IN ('field_definition', 'public_field_definition', 'field_declaration') THEN 'field'
+			WHEN kind = 'file' THEN 'module'
+			ELSE ''
+		END;
+		CREATE INDEX IF NOT EXISTS idx_nodes_symbol_kind ON nodes(symbol_kind);
+		`),
+	},
+	{
+		// Existing definitions are attached to their file; the next scan
+		// nests members under their class or struct.
+		version:     8,
+		description: "add nodes.parent_id",
+		up: execStatements(`
+		ALTER TABLE nodes ADD COLUMN parent_id TEXT NOT NULL DEFAULT '';
+		UPDATE nodes SET parent_id = COALESCE(
+			(SELECT f.id FROM nodes f WHERE f.kind = 'file' AND f.file_path = nodes.file_path), '')
+		WHERE kind != 'file';
+		CREATE INDEX IF NOT EXISTS idx_nodes_parent_id ON nodes(parent_id);
+		`),
+	},
+	{
+		// Filled in by the next scan.
+		version:     9,
+		description: "add nodes.signature and nodes.doc",
+		up: execStatements(`
+		ALTER TABLE nodes ADD COLUMN signature TEXT NOT NULL DEFAULT '';
+		ALTER TABLE nodes ADD COLUMN doc TEXT NOT NULL DEFAULT '';
+		`),
+	},
+	{
+		// Existing edges keep their first use, without a column or snippet,
+		// until the next scan records every occurrence.
+		version:     10,
+		description: "add edge_sites",
+		up: execStatements(`
+		CREATE TABLE IF NOT EXISTS edge_sites (
+			source_id TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			relation TEXT NOT NULL,
+			file_path TEXT NOT NULL,
+			line INTEGER NOT NULL,
+			col INTEGER NOT NULL,
+			snippet TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (source_id, target_id, relation, line, col),
+			FOREIGN KEY (source_id, target_id, relation) REFERENCES edges(source_id, target_id, relation) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_edge_sites_target ON edge_sites(target_id);
+
+		INSERT OR IGNORE INTO edge_sites (source_id, target_id, relation, file_path, line, col)
+		SELECT e.source_id, e.target_id, e.relation, n.file_path, e.line, 0
+		FROM edges e JOIN nodes n ON n.id = e.source_id
+		WHERE e.line > 0 AND e.relation != 'contains';
+		`),
+	},
+	{
+		// Empty until the next index, which then scans every file.
+		version:     11,
+		description: "add files",
+		up: execStatements(`
+		CREATE TABLE IF NOT EXISTS files (
+			path TEXT PRIMARY KEY,
+			language TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			mtime INTEGER NOT NULL,
+			hash TEXT NOT NULL,
+			indexed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		`),
+	},
+	{
+		// Go type specs were stored under kinds of their own, by underlying
+		// type; symbol_kind already tells them apart.
+		version:     12,
+		description: "store Go type specs as type_spec",
+		up: execStatements(`
+		UPDATE nodes SET kind = 'type_spec'
+		WHERE file_path LIKE '%.go'
+			AND kind IN ('interface_declaration', 'struct_declaration', 'type_declaration');
+		`),
+	},
+	{
+		// Empty until a scan stores the node again; until then an edit is
+		// taken to change it.
+		version:     13,
+		description: "add nodes.hash",
+		up: execStatements(`
+		ALTER TABLE nodes ADD COLUMN hash TEXT NOT NULL DEFAULT '';
+		`),
+	},
+	{
+		// Files indexed so far are taken to be enriched.
+		version:     14,
+		description: "add files.enriched",
+		up: execStatements(`
+		ALTER TABLE files ADD COLUMN enriched INTEGER NOT NULL DEFAULT 1;
+		`),
+	},
+}
+
+// LatestSchemaVersion returns the schema version this binary migrates to.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// execStatements returns a migration step that executes a fixed SQL script.
+func execStatements(script string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, script)
+		return err
+	}
+}
+
+// addSymbolSearch adds the nodes_fts full-text index over symbol names and
+// their split words (nodes.search_terms), kept in sync with nodes by
+// triggers. FTS5 is used when the binary is built with the sqlite_fts5 tag;
+// otherwise the built-in FTS4 module serves the same queries. nodes_fts_vocab
+// exposes the indexed terms for typo-tolerant lookups.
+func addSymbolSearch(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE nodes ADD COLUMN search_terms TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, name, qualified_name FROM nodes")
+	if err != nil {
+		return err
+	}
+	terms := make(map[string]string)
+	for rows.Next() {
+		var id, name, qualified string
+		if err := rows.Scan(&id, &name, &qualified); err != nil {
+			rows.Close()
+			return err
+		}
+		terms[id] = util.SearchTerms(name, qualified)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, t := range terms {
+		if _, err := tx.ExecContext(ctx, "UPDATE nodes SET search_terms = ? WHERE id = ?", t, id); err != nil {
+			return err
+		}
+	}
+
+	vocab := "CREATE VIRTUAL TABLE nodes_fts_vocab USING fts5vocab(nodes_fts, row)"
+	_, err = tx.ExecContext(ctx, "CREATE VIRTUAL TABLE nodes_fts USING fts5(name, terms)")
+	if err != nil && strings.Contains(err.Error(), "no such module") {
+		vocab = "CREATE VIRTUAL TABLE nodes_fts_vocab USING fts4aux(nodes_fts)"
+		_, err = tx.ExecContext(ctx, "CREATE VIRTUAL TABLE nodes_fts USING fts4(name, terms)")
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, vocab); err != nil {
+		return err
+	}
+
+	return execStatements(`
+	INSERT INTO nodes_fts (rowid, name, terms) SELECT rowid, name, search_terms FROM nodes;
+
+	CREATE TRIGGER nodes_fts_insert AFTER INSERT ON nodes BEGIN
+		INSERT INTO nodes_fts (rowid, name, terms) VALUES (new.rowid, new.name, new.search_terms);
+	END;
+	CREATE TRIGGER nodes_fts_delete AFTER DELETE ON nodes BEGIN
+		DELETE FROM nodes_fts WHERE rowid = old.rowid;
+	END;
+	CREATE TRIGGER nodes_fts_update AFTER UPDATE OF name, search_terms ON nodes BEGIN
+		DELETE FROM nodes_fts WHERE rowid = old.rowid;
+		INSERT INTO nodes_fts (rowid, name, terms) VALUES (new.rowid, new.name, new.search_terms);
+	END;
+	`)(ctx, tx)
+}
+
+// migrate brings the schema up to LatestSchemaVersion. A database written by
+// a newer binary is refused with ErrSchemaTooNew. If a pending migration
+// cannot be applied, the graph is treated as a disposable cache: every table
+// is dropped and the schema is rebuilt from scratch.
+func (db *DB) migrate() error {
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, schemaVersionTable); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return fmt.Errorf("%w: database is at version %d, this binary supports up to %d", ErrSchemaTooNew, current, latest)
+	}
+
+	if err := db.applyMigrations(ctx, current); err != nil {
+		log.Printf("Warning: schema migration failed (%v), rebuilding database from scratch", err)
+		if err := db.rebuild(ctx); err != nil {
+			return fmt.Errorf("failed to rebuild database: %w", err)
+		}
+	}
+
+	return db.checkSearchIndex(ctx)
+}
+
+// checkSearchIndex makes sure the search index can be read. A database
+// indexed by a binary built with FTS5 cannot be read (or even dropped) by one
+// without it.
+func (db *DB) checkSearchIndex(ctx context.Context) error {
+	var n int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'nodes_fts'").Scan(&n); err != nil {
+		return fmt.Errorf("failed to look up search index: %w", err)
+	}
+	if n == 0 {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, "SELECT 1 FROM nodes_fts WHERE rowid = 0"); err != nil {
+		return fmt.Errorf("%w: %v", ErrSearchUnavailable, err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 for an
+// empty database.
+func (db *DB) SchemaVersion(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// applyMigrations runs every migration newer than current, in order.
+func (db *DB) applyMigrations(ctx context.Context, current int) error {
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := db.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) applyMigration(ctx context.Context, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(ctx, tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_version (version, description) VALUES (?, ?)",
+		m.version, m.description,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebuild drops every table and applies all migrations to the empty database.
+func (db *DB) rebuild(ctx context.Context) error {
+	rows, err := db.QueryContext(ctx, `
+	SELECT name FROM sqlite_master
+	WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+	ORDER BY sql LIKE 'CREATE VIRTUAL TABLE%' DESC;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	// Virtual tables are dropped first so their shadow tables go with them.
+	for _, name := range tables {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %q", name)); err != nil {
+			return fmt.Errorf("failed to drop table %s: %w", name, err)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, schemaVersionTable); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	return db.applyMigrations(ctx, 0)
+}
@@ -0,0 +1,291 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate_FreshDatabase(t *testing.T) {
+	database, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+
+	version, err := database.SchemaVersion(context.Background())
+	if err != nil {
+		t.Fatalf("SchemaVersion failed: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("Expected schema version %d, got %d", LatestSchemaVersion(), version)
+	}
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	future := LatestSchemaVersion() + 1
+	if _, err := database.Exec("INSERT INTO schema_version (version, description) VALUES (?, 'from the future')", future); err != nil {
+		t.Fatalf("Failed to bump schema version: %v", err)
+	}
+	database.Close()
+
+	if _, err := New(dbPath); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestMigrate_RebuildsWhenMigrationFails(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	// Simulate a database that drifted from the recorded version: the column
+	// the next migration adds already exists, so ALTER TABLE fails.
+	if _, err := database.Exec("ALTER TABLE nodes ADD COLUMN extra TEXT"); err != nil {
+		t.Fatalf("Failed to alter nodes: %v", err)
+	}
+	if _, err := database.Exec("INSERT INTO nodes (id, name, kind, file_path, line_start, line_end, col_start, col_end) VALUES ('n1', 'stale', 'function_declaration', 'a.go', 1, 1, 1, 1)"); err != nil {
+		t.Fatalf("Failed to insert node: %v", err)
+	}
+	database.Close()
+
+	saved := migrations
+	defer func() { migrations = saved }()
+	migrations = append(append([]migration{}, saved...), migration{
+		version:     LatestSchemaVersion() + 1,
+		description: "add nodes.extra",
+		up:          execStatements("ALTER TABLE nodes ADD COLUMN extra TEXT"),
+	})
+
+	database, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("Expected rebuild to succeed, got %v", err)
+	}
+	defer database.Close()
+
+	version, err := database.SchemaVersion(context.Background())
+	if err != nil {
+		t.Fatalf("SchemaVersion failed: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("Expected schema version %d after rebuild, got %d", LatestSchemaVersion(), version)
+	}
+
+	var count int
+	if err := database.QueryRow("SELECT COUNT(*) FROM nodes").Scan(&count); err != nil {
+		t.Fatalf("Failed to count nodes: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected rebuilt database to be empty, got %d nodes", count)
+	}
+}
+
+func TestMigrate_IndexesExistingNodesForSearch(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Create a version 3 database holding one node.
+	saved := migrations
+	migrations = saved[:3]
+	database, err := New(dbPath)
+	migrations = saved
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	if _, err := database.Exec("INSERT INTO nodes (id, name, qualified_name, kind, file_path, line_start, line_end, col_start, col_end) VALUES ('n1', 'parseHTTPHeader', 'web.parseHTTPHeader', 'function_declaration', 'a.go', 1, 1, 1, 1)"); err != nil {
+		t.Fatalf("Failed to insert node: %v", err)
+	}
+	database.Close()
+
+	database, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate DB: %v", err)
+	}
+	defer database.Close()
+
+	var id string
+	if err := database.QueryRow("SELECT n.id FROM nodes_fts JOIN nodes n ON n.rowid = nodes_fts.rowid WHERE nodes_fts MATCH 'header'").Scan(&id); err != nil {
+		t.Fatalf("Expected the existing node to be searchable by word: %v", err)
+	}
+	if id != "n1" {
+		t.Errorf("Expected n1, got %s", id)
+	}
+}
+
+func TestMigrate_BackfillsSymbolKinds(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Create a version 6 database holding nodes of several kinds.
+	saved := migrations
+	migrations = saved[:6]
+	database, err := New(dbPath)
+	migrations = saved
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	for id, kind := range map[string]string{
+		"f": "function_declaration",
+		"m": "method_definition",
+		"c": "class_definition",
+		"i": "interface_declaration",
+		"v": "variable_declarator",
+		"x": "symbol",
+	} {
+		if _, err := database.Exec("INSERT INTO nodes (id, name, qualified_name, kind, file_path, line_start, line_end, col_start, col_end) VALUES (?, ?, ?, ?, 'a.ts', 1, 1, 1, 1)", id, id, id, kind); err != nil {
+			t.Fatalf("Failed to insert node: %v", err)
+		}
+	}
+	database.Close()
+
+	database, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate DB: %v", err)
+	}
+	defer database.Close()
+
+	want := map[string]string{"f": "function", "m": "method", "c": "class", "i": "interface", "v": "variable", "x": ""}
+	for id, kind := range want {
+		var got string
+		if err := database.QueryRow("SELECT symbol_kind FROM nodes WHERE id = ?", id).Scan(&got); err != nil {
+			t.Fatalf("Failed to read node %s: %v", id, err)
+		}
+		if got != kind {
+			t.Errorf("symbol_kind of %s = %q, want %q", id, got, kind)
+		}
+	}
+}
+
+func TestMigrate_AttachesNodesToTheirFile(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Create a version 7 database holding a file and a definition in it.
+	saved := migrations
+	migrations = saved[:7]
+	database, err := New(dbPath)
+	migrations = saved
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	for id, kind := range map[string]string{"file": "file", "f": "function_declaration"} {
+		if _, err := database.Exec("INSERT INTO nodes (id, name, qualified_name, kind, file_path, line_start, line_end, col_start, col_end) VALUES (?, ?, ?, ?, 'a.ts', 1, 1, 1, 1)", id, id, id, kind); err != nil {
+			t.Fatalf("Failed to insert node: %v", err)
+		}
+	}
+	database.Close()
+
+	database, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate DB: %v", err)
+	}
+	defer database.Close()
+
+	for id, parent := range map[string]string{"file": "", "f": "file"} {
+		var got string
+		if err := database.QueryRow("SELECT parent_id FROM nodes WHERE id = ?", id).Scan(&got); err != nil {
+			t.Fatalf("Failed to read node %s: %v", id, err)
+		}
+		if got != parent {
+			t.Errorf("parent_id of %s = %q, want %q", id, got, parent)
+		}
+	}
+}
+
+func TestMigrate_RecordsFirstUseOfEdges(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Create a version 9 database with a call edge and a contains edge.
+	saved := migrations
+	migrations = saved[:9]
+	database, err := New(dbPath)
+	migrations = saved
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	for _, id := range []string{"a", "b"} {
+		if _, err := database.Exec("INSERT INTO nodes (id, name, qualified_name, kind, file_path, line_start, line_end, col_start, col_end) VALUES (?, ?, ?, 'function_declaration', ?, 1, 9, 1, 1)", id, id, id, id+".go"); err != nil {
+			t.Fatalf("Failed to insert node: %v", err)
+		}
+	}
+	for _, relation := range []string{"calls", "contains"} {
+		if _, err := database.Exec("INSERT INTO edges (source_id, target_id, relation, line) VALUES ('a', 'b', ?, 3)", relation); err != nil {
+			t.Fatalf("Failed to insert edge: %v", err)
+		}
+	}
+	database.Close()
+
+	database, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate DB: %v", err)
+	}
+	defer database.Close()
+
+	var relation, file string
+	var line, n int
+	if err := database.QueryRow("SELECT relation, file_path, line, COUNT(*) FROM edge_sites").Scan(&relation, &file, &line, &n); err != nil {
+		t.Fatalf("Failed to read edge sites: %v", err)
+	}
+	if n != 1 || relation != "calls" || file != "a.go" || line != 3 {
+		t.Errorf("edge sites = %d, first %s at %s:%d, want the calls edge at a.go:3", n, relation, file, line)
+	}
+
+	// Sites go with their edge.
+	if _, err := database.Exec("DELETE FROM nodes WHERE id = 'b'"); err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+	if err := database.QueryRow("SELECT COUNT(*) FROM edge_sites").Scan(&n); err != nil || n != 0 {
+		t.Errorf("edge sites after deleting the target = %d, %v, want 0", n, err)
+	}
+}
+
+func TestMigrate_StoresGoTypeSpecsAsTypeSpec(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Create a version 11 database holding Go types under their old kinds
+	// and a TypeScript interface.
+	saved := migrations
+	migrations = saved[:11]
+	database, err := New(dbPath)
+	migrations = saved
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	for _, n := range []struct{ id, kind, symbolKind, file string }{
+		{"i", "interface_declaration", "interface", "a.go"},
+		{"s", "struct_declaration", "struct", "a.go"},
+		{"c", "type_declaration", "class", "a.go"},
+		{"t", "interface_declaration", "interface", "a.ts"},
+	} {
+		if _, err := database.Exec("INSERT INTO nodes (id, name, qualified_name, kind, symbol_kind, file_path, line_start, line_end, col_start, col_end) VALUES (?, ?, ?, ?, ?, ?, 1, 1, 1, 1)", n.id, n.id, n.id, n.kind, n.symbolKind, n.file); err != nil {
+			t.Fatalf("Failed to insert node: %v", err)
+		}
+	}
+	database.Close()
+
+	database, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate DB: %v", err)
+	}
+	defer database.Close()
+
+	want := map[string][2]string{
+		"i": {"type_spec", "interface"},
+		"s": {"type_spec", "struct"},
+		"c": {"type_spec", "class"},
+		"t": {"interface_declaration", "interface"},
+	}
+	for id, w := range want {
+		var kind, symbolKind string
+		if err := database.QueryRow("SELECT kind, symbol_kind FROM nodes WHERE id = ?", id).Scan(&kind, &symbolKind); err != nil {
+			t.Fatalf("Failed to read node %s: %v", id, err)
+		}
+		if kind != w[0] || symbolKind != w[1] {
+			t.Errorf("node %s = %s/%s, want %s/%s", id, kind, symbolKind, w[0], w[1])
+		}
+	}
+}
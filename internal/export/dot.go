@@ -0,0 +1,46 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"codemap/internal/graph"
+)
+
+func writeDOT(w io.Writer, g *graph.Subgraph, clusters []*cluster) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph codemap {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+	fmt.Fprintln(bw, "  node [shape=box, fontname=\"Helvetica\"];")
+
+	for i, c := range clusters {
+		indent := "  "
+		if c.Label != "" {
+			fmt.Fprintf(bw, "  subgraph cluster_%d {\n", i)
+			fmt.Fprintf(bw, "    label=%s;\n", dotQuote(c.Label))
+			indent = "    "
+		}
+		for _, n := range c.members {
+			fmt.Fprintf(bw, "%s%s [label=%s, kind=%s, file=%s, line=%d];\n",
+				indent, dotQuote(n.ID), dotQuote(nodeLabel(n, "\n")),
+				dotQuote(n.Kind), dotQuote(n.FilePath), n.LineStart)
+		}
+		if c.Label != "" {
+			fmt.Fprintln(bw, "  }")
+		}
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "  %s -> %s [label=%s];\n", dotQuote(e.SourceID), dotQuote(e.TargetID), dotQuote(e.Relation))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotQuote returns s as a double-quoted DOT string.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
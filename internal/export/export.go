@@ -0,0 +1,137 @@
+// Package export serializes code graphs to formats understood by other tools:
+// GraphViz DOT, GraphML, Mermaid flowcharts and plain JSON.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"codemap/internal/graph"
+)
+
+// Supported formats.
+const (
+	FormatDOT     = "dot"
+	FormatGraphML = "graphml"
+	FormatMermaid = "mermaid"
+	FormatJSON    = "json"
+)
+
+// Supported clusterings.
+const (
+	ClusterNone    = ""
+	ClusterFile    = "file"
+	ClusterPackage = "package"
+)
+
+// Formats lists the accepted format names.
+var Formats = []string{FormatDOT, FormatGraphML, FormatMermaid, FormatJSON}
+
+// Options controls serialization. Cluster groups nodes by the file they are
+// defined in or by its directory (the package).
+type Options struct {
+	Format  string
+	Cluster string
+}
+
+// Validate reports an unknown format or clustering.
+func (o Options) Validate() error {
+	valid := false
+	for _, f := range Formats {
+		if o.Format == f {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("unknown format %q (valid: %s)", o.Format, strings.Join(Formats, ", "))
+	}
+	switch o.Cluster {
+	case ClusterNone, ClusterFile, ClusterPackage:
+		return nil
+	}
+	return fmt.Errorf("unknown cluster %q (valid: file, package)", o.Cluster)
+}
+
+// Write serializes g to w.
+func Write(w io.Writer, g *graph.Subgraph, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	clusters := clusterNodes(g.Nodes, opts.Cluster)
+	switch opts.Format {
+	case FormatDOT:
+		return writeDOT(w, g, clusters)
+	case FormatGraphML:
+		return writeGraphML(w, g, clusters)
+	case FormatMermaid:
+		return writeMermaid(w, g, clusters)
+	default:
+		return writeJSON(w, g, clusters)
+	}
+}
+
+// cluster is a named group of nodes. Without clustering, all nodes are in a
+// single cluster with an empty label.
+type cluster struct {
+	Label string   `json:"label"`
+	Nodes []string `json:"nodes"`
+
+	members []*graph.Node
+}
+
+func clusterNodes(nodes []*graph.Node, by string) []*cluster {
+	if by == ClusterNone {
+		return []*cluster{{members: nodes}}
+	}
+	index := make(map[string]*cluster)
+	var clusters []*cluster
+	for _, n := range nodes {
+		label := n.FilePath
+		if by == ClusterPackage {
+			label = filepath.Dir(n.FilePath)
+		}
+		c, ok := index[label]
+		if !ok {
+			c = &cluster{Label: label}
+			index[label] = c
+			clusters = append(clusters, c)
+		}
+		c.members = append(c.members, n)
+		c.Nodes = append(c.Nodes, n.ID)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Label < clusters[j].Label })
+	return clusters
+}
+
+// nodeLabel is the human-readable label used by the visual formats.
+func nodeLabel(n *graph.Node, sep string) string {
+	name := n.QualifiedName
+	if name == "" {
+		name = n.Name
+	}
+	return fmt.Sprintf("%s%s%s%s%s:%d", name, sep, n.Kind, sep, filepath.Base(n.FilePath), n.LineStart)
+}
+
+func writeJSON(w io.Writer, g *graph.Subgraph, clusters []*cluster) error {
+	doc := struct {
+		Nodes    []*graph.Node `json:"nodes"`
+		Edges    []*graph.Edge `json:"edges"`
+		Clusters []*cluster    `json:"clusters,omitempty"`
+	}{Nodes: g.Nodes, Edges: g.Edges}
+	if doc.Nodes == nil {
+		doc.Nodes = []*graph.Node{}
+	}
+	if doc.Edges == nil {
+		doc.Edges = []*graph.Edge{}
+	}
+	if len(clusters) > 0 && clusters[0].Label != "" {
+		doc.Clusters = clusters
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,91 @@
+package export
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"codemap/internal/graph"
+)
+
+// graphMLKeys declares the attributes written for nodes and edges.
+var graphMLKeys = []struct{ id, target, name, typ string }{
+	{"name", "node", "name", "string"},
+	{"qualified_name", "node", "qualified_name", "string"},
+	{"kind", "node", "kind", "string"},
+	{"symbol_kind", "node", "symbol_kind", "string"},
+	{"file_path", "node", "file_path", "string"},
+	{"line_start", "node", "line_start", "int"},
+	{"line_end", "node", "line_end", "int"},
+	{"label", "node", "label", "string"},
+	{"relation", "edge", "relation", "string"},
+	{"line", "edge", "line", "int"},
+	{"provenance", "edge", "provenance", "string"},
+	{"confidence", "edge", "confidence", "double"},
+}
+
+// writeGraphML writes a GraphML document. Clusters become group nodes with a
+// nested graph, the representation yEd and Gephi understand.
+func writeGraphML(w io.Writer, g *graph.Subgraph, clusters []*cluster) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, xml.Header+`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	for _, k := range graphMLKeys {
+		fmt.Fprintf(bw, "  <key id=%q for=%q attr.name=%q attr.type=%q/>\n", k.id, k.target, k.name, k.typ)
+	}
+	fmt.Fprintln(bw, `  <graph id="codemap" edgedefault="directed">`)
+
+	for i, c := range clusters {
+		indent := "    "
+		if c.Label != "" {
+			id := "cluster" + strconv.Itoa(i)
+			fmt.Fprintf(bw, "    <node id=\"%s\">\n", xmlEscape(id))
+			fmt.Fprintf(bw, "      <data key=\"label\">%s</data>\n", xmlEscape(c.Label))
+			fmt.Fprintf(bw, "      <graph id=\"%s:\" edgedefault=\"directed\">\n", xmlEscape(id))
+			indent = "        "
+		}
+		for _, n := range c.members {
+			fmt.Fprintf(bw, "%s<node id=\"%s\">\n", indent, xmlEscape(n.ID))
+			for _, d := range [][2]string{
+				{"name", n.Name},
+				{"qualified_name", n.QualifiedName},
+				{"kind", n.Kind},
+				{"symbol_kind", n.SymbolKind},
+				{"file_path", n.FilePath},
+				{"line_start", strconv.Itoa(n.LineStart)},
+				{"line_end", strconv.Itoa(n.LineEnd)},
+			} {
+				fmt.Fprintf(bw, "%s  <data key=\"%s\">%s</data>\n", indent, d[0], xmlEscape(d[1]))
+			}
+			fmt.Fprintf(bw, "%s</node>\n", indent)
+		}
+		if c.Label != "" {
+			fmt.Fprintln(bw, "      </graph>")
+			fmt.Fprintln(bw, "    </node>")
+		}
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "    <edge source=\"%s\" target=\"%s\">\n", xmlEscape(e.SourceID), xmlEscape(e.TargetID))
+		fmt.Fprintf(bw, "      <data key=\"relation\">%s</data>\n", xmlEscape(e.Relation))
+		if e.Line > 0 {
+			fmt.Fprintf(bw, "      <data key=\"line\">%d</data>\n", e.Line)
+		}
+		if e.Provenance != "" {
+			fmt.Fprintf(bw, "      <data key=\"provenance\">%s</data>\n", xmlEscape(e.Provenance))
+			fmt.Fprintf(bw, "      <data key=\"confidence\">%s</data>\n", strconv.FormatFloat(e.Confidence, 'g', -1, 64))
+		}
+		fmt.Fprintln(bw, "    </edge>")
+	}
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	return bw.Flush()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"codemap/internal/graph"
+)
+
+// writeMermaid writes a Mermaid flowchart. Mermaid identifiers cannot hold
+// arbitrary text, so nodes are numbered n0, n1, ... in output order.
+func writeMermaid(w io.Writer, g *graph.Subgraph, clusters []*cluster) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "flowchart LR")
+
+	ids := make(map[string]string, len(g.Nodes))
+	for i, c := range clusters {
+		indent := "  "
+		if c.Label != "" {
+			fmt.Fprintf(bw, "  subgraph c%d[%s]\n", i, mermaidQuote(c.Label))
+			indent = "    "
+		}
+		for _, n := range c.members {
+			id := fmt.Sprintf("n%d", len(ids))
+			ids[n.ID] = id
+			fmt.Fprintf(bw, "%s%s[%s]\n", indent, id, mermaidQuote(nodeLabel(n, "<br/>")))
+		}
+		if c.Label != "" {
+			fmt.Fprintln(bw, "  end")
+		}
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "  %s -->|%s| %s\n", ids[e.SourceID], mermaidQuote(e.Relation), ids[e.TargetID])
+	}
+	return bw.Flush()
+}
+
+// mermaidQuote returns s as a double-quoted Mermaid label, with quotes
+// replaced by entity codes.
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
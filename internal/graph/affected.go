@@ -0,0 +1,265 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+)
+
+// CallersOf returns the stored definitions with a call site naming one of
+// names. Their heuristic calls edges may resolve differently once a
+// definition of that name is added, changed or removed.
+func (s *Store) CallersOf(ctx context.Context, names []string) ([]*Node, error) {
+	var ids []string
+	seen := make(map[string]bool)
+	for start := 0; start < len(names); start += maxQueryParams {
+		end := start + maxQueryParams
+		if end > len(names) {
+			end = len(names)
+		}
+		chunk := names[start:end]
+
+		args := make([]interface{}, len(chunk))
+		for i, name := range chunk {
+			args[i] = name
+		}
+		rows, err := s.db.QueryContext(ctx,
+			"SELECT DISTINCT caller_id FROM call_sites WHERE name IN ("+placeholders(len(chunk))+")", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query callers: %w", err)
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	byID, err := s.nodesByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	callers := make([]*Node, 0, len(byID))
+	for _, n := range byID {
+		callers = append(callers, n)
+	}
+	sortNodes(callers)
+	return callers, nil
+}
+
+// RefreshDependents finds again the edges from outside changed that depend
+// on changed and removed, the nodes an update stored again and deleted. It
+// must run once the edges of changed are stored. The heuristic calls edges
+// of definitions with a call site naming one of them are resolved again
+// from their stored call sites, and so are the overrides edges of the
+// methods of the subtypes of changed types and of the types holding a
+// changed or removed method. It stores the edges and returns them.
+func (s *Store) RefreshDependents(ctx context.Context, changed, removed []*Node) ([]*Edge, error) {
+	skip := make(map[string]bool, len(changed))
+	for _, n := range changed {
+		skip[n.ID] = true
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, n := range append(append([]*Node{}, changed...), removed...) {
+		if n.Kind != KindFile && !seen[n.Name] {
+			seen[n.Name] = true
+			names = append(names, n.Name)
+		}
+	}
+	stored, err := s.CallersOf(ctx, names)
+	if err != nil {
+		return nil, fmt.Errorf("finding callers failed: %w", err)
+	}
+	var callers []*Node
+	for _, n := range stored {
+		if skip[n.ID] {
+			continue
+		}
+		if n.Calls, err = s.GetCallSites(ctx, n.ID); err != nil {
+			return nil, err
+		}
+		callers = append(callers, n)
+	}
+	if err := s.ClearHeuristicCalls(ctx, callers); err != nil {
+		return nil, err
+	}
+	edges, err := s.HeuristicEdges(ctx, callers)
+	if err != nil {
+		return nil, fmt.Errorf("heuristic resolution of callers failed: %w", err)
+	}
+	if err := s.BulkUpsertEdges(ctx, edges); err != nil {
+		return nil, err
+	}
+
+	overrides, err := s.refreshOverrides(ctx, changed, removed, skip)
+	if err != nil {
+		return nil, fmt.Errorf("override resolution of subtypes failed: %w", err)
+	}
+	if err := s.BulkUpsertEdges(ctx, overrides); err != nil {
+		return nil, err
+	}
+	return append(edges, overrides...), nil
+}
+
+// refreshOverrides replaces the overrides edges of the methods of the
+// subtypes, outside skip, of the types among changed and of the types
+// holding a method among changed or removed.
+func (s *Store) refreshOverrides(ctx context.Context, changed, removed []*Node, skip map[string]bool) ([]*Edge, error) {
+	var frontier []string
+	visited := make(map[string]bool)
+	add := func(id string) {
+		if id != "" && !visited[id] {
+			visited[id] = true
+			frontier = append(frontier, id)
+		}
+	}
+	for _, n := range changed {
+		if isTypeKind(n.SymbolKind) {
+			add(n.ID)
+		} else if n.SymbolKind == SymbolMethod {
+			add(n.ParentID)
+		}
+	}
+	for _, n := range removed {
+		if n.SymbolKind == SymbolMethod {
+			add(n.ParentID)
+		}
+	}
+
+	var subtypes []string
+	for depth := 0; depth < maxHierarchyDepth && len(frontier) > 0; depth++ {
+		var next []string
+		for _, id := range frontier {
+			links, err := s.hierarchyLinks(ctx, id, Dependents)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range links {
+				if visited[l.other] {
+					continue
+				}
+				visited[l.other] = true
+				next = append(next, l.other)
+				if !skip[l.other] {
+					subtypes = append(subtypes, l.other)
+				}
+			}
+		}
+		frontier = next
+	}
+	if len(subtypes) == 0 {
+		return nil, nil
+	}
+
+	byID, err := s.nodesByID(ctx, subtypes)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*Node
+	for _, id := range subtypes {
+		t := byID[id]
+		if t == nil {
+			continue
+		}
+		members, err := s.Members(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, t)
+		for _, m := range members {
+			if !skip[m.ID] {
+				nodes = append(nodes, m)
+			}
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, n := range nodes {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM edges WHERE source_id = ? AND relation = ?", n.ID, RelationOverrides); err != nil {
+			return nil, fmt.Errorf("failed to clear overrides of %s: %w", n.ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.OverrideEdges(ctx, nodes)
+}
+
+// MentionedNodes returns the stored definitions, other than nodes, that a
+// call site of nodes names or that nodes declare as their base types. These
+// are the definitions the nodes may refer to, whose references a language
+// server finds.
+func (s *Store) MentionedNodes(ctx context.Context, nodes []*Node) ([]*Node, error) {
+	self := make(map[string]bool, len(nodes))
+	var names []string
+	seen := make(map[string]bool)
+	addName := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, n := range nodes {
+		self[n.ID] = true
+		for _, c := range n.Calls {
+			for _, name := range calleeNames(c) {
+				addName(name)
+			}
+		}
+		for _, st := range n.Supertypes {
+			addName(st.Name)
+		}
+	}
+
+	byName, err := s.nodesByName(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	var mentioned []*Node
+	for _, name := range names {
+		for _, n := range byName[name] {
+			if !self[n.ID] {
+				mentioned = append(mentioned, n)
+			}
+		}
+	}
+	sortNodes(mentioned)
+	return mentioned, nil
+}
+
+// ClearHeuristicCalls deletes the calls edges that HeuristicEdges found from
+// nodes, so that finding them again drops those that no longer resolve.
+// Edges a language server found or confirmed are kept.
+func (s *Store) ClearHeuristicCalls(ctx context.Context, nodes []*Node) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, n := range nodes {
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM edges WHERE source_id = ? AND provenance = ? AND relation = ?",
+			n.ID, ProvenanceHeuristic, RelationCalls)
+		if err != nil {
+			return fmt.Errorf("failed to clear heuristic calls of %s: %w", n.ID, err)
+		}
+	}
+
+	return tx.Commit()
+}
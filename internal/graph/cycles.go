@@ -0,0 +1,337 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// CycleOptions filters a cycle search. Zero values mean all relations but
+// contains, cycles of at least two members and no limit.
+type CycleOptions struct {
+	Relations []string
+	MinSize   int
+	Limit     int
+}
+
+// CycleEdge is an edge inside a cycle. Source and Target are qualified names
+// for symbol cycles and directories for package cycles; Via names one
+// symbol-level edge behind a package edge.
+type CycleEdge struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Relation string `json:"relation"`
+	Via      string `json:"via,omitempty"`
+}
+
+// SymbolCycle is a strongly connected component of symbols.
+type SymbolCycle struct {
+	Size         int         `json:"size"`
+	Members      []PathStep  `json:"members"`
+	ClosingEdges []CycleEdge `json:"closing_edges"`
+}
+
+// PackageCycle is a strongly connected component of directories.
+type PackageCycle struct {
+	Size         int         `json:"size"`
+	Packages     []string    `json:"packages"`
+	ClosingEdges []CycleEdge `json:"closing_edges"`
+}
+
+// cycleEdgeRow is an edge joined with both endpoints.
+type cycleEdgeRow struct {
+	source, target, relation string
+	sourceName, targetName   string
+	sourcePath, targetPath   string
+}
+
+func (s *Store) loadCycleEdges(ctx context.Context, relations []string) ([]cycleEdgeRow, error) {
+	query := `
+	SELECT e.source_id, e.target_id, e.relation, src.qualified_name, dst.qualified_name, src.file_path, dst.file_path
+	FROM edges e
+	JOIN nodes src ON src.id = e.source_id
+	JOIN nodes dst ON dst.id = e.target_id`
+	cond, args := relationFilter("e.relation", relations)
+	query += " WHERE " + cond
+	query += " ORDER BY e.source_id, e.target_id, e.relation"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load edges: %w", err)
+	}
+	defer rows.Close()
+
+	var result []cycleEdgeRow
+	for rows.Next() {
+		var r cycleEdgeRow
+		if err := rows.Scan(&r.source, &r.target, &r.relation, &r.sourceName, &r.targetName, &r.sourcePath, &r.targetPath); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// FindSymbolCycles returns the strongly connected components of the symbol
+// graph, largest first. Each cycle lists the edges that close its loops:
+// removing them leaves the component acyclic.
+func (s *Store) FindSymbolCycles(ctx context.Context, opts CycleOptions) ([]SymbolCycle, error) {
+	rows, err := s.loadCycleEdges(ctx, opts.Relations)
+	if err != nil {
+		return nil, err
+	}
+
+	g := newDigraph()
+	for _, r := range rows {
+		if r.source != r.target {
+			g.addEdge(r.source, r.target, r.relation)
+		}
+	}
+
+	var memberIDs []string
+	comps := g.components(minCycleSize(opts.MinSize))
+	for _, comp := range comps {
+		memberIDs = append(memberIDs, comp...)
+	}
+	nodes, err := s.nodesByID(ctx, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+	name := func(id string) string {
+		if n := nodes[id]; n != nil {
+			return n.QualifiedName
+		}
+		return id
+	}
+
+	var cycles []SymbolCycle
+	for _, comp := range comps {
+		c := SymbolCycle{Size: len(comp)}
+		for _, id := range comp {
+			if n := nodes[id]; n != nil {
+				c.Members = append(c.Members, PathStep{Name: n.Name, QualifiedName: n.QualifiedName, FilePath: n.FilePath, Line: n.LineStart})
+			}
+		}
+		for _, e := range g.closingEdges(comp) {
+			c.ClosingEdges = append(c.ClosingEdges, CycleEdge{Source: name(e.from), Target: name(e.to), Relation: e.relation})
+		}
+		cycles = append(cycles, c)
+		if opts.Limit > 0 && len(cycles) >= opts.Limit {
+			break
+		}
+	}
+	return cycles, nil
+}
+
+// FindPackageCycles collapses symbols into their directories and returns the
+// strongly connected components of the resulting package graph, largest first.
+func (s *Store) FindPackageCycles(ctx context.Context, opts CycleOptions) ([]PackageCycle, error) {
+	rows, err := s.loadCycleEdges(ctx, opts.Relations)
+	if err != nil {
+		return nil, err
+	}
+
+	g := newDigraph()
+	via := make(map[[2]string]string)
+	for _, r := range rows {
+		from, to := filepath.Dir(r.sourcePath), filepath.Dir(r.targetPath)
+		if from == to {
+			continue
+		}
+		key := [2]string{from, to}
+		if _, ok := via[key]; !ok {
+			via[key] = r.sourceName + " -> " + r.targetName
+			g.addEdge(from, to, r.relation)
+		}
+	}
+
+	var cycles []PackageCycle
+	for _, comp := range g.components(minCycleSize(opts.MinSize)) {
+		c := PackageCycle{Size: len(comp), Packages: comp}
+		for _, e := range g.closingEdges(comp) {
+			c.ClosingEdges = append(c.ClosingEdges, CycleEdge{Source: e.from, Target: e.to, Relation: e.relation, Via: via[[2]string{e.from, e.to}]})
+		}
+		cycles = append(cycles, c)
+		if opts.Limit > 0 && len(cycles) >= opts.Limit {
+			break
+		}
+	}
+	return cycles, nil
+}
+
+func minCycleSize(n int) int {
+	if n < 2 {
+		return 2
+	}
+	return n
+}
+
+// digraph is an in-memory adjacency list used for cycle analysis.
+type digraph struct {
+	vertices []string
+	index    map[string]int
+	out      [][]traversalEdge
+}
+
+func newDigraph() *digraph {
+	return &digraph{index: make(map[string]int)}
+}
+
+func (g *digraph) vertex(id string) int {
+	if i, ok := g.index[id]; ok {
+		return i
+	}
+	g.index[id] = len(g.vertices)
+	g.vertices = append(g.vertices, id)
+	g.out = append(g.out, nil)
+	return len(g.vertices) - 1
+}
+
+func (g *digraph) addEdge(from, to, relation string) {
+	i := g.vertex(from)
+	g.vertex(to)
+	for _, e := range g.out[i] {
+		if e.to == to {
+			return
+		}
+	}
+	g.out[i] = append(g.out[i], traversalEdge{from: from, to: to, relation: relation})
+}
+
+// components returns the strongly connected components with at least
+// minSize members, largest first, each sorted by vertex name. It uses an
+// iterative form of Tarjan's algorithm so deep graphs cannot overflow the
+// stack.
+func (g *digraph) components(minSize int) [][]string {
+	n := len(g.vertices)
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+
+	type frame struct {
+		v, next int
+	}
+	var stack []int
+	var comps [][]string
+	counter := 0
+
+	for root := 0; root < n; root++ {
+		if index[root] != -1 {
+			continue
+		}
+		call := []frame{{v: root}}
+		index[root], low[root] = counter, counter
+		counter++
+		stack = append(stack, root)
+		onStack[root] = true
+
+		for len(call) > 0 {
+			top := &call[len(call)-1]
+			v := top.v
+			if top.next < len(g.out[v]) {
+				w := g.index[g.out[v][top.next].to]
+				top.next++
+				if index[w] == -1 {
+					index[w], low[w] = counter, counter
+					counter++
+					stack = append(stack, w)
+					onStack[w] = true
+					call = append(call, frame{v: w})
+				} else if onStack[w] && index[w] < low[v] {
+					low[v] = index[w]
+				}
+				continue
+			}
+
+			call = call[:len(call)-1]
+			if len(call) > 0 {
+				parent := call[len(call)-1].v
+				if low[v] < low[parent] {
+					low[parent] = low[v]
+				}
+			}
+			if low[v] != index[v] {
+				continue
+			}
+
+			var comp []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				comp = append(comp, g.vertices[w])
+				if w == v {
+					break
+				}
+			}
+			if len(comp) >= minSize {
+				sort.Strings(comp)
+				comps = append(comps, comp)
+			}
+		}
+	}
+
+	sort.SliceStable(comps, func(i, j int) bool {
+		if len(comps[i]) != len(comps[j]) {
+			return len(comps[i]) > len(comps[j])
+		}
+		return comps[i][0] < comps[j][0]
+	})
+	return comps
+}
+
+// closingEdges returns the back edges found by a depth-first search of the
+// component, starting at its first member. Each one closes at least one loop,
+// and removing all of them breaks every cycle in the component.
+func (g *digraph) closingEdges(comp []string) []traversalEdge {
+	member := make(map[string]bool, len(comp))
+	for _, id := range comp {
+		member[id] = true
+	}
+
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make(map[string]int, len(comp))
+	var result []traversalEdge
+
+	type frame struct {
+		v    string
+		next int
+	}
+	for _, root := range comp {
+		if state[root] != unvisited {
+			continue
+		}
+		call := []frame{{v: root}}
+		state[root] = active
+		for len(call) > 0 {
+			top := &call[len(call)-1]
+			out := g.out[g.index[top.v]]
+			if top.next >= len(out) {
+				state[top.v] = done
+				call = call[:len(call)-1]
+				continue
+			}
+			e := out[top.next]
+			top.next++
+			if !member[e.to] {
+				continue
+			}
+			switch state[e.to] {
+			case unvisited:
+				state[e.to] = active
+				call = append(call, frame{v: e.to})
+			case active:
+				result = append(result, e)
+			}
+		}
+	}
+	return result
+}
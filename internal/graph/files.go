@@ -0,0 +1,202 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"codemap/internal/db"
+)
+
+// File is a source file as it was when last indexed. ModTime is in Unix
+// nanoseconds and Hash is the hex SHA-256 of the content. A file has a record
+// only while its nodes are up to date with that content. Enriched is false
+// while its edges are the heuristic ones alone because the language server
+// failed, so that the next index asks it again.
+type File struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mtime"`
+	Hash     string `json:"hash"`
+	Enriched bool   `json:"enriched"`
+}
+
+// Files returns the indexed files, keyed by path.
+func (s *Store) Files(ctx context.Context) (map[string]*File, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT path, language, size, mtime, hash, enriched FROM files")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files: %w", err)
+	}
+	defer rows.Close()
+
+	files := make(map[string]*File)
+	for rows.Next() {
+		f := &File{}
+		if err := rows.Scan(&f.Path, &f.Language, &f.Size, &f.ModTime, &f.Hash, &f.Enriched); err != nil {
+			return nil, err
+		}
+		files[f.Path] = f
+	}
+	return files, rows.Err()
+}
+
+// UpsertFiles records files as indexed. Call it once their nodes and edges
+// are stored, so that a file whose indexing failed is scanned again.
+func (s *Store) UpsertFiles(ctx context.Context, files []*File) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, f := range files {
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO files (path, language, size, mtime, hash, enriched)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+			language = excluded.language,
+			size = excluded.size,
+			mtime = excluded.mtime,
+			hash = excluded.hash,
+			enriched = excluded.enriched,
+			indexed_at = CURRENT_TIMESTAMP;
+		`, f.Path, f.Language, f.Size, f.ModTime, f.Hash, f.Enriched)
+		if err != nil {
+			return fmt.Errorf("failed to record file %s: %w", f.Path, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateFileNodes stores the nodes of a rescanned file, writing only those
+// that changed: new nodes, and nodes whose source, name, kind, parent or doc
+// comment differs from the stored one. A node's source is unchanged when
+// edited, the IDs of the nodes whose source an edit touched, leaves it out,
+// or else when its hash matches; a nil edited means any node may have been
+// touched. A node that only moved, such as below an added line, keeps its
+// edges, which move with it. Stored nodes of the file that are gone are
+// deleted. The outbound edges of a changed node and the contains edge to it
+// are cleared, for the caller to find again, while the other nodes keep
+// theirs. It returns the changed nodes and the deleted ones.
+func (s *Store) UpdateFileNodes(ctx context.Context, path string, nodes []*Node, edited map[string]bool) (changed, removed []*Node, err error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+nodeColumns+" FROM nodes WHERE file_path = ?", path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query nodes of %s: %w", path, err)
+	}
+	old, err := scanNodes(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	stored := make(map[string]*Node, len(old))
+	for _, n := range old {
+		stored[n.ID] = n
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	for _, n := range nodes {
+		prev := stored[n.ID]
+		delete(stored, n.ID)
+		if prev != nil && sameDefinition(prev, n) && sameSource(prev, n, edited) {
+			if delta, ok := moved(prev, n); ok {
+				if delta != 0 || prev.Hash != n.Hash {
+					if err := s.moveNode(ctx, tx, n, delta); err != nil {
+						return nil, nil, err
+					}
+				}
+				continue
+			}
+		}
+		if prev != nil {
+			if _, err := tx.ExecContext(ctx,
+				"DELETE FROM edges WHERE (source_id = ? AND relation != ?) OR (target_id = ? AND relation = ?)",
+				n.ID, RelationContains, n.ID, RelationContains); err != nil {
+				return nil, nil, fmt.Errorf("failed to clear edges of %s: %w", n.ID, err)
+			}
+		}
+		if err := s.upsertNode(ctx, tx, n); err != nil {
+			return nil, nil, err
+		}
+		changed = append(changed, n)
+	}
+	for _, n := range old {
+		if stored[n.ID] == nil {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM nodes WHERE id = ?", n.ID); err != nil {
+			return nil, nil, fmt.Errorf("failed to delete node %s: %w", n.ID, err)
+		}
+		removed = append(removed, n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return changed, removed, nil
+}
+
+// sameDefinition reports whether a scanned node declares the same thing as
+// the stored one, wherever in the file it now is. The signature is not
+// compared: a language server may have replaced the scanned one.
+func sameDefinition(stored, scanned *Node) bool {
+	return stored.Name == scanned.Name &&
+		stored.QualifiedName == scanned.QualifiedName &&
+		stored.Kind == scanned.Kind &&
+		stored.SymbolKind == scanned.SymbolKind &&
+		stored.ParentID == scanned.ParentID &&
+		stored.Doc == scanned.Doc
+}
+
+// sameSource reports whether the source of a scanned node is that of the
+// stored one, as UpdateFileNodes describes.
+func sameSource(stored, scanned *Node, edited map[string]bool) bool {
+	if edited != nil && !edited[scanned.ID] {
+		return true
+	}
+	return stored.Hash != "" && stored.Hash == scanned.Hash
+}
+
+// moved reports whether the source of a node moved by whole lines, and by
+// how many; delta is 0 if it did not move. Any other change of its position
+// counts as an edit.
+func moved(stored, scanned *Node) (delta int, ok bool) {
+	delta = scanned.LineStart - stored.LineStart
+	return delta, scanned.LineEnd-stored.LineEnd == delta &&
+		scanned.ColStart == stored.ColStart && scanned.ColEnd == stored.ColEnd
+}
+
+// moveNode stores the position of a node whose source moved delta lines,
+// moving the lines of its uses, outbound edges and the contains edge to it
+// along. A stored hash, missing for nodes stored before hashes were, is
+// filled in.
+func (s *Store) moveNode(ctx context.Context, execer db.Execer, n *Node, delta int) error {
+	if _, err := execer.ExecContext(ctx,
+		"UPDATE nodes SET line_start = ?, line_end = ?, col_start = ?, col_end = ?, hash = ? WHERE id = ?",
+		n.LineStart, n.LineEnd, n.ColStart, n.ColEnd, n.Hash, n.ID); err != nil {
+		return fmt.Errorf("failed to move node %s: %w", n.ID, err)
+	}
+	if delta == 0 {
+		return nil
+	}
+	if _, err := execer.ExecContext(ctx,
+		"UPDATE edges SET line = line + ? WHERE line > 0 AND ((source_id = ? AND relation != ?) OR (target_id = ? AND relation = ?))",
+		delta, n.ID, RelationContains, n.ID, RelationContains); err != nil {
+		return fmt.Errorf("failed to move edges of %s: %w", n.ID, err)
+	}
+	// Lines are part of the key of edge sites. Negating them first keeps a
+	// moved site from colliding with one yet to move.
+	if _, err := execer.ExecContext(ctx,
+		"UPDATE edge_sites SET line = -(line + ?) WHERE source_id = ? AND line > 0", delta, n.ID); err != nil {
+		return fmt.Errorf("failed to move edge sites of %s: %w", n.ID, err)
+	}
+	if _, err := execer.ExecContext(ctx,
+		"UPDATE edge_sites SET line = -line WHERE source_id = ? AND line < 0", n.ID); err != nil {
+		return fmt.Errorf("failed to move edge sites of %s: %w", n.ID, err)
+	}
+	return s.replaceCallSites(ctx, execer, n)
+}
@@ -0,0 +1,286 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// Confidence of a heuristic edge, by where the target was found relative to
+// the source. Ambiguous names split the confidence of their tier between up
+// to maxHeuristicCandidates targets and are dropped beyond that.
+const (
+	confidenceSameFile     = 0.9
+	confidenceImported     = 0.8
+	confidenceSamePackage  = 0.7
+	confidenceWorkspace    = 0.5
+	maxHeuristicCandidates = 3
+)
+
+// HeuristicEdges infers edges for the given nodes without a language server:
+// the imports and contains edges recorded by the scanner, calls edges matching each call
+// site's callee name to definitions of the same language, extends and
+// implements edges matching declared base types to type definitions, and
+// implements edges from Go types to the interfaces whose methods they all
+// declare. Matches in the caller's file are preferred, then the files it
+// imports, then its package. The nodes must already be stored; targets are looked up across the
+// whole graph, as are the imports of files whose file node is not among
+// nodes.
+func (s *Store) HeuristicEdges(ctx context.Context, nodes []*Node) ([]*Edge, error) {
+	edges := append(ImportEdges(nodes), ContainsEdges(nodes)...)
+
+	var names []string
+	seen := make(map[string]bool)
+	addName := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	var importIDs []string
+	for _, n := range nodes {
+		for _, c := range n.Calls {
+			for _, name := range calleeNames(c) {
+				addName(name)
+			}
+		}
+		for _, st := range n.Supertypes {
+			addName(st.Name)
+		}
+		for _, imp := range n.Imports {
+			importIDs = append(importIDs, imp.TargetID)
+		}
+	}
+	candidates, err := s.nodesByName(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	imported, err := s.nodesByID(ctx, importIDs)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	scanned := make(map[string]bool)
+	for _, n := range nodes {
+		if n.Kind == KindFile {
+			scanned[n.FilePath] = true
+		}
+	}
+	for _, n := range nodes {
+		if !scanned[n.FilePath] {
+			scanned[n.FilePath] = true
+			paths = append(paths, n.FilePath)
+		}
+	}
+	importsOf, err := s.storedImports(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	r := &heuristicResolver{candidates: candidates, importsOf: importsOf}
+	for _, n := range nodes {
+		for _, imp := range n.Imports {
+			if target := imported[imp.TargetID]; target != nil {
+				if r.importsOf[n.FilePath] == nil {
+					r.importsOf[n.FilePath] = make(map[string]bool)
+				}
+				r.importsOf[n.FilePath][target.FilePath] = true
+			}
+		}
+	}
+
+	add := func(from *Node, relation string, line, col int, tier float64, targets []*Node) {
+		for _, target := range targets {
+			edges = append(edges, &Edge{
+				SourceID:   from.ID,
+				TargetID:   target.ID,
+				Relation:   relation,
+				Line:       line,
+				Col:        col,
+				Provenance: ProvenanceHeuristic,
+				Confidence: tier / float64(len(targets)),
+			})
+		}
+	}
+	for _, n := range nodes {
+		for _, c := range n.Calls {
+			tier, callees := r.resolve(n, calleeNames(c), isCallTarget)
+			add(n, RelationCalls, c.Line, c.Col, tier, callees)
+		}
+		for _, st := range n.Supertypes {
+			tier, supers := r.resolve(n, []string{st.Name}, isTypeKind)
+			add(n, st.Relation, st.Line, st.Col, tier, supers)
+		}
+	}
+
+	implicit, err := s.goImplementsEdges(ctx, nodes, r)
+	if err != nil {
+		return nil, err
+	}
+	return append(edges, implicit...), nil
+}
+
+// heuristicResolver matches names to definitions by proximity.
+type heuristicResolver struct {
+	candidates map[string][]*Node         // definitions by name
+	importsOf  map[string]map[string]bool // file path -> imported file paths
+}
+
+// resolve returns the definitions of the given names that from most likely
+// refers to, with the confidence of their tier. Definitions in other
+// languages, from itself and those whose symbol kind accept rejects are
+// skipped; nothing is returned when the best tier holds more than
+// maxHeuristicCandidates.
+func (r *heuristicResolver) resolve(from *Node, names []string, accept func(symbolKind string) bool) (float64, []*Node) {
+	lang := languageFamily(from.FilePath)
+	tier, best := 0.0, []*Node(nil)
+	for _, name := range names {
+		for _, cand := range r.candidates[name] {
+			if cand.ID == from.ID || languageFamily(cand.FilePath) != lang || (accept != nil && !accept(cand.SymbolKind)) {
+				continue
+			}
+			conf := r.tier(from, cand)
+			if conf > tier {
+				tier, best = conf, nil
+			}
+			if conf == tier {
+				best = append(best, cand)
+			}
+		}
+	}
+	if len(best) > maxHeuristicCandidates {
+		return 0, nil
+	}
+	return tier, best
+}
+
+// tier returns the confidence of a match of from to cand by where cand is.
+func (r *heuristicResolver) tier(from, cand *Node) float64 {
+	switch {
+	case cand.FilePath == from.FilePath:
+		return confidenceSameFile
+	case r.importsOf[from.FilePath][cand.FilePath]:
+		return confidenceImported
+	case filepath.Dir(cand.FilePath) == filepath.Dir(from.FilePath):
+		return confidenceSamePackage
+	}
+	return confidenceWorkspace
+}
+
+// isTypeKind reports whether a symbol kind can be a base class or interface.
+func isTypeKind(symbolKind string) bool {
+	switch symbolKind {
+	case SymbolClass, SymbolInterface, SymbolStruct:
+		return true
+	}
+	return false
+}
+
+// isCallTarget reports whether a call may resolve to a definition of the
+// symbol kind. Fields are left out so that a method call is not matched to a
+// field of the same name.
+func isCallTarget(symbolKind string) bool {
+	return symbolKind != SymbolField
+}
+
+// calleeNames returns the definition names a call site may refer to: the
+// callee itself and, for Lua's module functions (function M.run), the
+// qualified form.
+func calleeNames(c CallSite) []string {
+	if c.Qualifier == "" {
+		return []string{c.Name}
+	}
+	return []string{c.Name, c.Qualifier + "." + c.Name}
+}
+
+// storedImports returns the workspace files each of paths imports, as
+// recorded by the stored imports edges of their file nodes.
+func (s *Store) storedImports(ctx context.Context, paths []string) (map[string]map[string]bool, error) {
+	importsOf := make(map[string]map[string]bool)
+	for start := 0; start < len(paths); start += maxQueryParams {
+		end := start + maxQueryParams
+		if end > len(paths) {
+			end = len(paths)
+		}
+		chunk := paths[start:end]
+
+		args := make([]interface{}, 0, len(chunk)+2)
+		args = append(args, RelationImports, KindFile)
+		for _, p := range chunk {
+			args = append(args, p)
+		}
+		rows, err := s.db.QueryContext(ctx, `
+		SELECT f.file_path, t.file_path FROM edges e
+		JOIN nodes f ON f.id = e.source_id
+		JOIN nodes t ON t.id = e.target_id
+		WHERE e.relation = ? AND f.kind = ? AND f.file_path IN (`+placeholders(len(chunk))+")", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query imports: %w", err)
+		}
+		for rows.Next() {
+			var from, to string
+			if err := rows.Scan(&from, &to); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if importsOf[from] == nil {
+				importsOf[from] = make(map[string]bool)
+			}
+			importsOf[from][to] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return importsOf, nil
+}
+
+// nodesByName loads the definitions with the given names, keyed by name.
+// File nodes are left out.
+func (s *Store) nodesByName(ctx context.Context, names []string) (map[string][]*Node, error) {
+	result := make(map[string][]*Node)
+	for start := 0; start < len(names); start += maxQueryParams {
+		end := start + maxQueryParams
+		if end > len(names) {
+			end = len(names)
+		}
+		chunk := names[start:end]
+
+		args := make([]interface{}, 0, len(chunk)+1)
+		for _, name := range chunk {
+			args = append(args, name)
+		}
+		args = append(args, KindFile)
+		rows, err := s.db.QueryContext(ctx,
+			"SELECT "+nodeColumns+" FROM nodes WHERE name IN ("+placeholders(len(chunk))+") AND kind != ? ORDER BY file_path, line_start",
+			args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query nodes by name: %w", err)
+		}
+		nodes, err := scanNodes(rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			result[n.Name] = append(result[n.Name], n)
+		}
+	}
+	return result, nil
+}
+
+// languageFamily groups files whose symbols can call each other. JavaScript
+// and TypeScript form one family.
+func languageFamily(path string) string {
+	ext := filepath.Ext(path)
+	for lang, exts := range languageExtensions {
+		for _, e := range exts {
+			if e == ext {
+				if lang == "typescript" {
+					return "javascript"
+				}
+				return lang
+			}
+		}
+	}
+	return ext
+}
@@ -0,0 +1,257 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+)
+
+// maxHierarchyDepth bounds walks up and down the type hierarchy.
+const maxHierarchyDepth = 32
+
+// TypeNode is a type in a hierarchy tree. Relation, Provenance and
+// Confidence describe the edge to its parent in the tree and are empty for
+// the root. Supertypes are filled in above the root, Subtypes below it.
+type TypeNode struct {
+	Name          string      `json:"name"`
+	QualifiedName string      `json:"qualified_name"`
+	Kind          string      `json:"kind"`
+	SymbolKind    string      `json:"symbol_kind"`
+	FilePath      string      `json:"file_path"`
+	Line          int         `json:"line"`
+	Signature     string      `json:"signature,omitempty"`
+	Doc           string      `json:"doc,omitempty"`
+	Relation      string      `json:"relation,omitempty"`
+	Provenance    string      `json:"provenance,omitempty"`
+	Confidence    float64     `json:"confidence,omitempty"`
+	Supertypes    []*TypeNode `json:"supertypes,omitempty"`
+	Subtypes      []*TypeNode `json:"subtypes,omitempty"`
+}
+
+// hierarchyLink is an extends or implements edge seen from one end.
+type hierarchyLink struct {
+	other      string
+	relation   string
+	provenance string
+	confidence float64
+}
+
+// hierarchyLinks returns the supertypes (dir == Dependencies) or subtypes
+// (dir == Dependents) of a type.
+func (s *Store) hierarchyLinks(ctx context.Context, id string, dir Direction) ([]hierarchyLink, error) {
+	fromCol, toCol := "target_id", "source_id"
+	if dir == Dependencies {
+		fromCol, toCol = "source_id", "target_id"
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+toCol+", relation, provenance, confidence FROM edges WHERE "+fromCol+" = ? AND relation IN (?, ?) ORDER BY "+toCol,
+		id, RelationExtends, RelationImplements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query type hierarchy: %w", err)
+	}
+	defer rows.Close()
+
+	var links []hierarchyLink
+	for rows.Next() {
+		var l hierarchyLink
+		if err := rows.Scan(&l.other, &l.relation, &l.provenance, &l.confidence); err != nil {
+			return nil, err
+		}
+		if l.other != id {
+			links = append(links, l)
+		}
+	}
+	return links, rows.Err()
+}
+
+// TypeHierarchy returns the type hierarchy around each definition of a
+// symbol, given as a bare or qualified name: its supertypes up to the roots
+// and its subtypes down to the leaves, at most maxDepth levels each way
+// (0 means no limit). A type reached twice on one branch is not expanded
+// again.
+func (s *Store) TypeHierarchy(ctx context.Context, symbol string, maxDepth int) ([]*TypeNode, error) {
+	if maxDepth <= 0 || maxDepth > maxHierarchyDepth {
+		maxDepth = maxHierarchyDepth
+	}
+	defs, err := s.GetSymbolLocation(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	var expand func(t *TypeNode, id string, dir Direction, depth int, onPath map[string]bool) error
+	expand = func(t *TypeNode, id string, dir Direction, depth int, onPath map[string]bool) error {
+		if depth >= maxDepth {
+			return nil
+		}
+		links, err := s.hierarchyLinks(ctx, id, dir)
+		if err != nil {
+			return err
+		}
+		ids := make([]string, len(links))
+		for i, l := range links {
+			ids[i] = l.other
+		}
+		nodes, err := s.nodesByID(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, l := range links {
+			n := nodes[l.other]
+			if n == nil || onPath[n.ID] {
+				continue
+			}
+			child := newTypeNode(n)
+			child.Relation, child.Provenance, child.Confidence = l.relation, l.provenance, l.confidence
+			onPath[n.ID] = true
+			if err := expand(child, n.ID, dir, depth+1, onPath); err != nil {
+				return err
+			}
+			delete(onPath, n.ID)
+			if dir == Dependencies {
+				t.Supertypes = append(t.Supertypes, child)
+			} else {
+				t.Subtypes = append(t.Subtypes, child)
+			}
+		}
+		return nil
+	}
+
+	var roots []*TypeNode
+	for _, def := range defs {
+		if def.Kind == KindFile {
+			continue
+		}
+		root := newTypeNode(def)
+		for _, dir := range []Direction{Dependencies, Dependents} {
+			if err := expand(root, def.ID, dir, 0, map[string]bool{def.ID: true}); err != nil {
+				return nil, err
+			}
+		}
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
+
+func newTypeNode(n *Node) *TypeNode {
+	return &TypeNode{Name: n.Name, QualifiedName: n.QualifiedName, Kind: n.Kind, SymbolKind: n.SymbolKind, FilePath: n.FilePath, Line: n.LineStart, Signature: n.Signature, Doc: n.Doc}
+}
+
+// OverrideEdges returns overrides edges from the methods among nodes, and
+// those of the types among nodes, to the nearest method of the same name in
+// their supertypes, following the stored extends and implements edges, so it
+// must run after those are stored. The type of a method among nodes is read
+// from the store if it is not among nodes itself, as for a Go method edited
+// apart from its receiver type. An override found through heuristic edges is
+// heuristic, with the product of their confidences.
+func (s *Store) OverrideEdges(ctx context.Context, nodes []*Node) ([]*Edge, error) {
+	types := make(map[string]*Node)
+	for _, n := range nodes {
+		if isTypeKind(n.SymbolKind) {
+			types[n.ID] = n
+		}
+	}
+	var parents []string
+	seen := make(map[string]bool)
+	for _, n := range nodes {
+		if isTypeKind(n.SymbolKind) || n.SymbolKind == SymbolField || n.ParentID == "" || types[n.ParentID] != nil || seen[n.ParentID] {
+			continue
+		}
+		seen[n.ParentID] = true
+		parents = append(parents, n.ParentID)
+	}
+	stored, err := s.nodesByID(ctx, parents)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range parents {
+		if t := stored[id]; t != nil && isTypeKind(t.SymbolKind) {
+			types[id] = t
+		}
+	}
+
+	methods := make(map[*Node][]*Node)
+	var order []*Node
+	for _, n := range nodes {
+		if isTypeKind(n.SymbolKind) || n.SymbolKind == SymbolField {
+			continue
+		}
+		if t := types[n.ParentID]; t != nil {
+			if methods[t] == nil {
+				order = append(order, t)
+			}
+			methods[t] = append(methods[t], n)
+		}
+	}
+
+	var edges []*Edge
+	for _, t := range order {
+		pending := make(map[string]*Node)
+		for _, m := range methods[t] {
+			pending[m.Name] = m
+		}
+
+		type ancestor struct {
+			id         string
+			provenance string
+			confidence float64
+		}
+		frontier := []ancestor{{t.ID, ProvenanceLSP, 1}}
+		visited := map[string]bool{t.ID: true}
+		for depth := 0; depth < maxHierarchyDepth && len(frontier) > 0 && len(pending) > 0; depth++ {
+			var next []ancestor
+			for _, a := range frontier {
+				links, err := s.hierarchyLinks(ctx, a.id, Dependencies)
+				if err != nil {
+					return nil, err
+				}
+				for _, l := range links {
+					if visited[l.other] {
+						continue
+					}
+					visited[l.other] = true
+					sup := ancestor{l.other, a.provenance, a.confidence * l.confidence}
+					if l.provenance != ProvenanceLSP {
+						sup.provenance = ProvenanceHeuristic
+					}
+					next = append(next, sup)
+				}
+			}
+
+			ids := make([]string, len(next))
+			for i, a := range next {
+				ids[i] = a.id
+			}
+			supers, err := s.nodesByID(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range next {
+				sup := supers[a.id]
+				if sup == nil {
+					continue
+				}
+				members, err := s.Members(ctx, sup)
+				if err != nil {
+					return nil, err
+				}
+				for _, base := range members {
+					m := pending[base.Name]
+					if m == nil {
+						continue
+					}
+					delete(pending, base.Name)
+					edges = append(edges, &Edge{
+						SourceID:   m.ID,
+						TargetID:   base.ID,
+						Relation:   RelationOverrides,
+						Line:       m.LineStart,
+						Col:        m.ColStart,
+						Provenance: a.provenance,
+						Confidence: a.confidence,
+					})
+				}
+			}
+			frontier = next
+		}
+	}
+	return edges, nil
+}
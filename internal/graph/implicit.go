@@ -0,0 +1,116 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// goImplementsEdges returns heuristic implements edges between the Go types
+// and interfaces that nodes concern: a struct or other named type
+// implements an interface when it declares a method of each name the
+// interface has, as Go types satisfy interfaces without naming them. The
+// types among nodes, and those of the methods among nodes, are matched to
+// every interface in the workspace, and the interfaces among nodes, and
+// those of the interface methods among nodes, to every type.
+func (s *Store) goImplementsEdges(ctx context.Context, nodes []*Node, r *heuristicResolver) ([]*Edge, error) {
+	var relevant bool
+	for _, n := range nodes {
+		if languageFamily(n.FilePath) == "go" && n.Kind != KindFile {
+			relevant = true
+			break
+		}
+	}
+	if !relevant {
+		return nil, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+nodeColumns+" FROM nodes WHERE file_path LIKE '%.go' AND (symbol_kind IN (?, ?, ?) OR kind IN ('method_declaration', 'method_elem'))",
+		SymbolInterface, SymbolStruct, SymbolClass)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Go types: %w", err)
+	}
+	stored, err := scanNodes(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// A method belongs to the type of its qualified name in its package,
+	// which may be declared in another file.
+	typeKey := func(dir, qualified string) string { return dir + "\x00" + qualified }
+	receiver := func(m *Node) string {
+		return typeKey(filepath.Dir(m.FilePath), strings.TrimSuffix(m.QualifiedName, "."+m.Name))
+	}
+	var types, ifaces []*Node
+	typesByKey := make(map[string]*Node)
+	methodSets := make(map[string]map[string]bool) // by type key
+	ifaceMethods := make(map[string][]string)      // by interface ID
+	for _, n := range stored {
+		switch {
+		case n.Kind == "method_declaration":
+			key := receiver(n)
+			if methodSets[key] == nil {
+				methodSets[key] = make(map[string]bool)
+			}
+			methodSets[key][n.Name] = true
+		case n.Kind == "method_elem":
+			ifaceMethods[n.ParentID] = append(ifaceMethods[n.ParentID], n.Name)
+		case n.SymbolKind == SymbolInterface:
+			ifaces = append(ifaces, n)
+		default:
+			types = append(types, n)
+			typesByKey[typeKey(filepath.Dir(n.FilePath), n.QualifiedName)] = n
+		}
+	}
+
+	checkType, checkIface := make(map[string]bool), make(map[string]bool)
+	for _, n := range nodes {
+		if languageFamily(n.FilePath) != "go" {
+			continue
+		}
+		switch {
+		case n.Kind == "method_declaration":
+			if t := typesByKey[receiver(n)]; t != nil {
+				checkType[t.ID] = true
+			}
+		case n.Kind == "method_elem":
+			checkIface[n.ParentID] = true
+		case n.SymbolKind == SymbolInterface:
+			checkIface[n.ID] = true
+		case n.SymbolKind == SymbolStruct || n.SymbolKind == SymbolClass:
+			checkType[n.ID] = true
+		}
+	}
+
+	var edges []*Edge
+	for _, t := range types {
+		methods := methodSets[typeKey(filepath.Dir(t.FilePath), t.QualifiedName)]
+		if len(methods) == 0 {
+			continue
+		}
+		for _, iface := range ifaces {
+			names := ifaceMethods[iface.ID]
+			if len(names) == 0 || !(checkType[t.ID] || checkIface[iface.ID]) {
+				continue
+			}
+			implements := true
+			for _, name := range names {
+				implements = implements && methods[name]
+			}
+			if implements {
+				edges = append(edges, &Edge{
+					SourceID:   t.ID,
+					TargetID:   iface.ID,
+					Relation:   RelationImplements,
+					Line:       t.LineStart,
+					Col:        t.ColStart,
+					Provenance: ProvenanceHeuristic,
+					Confidence: r.tier(t, iface),
+				})
+			}
+		}
+	}
+	return edges, nil
+}
@@ -0,0 +1,113 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// IntegrityReport lists inconsistencies found in the stored graph.
+type IntegrityReport struct {
+	OrphanedEdges    []*Edge           `json:"orphaned_edges"`
+	DuplicateSymbols []DuplicateSymbol `json:"duplicate_symbols"`
+	MissingFiles     []string          `json:"missing_files"`
+}
+
+// DuplicateSymbol is a qualified name stored more than once for the same file.
+type DuplicateSymbol struct {
+	FilePath      string   `json:"file_path"`
+	QualifiedName string   `json:"qualified_name"`
+	NodeIDs       []string `json:"node_ids"`
+}
+
+// OK reports whether no problems were found.
+func (r *IntegrityReport) OK() bool {
+	return len(r.OrphanedEdges) == 0 && len(r.DuplicateSymbols) == 0 && len(r.MissingFiles) == 0
+}
+
+// CheckIntegrity reports edges pointing at missing nodes, symbols stored under
+// several IDs, and indexed files that no longer exist on disk.
+func (s *Store) CheckIntegrity(ctx context.Context) (*IntegrityReport, error) {
+	report := &IntegrityReport{}
+
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT source_id, target_id, relation
+	FROM edges
+	WHERE source_id NOT IN (SELECT id FROM nodes)
+	   OR target_id NOT IN (SELECT id FROM nodes)
+	ORDER BY source_id, target_id;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orphaned edges: %w", err)
+	}
+	for rows.Next() {
+		e := &Edge{}
+		if err := rows.Scan(&e.SourceID, &e.TargetID, &e.Relation); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.OrphanedEdges = append(report.OrphanedEdges, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+	SELECT file_path, qualified_name, id
+	FROM nodes
+	WHERE (file_path, qualified_name) IN (
+		SELECT file_path, qualified_name
+		FROM nodes
+		GROUP BY file_path, qualified_name
+		HAVING COUNT(*) > 1
+	)
+	ORDER BY file_path, qualified_name, id;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate symbols: %w", err)
+	}
+	for rows.Next() {
+		var path, qualified, id string
+		if err := rows.Scan(&path, &qualified, &id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		last := len(report.DuplicateSymbols) - 1
+		if last < 0 || report.DuplicateSymbols[last].FilePath != path || report.DuplicateSymbols[last].QualifiedName != qualified {
+			report.DuplicateSymbols = append(report.DuplicateSymbols, DuplicateSymbol{FilePath: path, QualifiedName: qualified})
+			last++
+		}
+		report.DuplicateSymbols[last].NodeIDs = append(report.DuplicateSymbols[last].NodeIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, "SELECT DISTINCT file_path FROM nodes ORDER BY file_path")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexed files: %w", err)
+	}
+	var files []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		files = append(files, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range files {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			report.MissingFiles = append(report.MissingFiles, p)
+		}
+	}
+
+	return report, nil
+}
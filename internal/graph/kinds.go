@@ -0,0 +1,86 @@
+package graph
+
+import "fmt"
+
+// Symbol kinds classify nodes the same way in every language, using the
+// names of the LSP SymbolKind values. Node.Kind keeps the grammar's own node
+// type, which differs between languages for the same construct.
+const (
+	SymbolFunction  = "function"
+	SymbolMethod    = "method"
+	SymbolClass     = "class"
+	SymbolInterface = "interface"
+	SymbolStruct    = "struct"
+	SymbolEnum      = "enum"
+	SymbolConstant  = "constant"
+	SymbolVariable  = "variable"
+	SymbolField     = "field"
+	SymbolModule    = "module"
+)
+
+// SymbolKinds lists every symbol kind.
+var SymbolKinds = []string{
+	SymbolFunction, SymbolMethod, SymbolClass, SymbolInterface, SymbolStruct,
+	SymbolEnum, SymbolConstant, SymbolVariable, SymbolField, SymbolModule,
+}
+
+// SymbolKindOf returns the symbol kind a node kind denotes when nothing else
+// is known about the node. Named types that are neither structs nor
+// interfaces, such as Go's type ID string and TypeScript type aliases, are
+// classes, as gopls and tsserver report them. Unknown node kinds have no
+// symbol kind.
+func SymbolKindOf(kind string) string {
+	switch kind {
+	case "function_declaration", "function_definition", "generator_function_declaration":
+		return SymbolFunction
+	case "method_declaration", "method_definition", "method_elem", "method_signature":
+		return SymbolMethod
+	case "class_declaration", "class_definition", "abstract_class_declaration", "class",
+		"type_declaration", "type_spec", "type_alias_declaration":
+		return SymbolClass
+	case "interface_declaration":
+		return SymbolInterface
+	case "enum_declaration":
+		return SymbolEnum
+	case "const_spec":
+		return SymbolConstant
+	case "variable_declarator", "variable_declaration", "assignment_statement":
+		return SymbolVariable
+	case "field_definition", "public_field_definition", "field_declaration", "property_signature":
+		return SymbolField
+	case KindFile:
+		return SymbolModule
+	}
+	return ""
+}
+
+// MatchesKinds reports whether a node has one of the given kinds, each of
+// which may be a symbol kind (class) or a node kind (class_declaration). An
+// empty list matches every node.
+func MatchesKinds(n *Node, kinds []string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+	for _, k := range kinds {
+		if k == n.SymbolKind || k == n.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// kindFilter returns an SQL condition, starting with " AND ", restricting the
+// nodes aliased as alias to the given symbol or node kinds, and its
+// arguments. An empty list yields no condition.
+func kindFilter(alias string, kinds []string) (string, []interface{}) {
+	if len(kinds) == 0 {
+		return "", nil
+	}
+	ph := placeholders(len(kinds))
+	args := make([]interface{}, 0, 2*len(kinds))
+	for _, k := range kinds {
+		args = append(args, k)
+	}
+	args = append(args, args...)
+	return fmt.Sprintf(" AND (%[1]s.symbol_kind IN (%[2]s) OR %[1]s.kind IN (%[2]s))", alias, ph), args
+}
@@ -0,0 +1,95 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// ContainsEdges returns a contains edge from the parent of each node to the
+// node, as recorded by the scanner.
+func ContainsEdges(nodes []*Node) []*Edge {
+	var edges []*Edge
+	for _, n := range nodes {
+		if n.ParentID == "" {
+			continue
+		}
+		edges = append(edges, &Edge{
+			SourceID:   n.ParentID,
+			TargetID:   n.ID,
+			Relation:   RelationContains,
+			Line:       n.LineStart,
+			Provenance: ProvenanceHeuristic,
+			Confidence: 1,
+		})
+	}
+	return edges
+}
+
+// Members returns the definitions directly inside a class, struct or other
+// container, in file and line order: the nodes whose parent it is and, for a
+// Go type, the methods declared on it in the other files of its package.
+func (s *Store) Members(ctx context.Context, t *Node) ([]*Node, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+nodeColumns+" FROM nodes WHERE parent_id = ? OR (kind = 'method_declaration' AND qualified_name LIKE ? ESCAPE '\\') ORDER BY file_path, line_start",
+		t.ID, escapeLike(t.QualifiedName+".")+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members of %s: %w", t.QualifiedName, err)
+	}
+	nodes, err := scanNodes(rows)
+	if err != nil {
+		return nil, err
+	}
+	var members []*Node
+	for _, n := range nodes {
+		if n.ParentID == t.ID || filepath.Dir(n.FilePath) == filepath.Dir(t.FilePath) {
+			members = append(members, n)
+		}
+	}
+	return members, nil
+}
+
+// OutlineNode is a definition with the definitions nested inside it.
+type OutlineNode struct {
+	*Node
+	Children []*OutlineNode
+}
+
+// Outline nests the definitions of a file under their parents, keeping their
+// order. Only nodes accepted by keep (all if nil) are returned; one whose
+// parent was left out is attached to its nearest kept ancestor instead, or
+// becomes a root.
+func Outline(nodes []*Node, keep func(*Node) bool) []*OutlineNode {
+	byID := make(map[string]*Node, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+	kept := make(map[string]*OutlineNode)
+	for _, n := range nodes {
+		if keep == nil || keep(n) {
+			kept[n.ID] = &OutlineNode{Node: n}
+		}
+	}
+
+	var roots []*OutlineNode
+	for _, n := range nodes {
+		o := kept[n.ID]
+		if o == nil {
+			continue
+		}
+		var parent *OutlineNode
+		seen := map[string]bool{n.ID: true}
+		for p := byID[n.ParentID]; p != nil && !seen[p.ID]; p = byID[p.ParentID] {
+			seen[p.ID] = true
+			if parent = kept[p.ID]; parent != nil {
+				break
+			}
+		}
+		if parent != nil {
+			parent.Children = append(parent.Children, o)
+		} else {
+			roots = append(roots, o)
+		}
+	}
+	return roots
+}
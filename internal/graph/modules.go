@@ -0,0 +1,149 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ImportEdges returns the imports edges recorded on file nodes by the scanner.
+// They are resolved from paths on disk, so they carry full confidence.
+func ImportEdges(nodes []*Node) []*Edge {
+	var edges []*Edge
+	for _, n := range nodes {
+		for _, imp := range n.Imports {
+			edges = append(edges, &Edge{
+				SourceID:   n.ID,
+				TargetID:   imp.TargetID,
+				Relation:   RelationImports,
+				Line:       imp.Line,
+				Col:        imp.Col,
+				Provenance: ProvenanceHeuristic,
+				Confidence: 1,
+			})
+		}
+	}
+	return edges
+}
+
+// ModuleGraphOptions filters the module graph. Relations defaults to imports;
+// Dir keeps only packages inside that directory.
+type ModuleGraphOptions struct {
+	Relations []string
+	Dir       string
+}
+
+// ModulePackage is a directory of indexed files with the packages it depends
+// on and is depended on by. FanOut and FanIn count those packages.
+type ModulePackage struct {
+	Package   string   `json:"package"`
+	Files     int      `json:"files"`
+	FanOut    int      `json:"fan_out"`
+	FanIn     int      `json:"fan_in"`
+	DependsOn []string `json:"depends_on,omitempty"`
+	UsedBy    []string `json:"used_by,omitempty"`
+}
+
+// ModuleDependency is a dependency between two packages. Edges counts the
+// file- or symbol-level edges behind it and Via names one of them.
+type ModuleDependency struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Edges int    `json:"edges"`
+	Via   string `json:"via"`
+}
+
+// ModuleGraph is the graph of packages and the dependencies between them.
+type ModuleGraph struct {
+	Packages     []ModulePackage    `json:"packages"`
+	Dependencies []ModuleDependency `json:"dependencies"`
+}
+
+// ModuleGraph collapses files into their directories and returns which
+// packages depend on which, with fan-in and fan-out counts. Packages are
+// ordered by path and dependencies by source then target.
+func (s *Store) ModuleGraph(ctx context.Context, opts ModuleGraphOptions) (*ModuleGraph, error) {
+	relations := opts.Relations
+	if len(relations) == 0 {
+		relations = []string{RelationImports}
+	}
+	dir := opts.Dir
+	if dir != "" {
+		dir = filepath.Clean(dir)
+	}
+	inDir := func(pkg string) bool {
+		return dir == "" || pkg == dir || strings.HasPrefix(pkg, dir+string(filepath.Separator))
+	}
+
+	files := make(map[string]int)
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT file_path FROM nodes")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files: %w", err)
+	}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if pkg := filepath.Dir(p); inDir(pkg) {
+			files[pkg]++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	edgeRows, err := s.loadCycleEdges(ctx, relations)
+	if err != nil {
+		return nil, err
+	}
+	deps := make(map[[2]string]*ModuleDependency)
+	for _, r := range edgeRows {
+		from, to := filepath.Dir(r.sourcePath), filepath.Dir(r.targetPath)
+		if from == to || !inDir(from) || !inDir(to) {
+			continue
+		}
+		key := [2]string{from, to}
+		d := deps[key]
+		if d == nil {
+			d = &ModuleDependency{From: from, To: to, Via: r.sourceName + " -> " + r.targetName}
+			deps[key] = d
+		}
+		d.Edges++
+	}
+
+	g := &ModuleGraph{Packages: []ModulePackage{}, Dependencies: []ModuleDependency{}}
+	dependsOn := make(map[string][]string)
+	usedBy := make(map[string][]string)
+	for _, d := range deps {
+		g.Dependencies = append(g.Dependencies, *d)
+	}
+	sort.Slice(g.Dependencies, func(i, j int) bool {
+		a, b := g.Dependencies[i], g.Dependencies[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	for _, d := range g.Dependencies {
+		dependsOn[d.From] = append(dependsOn[d.From], d.To)
+		usedBy[d.To] = append(usedBy[d.To], d.From)
+	}
+
+	for pkg, n := range files {
+		g.Packages = append(g.Packages, ModulePackage{
+			Package:   pkg,
+			Files:     n,
+			FanOut:    len(dependsOn[pkg]),
+			FanIn:     len(usedBy[pkg]),
+			DependsOn: dependsOn[pkg],
+			UsedBy:    usedBy[pkg],
+		})
+	}
+	sort.Slice(g.Packages, func(i, j int) bool { return g.Packages[i].Package < g.Packages[j].Package })
+	return g, nil
+}
@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultMaxPathLength bounds FindPaths when PathOptions.MaxLength is zero.
+const DefaultMaxPathLength = 10
+
+// PathOptions bounds a path search. Zero values mean DefaultMaxPathLength,
+// all relations but contains and a single (shortest) path.
+type PathOptions struct {
+	MaxLength int
+	Relations []string
+	MaxPaths  int
+}
+
+// FindPaths returns dependency chains from one symbol to another, following
+// edges from source to target (A calls B calls C). Paths are simple, ordered
+// by length, and distinct in the sequence of symbols they visit. Every step
+// carries the file and line of its symbol.
+func (s *Store) FindPaths(ctx context.Context, fromSymbol, toSymbol string, opts PathOptions) ([][]PathStep, error) {
+	if opts.MaxLength <= 0 {
+		opts.MaxLength = DefaultMaxPathLength
+	}
+	if opts.MaxPaths <= 0 {
+		opts.MaxPaths = 1
+	}
+
+	from, err := s.GetSymbolLocation(ctx, fromSymbol)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.GetSymbolLocation(ctx, toSymbol)
+	if err != nil {
+		return nil, err
+	}
+	if len(from) == 0 || len(to) == 0 {
+		return nil, nil
+	}
+
+	targets := make(map[string]bool, len(to))
+	for _, n := range to {
+		targets[n.ID] = true
+	}
+
+	// Walk backwards from the targets to learn each node's distance to the
+	// nearest target. Every edge usable by a path within MaxLength is seen
+	// along the way, which gives the forward adjacency for the search below.
+	distTo := make(map[string]int)
+	var frontier []string
+	for id := range targets {
+		distTo[id] = 0
+		frontier = append(frontier, id)
+	}
+	type hop struct {
+		to, relation string
+	}
+	adj := make(map[string][]hop)
+	for depth := 1; depth <= opts.MaxLength && len(frontier) > 0; depth++ {
+		edges, err := s.neighbours(ctx, frontier, Dependents, opts.Relations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search paths: %w", err)
+		}
+		var next []string
+		for _, e := range edges {
+			// e.from is the edge target, e.to its source.
+			hops := adj[e.to]
+			if len(hops) == 0 || hops[len(hops)-1].to != e.from {
+				adj[e.to] = append(hops, hop{to: e.from, relation: e.relation})
+			}
+			if _, ok := distTo[e.to]; !ok {
+				distTo[e.to] = depth
+				next = append(next, e.to)
+			}
+		}
+		frontier = next
+	}
+
+	// Enumerate simple paths of increasing length. A node is only entered if
+	// a target is still reachable from it within the remaining budget.
+	var found [][]string
+	onPath := make(map[string]bool)
+	var walk func(path []string, remaining int)
+	walk = func(path []string, remaining int) {
+		if len(found) >= opts.MaxPaths {
+			return
+		}
+		cur := path[len(path)-1]
+		if targets[cur] && len(path) > 1 {
+			if remaining == 0 {
+				found = append(found, append([]string(nil), path...))
+			}
+			return
+		}
+		if remaining == 0 {
+			return
+		}
+		for _, h := range adj[cur] {
+			d, ok := distTo[h.to]
+			if !ok || d > remaining-1 || onPath[h.to] {
+				continue
+			}
+			onPath[h.to] = true
+			walk(append(path, h.to), remaining-1)
+			onPath[h.to] = false
+		}
+	}
+	for length := 1; length <= opts.MaxLength && len(found) < opts.MaxPaths; length++ {
+		for _, n := range from {
+			if d, ok := distTo[n.ID]; !ok || d > length {
+				continue
+			}
+			onPath[n.ID] = true
+			walk([]string{n.ID}, length)
+			onPath[n.ID] = false
+		}
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	var ids []string
+	for _, p := range found {
+		ids = append(ids, p...)
+	}
+	nodes, err := s.nodesByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	relationOf := func(from, to string) string {
+		for _, h := range adj[from] {
+			if h.to == to {
+				return h.relation
+			}
+		}
+		return ""
+	}
+
+	paths := make([][]PathStep, 0, len(found))
+	for _, p := range found {
+		steps := make([]PathStep, 0, len(p))
+		for i, id := range p {
+			n := nodes[id]
+			if n == nil {
+				continue
+			}
+			step := PathStep{
+				Name:          n.Name,
+				QualifiedName: n.QualifiedName,
+				FilePath:      n.FilePath,
+				Line:          n.LineStart,
+			}
+			if i > 0 {
+				step.Relation = relationOf(p[i-1], id)
+			}
+			steps = append(steps, step)
+		}
+		paths = append(paths, steps)
+	}
+	return paths, nil
+}
@@ -0,0 +1,381 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"codemap/util"
+)
+
+// DefaultSearchLimit bounds SearchSymbols when SearchOptions.Limit is zero.
+const DefaultSearchLimit = 20
+
+// Match types reported by SearchSymbols, best first.
+const (
+	MatchExact     = "exact"
+	MatchPrefix    = "prefix"
+	MatchWords     = "words"
+	MatchSubstring = "substring"
+	MatchFuzzy     = "fuzzy"
+)
+
+// languageExtensions maps the language names accepted by SearchOptions to
+// file extensions.
+var languageExtensions = map[string][]string{
+	"go":         {".go"},
+	"python":     {".py"},
+	"javascript": {".js", ".jsx"},
+	"typescript": {".ts", ".tsx"},
+	"lua":        {".lua"},
+	"zig":        {".zig"},
+}
+
+// SearchOptions filters a symbol search. Kinds are symbol kinds or node
+// kinds. PathGlob uses SQLite GLOB syntax, where * also matches path
+// separators.
+type SearchOptions struct {
+	Kinds    []string
+	Language string
+	PathGlob string
+	Limit    int
+}
+
+// SearchResult is a symbol matched by SearchSymbols. Score is between 0 and 1;
+// Match says which rule produced it.
+type SearchResult struct {
+	Node  *Node   `json:"node"`
+	Score float64 `json:"score"`
+	Match string  `json:"match"`
+}
+
+// SearchSymbols finds symbols whose names resemble the query, ignoring case.
+// It matches whole names, name prefixes, the words of camelCase and
+// snake_case identifiers ("req hand" finds HTTPRequestHandler), substrings,
+// and words within a small edit distance of the query's. Candidates come from
+// the nodes_fts index and are ranked by how closely they match.
+func (s *Store) SearchSymbols(ctx context.Context, query string, opts SearchOptions) ([]*SearchResult, error) {
+	query = strings.TrimSpace(query)
+	words := util.SplitIdentifier(query)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("search query %q has no letters or digits", query)
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultSearchLimit
+	}
+
+	filter, filterArgs, err := searchFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	// Ranking happens here, so fetch more candidates than requested; shorter
+	// names are preferred because they match the query more tightly.
+	candidateLimit := opts.Limit * 20
+	if candidateLimit < 500 {
+		candidateLimit = 500
+	}
+
+	vocab, err := s.searchVocabulary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, len(words))
+	for i, w := range words {
+		alternatives := append([]string{w + "*"}, similarTerms(w, vocab)...)
+		groups[i] = "(" + strings.Join(alternatives, " OR ") + ")"
+	}
+	args := append([]interface{}{strings.Join(groups, " AND ")}, filterArgs...)
+	args = append(args, candidateLimit)
+	candidates, err := s.queryNodes(ctx, `
+	SELECT `+prefixColumns("n")+`
+	FROM nodes_fts JOIN nodes n ON n.rowid = nodes_fts.rowid
+	WHERE nodes_fts MATCH ?`+filter+`
+	ORDER BY length(n.name), n.file_path, n.line_start
+	LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search symbols: %w", err)
+	}
+
+	// Substrings inside a word ("quest" in HTTPRequestHandler) are invisible
+	// to the word index.
+	lowered := strings.ToLower(query)
+	if len(lowered) >= 3 {
+		args := append([]interface{}{lowered}, filterArgs...)
+		args = append(args, candidateLimit)
+		more, err := s.queryNodes(ctx, `
+		SELECT `+prefixColumns("n")+`
+		FROM nodes n
+		WHERE instr(lower(n.name), ?) > 0`+filter+`
+		ORDER BY length(n.name), n.file_path, n.line_start
+		LIMIT ?`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search symbols: %w", err)
+		}
+		candidates = append(candidates, more...)
+	}
+
+	seen := make(map[string]bool, len(candidates))
+	var results []*SearchResult
+	for _, n := range candidates {
+		if seen[n.ID] {
+			continue
+		}
+		seen[n.ID] = true
+		if score, match := scoreSymbol(n, query, words); score > 0 {
+			results = append(results, &SearchResult{Node: n, Score: score, Match: match})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.Node.Name) != len(b.Node.Name) {
+			return len(a.Node.Name) < len(b.Node.Name)
+		}
+		if a.Node.FilePath != b.Node.FilePath {
+			return a.Node.FilePath < b.Node.FilePath
+		}
+		return a.Node.LineStart < b.Node.LineStart
+	})
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// searchFilter returns the SQL conditions (each starting with " AND") and
+// arguments for the kind, language and path filters on alias n.
+func searchFilter(opts SearchOptions) (string, []interface{}, error) {
+	var sb strings.Builder
+	var args []interface{}
+	if cond, kindArgs := kindFilter("n", opts.Kinds); cond != "" {
+		sb.WriteString(cond)
+		args = append(args, kindArgs...)
+	}
+	if opts.Language != "" {
+		exts, ok := languageExtensions[strings.ToLower(opts.Language)]
+		if !ok {
+			var valid []string
+			for lang := range languageExtensions {
+				valid = append(valid, lang)
+			}
+			sort.Strings(valid)
+			return "", nil, fmt.Errorf("unknown language %q (valid: %s)", opts.Language, strings.Join(valid, ", "))
+		}
+		conds := make([]string, len(exts))
+		for i, ext := range exts {
+			conds[i] = "n.file_path LIKE ?"
+			args = append(args, "%"+ext)
+		}
+		sb.WriteString(" AND (" + strings.Join(conds, " OR ") + ")")
+	}
+	if opts.PathGlob != "" {
+		sb.WriteString(" AND n.file_path GLOB ?")
+		args = append(args, opts.PathGlob)
+	}
+	return sb.String(), args, nil
+}
+
+// searchVocabulary returns every term in the search index.
+func (s *Store) searchVocabulary(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT term FROM nodes_fts_vocab")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search vocabulary: %w", err)
+	}
+	defer rows.Close()
+
+	var terms []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	return terms, rows.Err()
+}
+
+func (s *Store) queryNodes(ctx context.Context, query string, args ...interface{}) ([]*Node, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanNodes(rows)
+}
+
+// prefixColumns returns nodeColumns qualified with a table alias.
+func prefixColumns(alias string) string {
+	cols := strings.Split(nodeColumns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+	return strings.Join(cols, ", ")
+}
+
+// maxTypos is the number of edits tolerated in a word of the given length.
+// Short words are matched exactly; anything else drowns in noise.
+func maxTypos(n int) int {
+	switch {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// maxSimilarTerms caps the typo alternatives added per query word.
+const maxSimilarTerms = 20
+
+// similarTerms returns index terms within typo distance of word, either as a
+// whole or in their first len(word) characters, closest first.
+func similarTerms(word string, vocab []string) []string {
+	limit := maxTypos(len(word))
+	if limit == 0 {
+		return nil
+	}
+	type candidate struct {
+		term  string
+		edits int
+	}
+	var found []candidate
+	for _, t := range vocab {
+		if strings.HasPrefix(t, word) {
+			continue // already covered by the prefix query
+		}
+		if d := typoDistance(word, t); d <= limit {
+			found = append(found, candidate{t, d})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].edits < found[j].edits })
+	if len(found) > maxSimilarTerms {
+		found = found[:maxSimilarTerms]
+	}
+	terms := make([]string, len(found))
+	for i, c := range found {
+		terms[i] = c.term
+	}
+	return terms
+}
+
+// typoDistance is the edit distance between word and term, or between word
+// and term's leading characters when term is longer, so that partially typed
+// words still match.
+func typoDistance(word, term string) int {
+	d := editDistance(word, term)
+	if len(term) > len(word) {
+		if p := editDistance(word, term[:len(word)]); p < d {
+			d = p
+		}
+	}
+	return d
+}
+
+// editDistance is the optimal string alignment distance: insertions,
+// deletions, substitutions and swaps of adjacent characters each count as one.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// scoreSymbol rates how well a symbol matches the query, from 1 for an exact
+// name down to 0 for no match. Within a match type, names the query covers
+// more completely score higher.
+func scoreSymbol(n *Node, query string, words []string) (float64, string) {
+	name := strings.ToLower(n.Name)
+	q := strings.ToLower(query)
+	coverage := func(base float64) float64 {
+		return base - 0.1*(1-float64(len(q))/float64(max(len(name), len(q))))
+	}
+
+	switch {
+	case n.Name == query || n.QualifiedName == query:
+		return 1, MatchExact
+	case name == q || strings.HasSuffix(strings.ToLower(n.QualifiedName), "."+q):
+		return 0.95, MatchExact
+	case strings.HasPrefix(name, q):
+		return coverage(0.85), MatchPrefix
+	}
+
+	nameWords := util.SplitIdentifier(n.Name)
+	if wordsMatch(words, nameWords) {
+		if strings.HasPrefix(nameWords[0], words[0]) {
+			return coverage(0.75), MatchWords
+		}
+		return coverage(0.7), MatchWords
+	}
+	if strings.Contains(name, q) {
+		return coverage(0.6), MatchSubstring
+	}
+	if edits, ok := fuzzyMatch(words, nameWords); ok {
+		return 0.5 - 0.1*float64(edits), MatchFuzzy
+	}
+	// The query may name the container as well (server run -> Server.Run).
+	qualifiedWords := util.SplitIdentifier(n.QualifiedName)
+	if wordsMatch(words, qualifiedWords) {
+		return 0.45, MatchWords
+	}
+	if edits, ok := fuzzyMatch(words, qualifiedWords); ok {
+		return 0.35 - 0.1*float64(edits), MatchFuzzy
+	}
+	return 0, ""
+}
+
+// wordsMatch reports whether every query word is a prefix of a distinct
+// target word, in order.
+func wordsMatch(query, target []string) bool {
+	j := 0
+	for _, w := range query {
+		for j < len(target) && !strings.HasPrefix(target[j], w) {
+			j++
+		}
+		if j == len(target) {
+			return false
+		}
+		j++
+	}
+	return true
+}
+
+// fuzzyMatch matches each query word to its closest target word, tolerating
+// maxTypos edits per word, and returns the total number of edits.
+func fuzzyMatch(query, target []string) (int, bool) {
+	total := 0
+	for _, w := range query {
+		best := -1
+		for _, t := range target {
+			if d := typoDistance(w, t); d <= maxTypos(len(w)) && (best < 0 || d < best) {
+				best = d
+			}
+		}
+		if best < 0 {
+			return 0, false
+		}
+		total += best
+	}
+	return total, true
+}
@@ -0,0 +1,195 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"codemap/internal/db"
+)
+
+// maxSnippetLength caps the source line stored with a site, in runes.
+const maxSnippetLength = 200
+
+// edgeKey identifies a stored edge.
+type edgeKey struct {
+	source, target, relation string
+}
+
+// sitesOf returns where each edge was found, aligned with edges. Edges
+// without a line get nil, as do contains edges, which mark where a member is
+// defined rather than a use of it.
+func (s *Store) sitesOf(ctx context.Context, edges []*Edge) ([]*Site, error) {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, e := range edges {
+		if e.Line > 0 && e.Relation != RelationContains && !seen[e.SourceID] {
+			seen[e.SourceID] = true
+			ids = append(ids, e.SourceID)
+		}
+	}
+	sources, err := s.nodesByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]string)
+	sites := make([]*Site, len(edges))
+	for i, e := range edges {
+		src := sources[e.SourceID]
+		if src == nil || e.Line <= 0 || e.Relation == RelationContains {
+			continue
+		}
+		lines, ok := files[src.FilePath]
+		if !ok {
+			lines = readLines(src.FilePath)
+			files[src.FilePath] = lines
+		}
+		sites[i] = &Site{FilePath: src.FilePath, Line: e.Line, Col: e.Col, Snippet: snippet(lines, e.Line)}
+	}
+	return sites, nil
+}
+
+// snippet returns a line of a file, trimmed and capped at maxSnippetLength.
+func snippet(lines []string, line int) string {
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	text := strings.TrimSpace(lines[line-1])
+	if utf8.RuneCountInString(text) > maxSnippetLength {
+		text = string([]rune(text)[:maxSnippetLength]) + "..."
+	}
+	return text
+}
+
+// addSite records a use of an edge that is already stored. Like upsertEdge,
+// it skips the site if the edge was not stored because an endpoint is gone.
+func (s *Store) addSite(ctx context.Context, execer db.Execer, e *Edge, site *Site) error {
+	_, err := execer.ExecContext(ctx, `
+	INSERT INTO edge_sites (source_id, target_id, relation, file_path, line, col, snippet)
+	SELECT ?, ?, ?, ?, ?, ?, ?
+	WHERE EXISTS (SELECT 1 FROM edges WHERE source_id = ? AND target_id = ? AND relation = ?)
+	ON CONFLICT(source_id, target_id, relation, line, col) DO UPDATE SET
+		file_path = excluded.file_path,
+		snippet = excluded.snippet;
+	`, e.SourceID, e.TargetID, e.Relation, site.FilePath, site.Line, site.Col, site.Snippet,
+		e.SourceID, e.TargetID, e.Relation)
+	if err != nil {
+		return fmt.Errorf("failed to store site of edge %s->%s: %w", e.SourceID, e.TargetID, err)
+	}
+	return nil
+}
+
+// edgeSites returns the uses of an edge in line order.
+func (s *Store) edgeSites(ctx context.Context, k edgeKey) ([]Site, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT file_path, line, col, snippet FROM edge_sites WHERE source_id = ? AND target_id = ? AND relation = ? ORDER BY line, col",
+		k.source, k.target, k.relation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query edge sites: %w", err)
+	}
+	defer rows.Close()
+
+	var sites []Site
+	for rows.Next() {
+		var site Site
+		if err := rows.Scan(&site.FilePath, &site.Line, &site.Col, &site.Snippet); err != nil {
+			return nil, err
+		}
+		sites = append(sites, site)
+	}
+	return sites, rows.Err()
+}
+
+// ReferenceOptions filters FindReferences. Zero values mean all relations but
+// contains, references from symbols of every kind and no limit.
+type ReferenceOptions struct {
+	Relations []string
+	Kinds     []string
+	Limit     int
+}
+
+// Reference is one use of a symbol: where it is, how the edge behind it was
+// found, the symbol it occurs in and the definition it refers to.
+type Reference struct {
+	Site
+	Relation   string
+	Provenance string
+	Confidence float64
+	From       *Node
+	To         *Node
+}
+
+// FindReferences returns every recorded use of the definitions of a symbol,
+// given as a bare or qualified name, ordered by file and position. Kinds
+// filters the symbols the uses occur in.
+func (s *Store) FindReferences(ctx context.Context, symbol string, opts ReferenceOptions) ([]*Reference, error) {
+	defs, err := s.GetSymbolLocation(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if len(defs) == 0 {
+		return nil, nil
+	}
+	targets := make(map[string]*Node, len(defs))
+	args := make([]interface{}, 0, len(defs))
+	for _, d := range defs {
+		targets[d.ID] = d
+		args = append(args, d.ID)
+	}
+
+	cond, relArgs := relationFilter("s.relation", opts.Relations)
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT s.source_id, s.target_id, s.relation, s.file_path, s.line, s.col, s.snippet, e.provenance, e.confidence
+	FROM edge_sites s
+	JOIN edges e ON e.source_id = s.source_id AND e.target_id = s.target_id AND e.relation = s.relation
+	WHERE s.target_id IN (`+placeholders(len(defs))+`) AND `+cond+`
+	ORDER BY s.file_path, s.line, s.col, s.relation`,
+		append(args, relArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query references to %s: %w", symbol, err)
+	}
+	type found struct {
+		ref            *Reference
+		source, target string
+	}
+	var all []found
+	var sourceIDs []string
+	seen := make(map[string]bool)
+	for rows.Next() {
+		f := found{ref: &Reference{}}
+		r := f.ref
+		if err := rows.Scan(&f.source, &f.target, &r.Relation, &r.FilePath, &r.Line, &r.Col, &r.Snippet, &r.Provenance, &r.Confidence); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		all = append(all, f)
+		if !seen[f.source] {
+			seen[f.source] = true
+			sourceIDs = append(sourceIDs, f.source)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sources, err := s.nodesByID(ctx, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	var refs []*Reference
+	for _, f := range all {
+		from := sources[f.source]
+		if from == nil || (len(opts.Kinds) > 0 && !MatchesKinds(from, opts.Kinds)) {
+			continue
+		}
+		f.ref.From, f.ref.To = from, targets[f.target]
+		refs = append(refs, f.ref)
+		if opts.Limit > 0 && len(refs) >= opts.Limit {
+			break
+		}
+	}
+	return refs, nil
+}
@@ -4,10 +4,72 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"codemap/internal/db"
+	"codemap/util"
 )
 
+// nodeColumns is the column list read by scanNode, in order.
+const nodeColumns = "id, name, qualified_name, kind, symbol_kind, file_path, line_start, line_end, col_start, col_end, symbol_uri, parent_id, signature, doc, hash"
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanNode(row rowScanner) (*Node, error) {
+	n := &Node{}
+	var uri sql.NullString
+	if err := row.Scan(&n.ID, &n.Name, &n.QualifiedName, &n.Kind, &n.SymbolKind, &n.FilePath, &n.LineStart, &n.LineEnd, &n.ColStart, &n.ColEnd, &uri, &n.ParentID, &n.Signature, &n.Doc, &n.Hash); err != nil {
+		return nil, err
+	}
+	n.SymbolURI = uri.String
+	return n, nil
+}
+
+func scanNodes(rows *sql.Rows) ([]*Node, error) {
+	defer rows.Close()
+
+	var nodes []*Node
+	for rows.Next() {
+		n, err := scanNode(rows)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, rows.Err()
+}
+
+// symbolFilter returns a WHERE fragment (for a table aliased as alias)
+// matching a symbol given either as a bare name or in qualified form. A
+// qualified symbol matches exact qualified names and any dot-separated suffix,
+// so "Server.Run" matches "server.Server.Run", including overloads ("#2").
+func symbolFilter(alias, symbol string) (string, []interface{}) {
+	col := func(name string) string {
+		if alias == "" {
+			return name
+		}
+		return alias + "." + name
+	}
+
+	if !strings.ContainsAny(symbol, ".:#/") {
+		return col("name") + " = ?", []interface{}{symbol}
+	}
+
+	esc := escapeLike(symbol)
+	clause := fmt.Sprintf(`(%[1]s = ? OR %[2]s = ? OR %[2]s LIKE ? ESCAPE '\' OR %[2]s LIKE ? ESCAPE '\' OR %[2]s LIKE ? ESCAPE '\')`,
+		col("name"), col("qualified_name"))
+	return clause, []interface{}{symbol, symbol, "%." + esc, "%." + esc + "#%", esc + "#%"}
+}
+
+// escapeLike escapes LIKE wildcards using backslash as the escape character.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return r.Replace(s)
+}
+
 type Store struct {
 	db *db.DB
 }
@@ -22,29 +84,98 @@ func (s *Store) UpsertNode(ctx context.Context, n *Node) error {
 
 func (s *Store) upsertNode(ctx context.Context, execer db.Execer, n *Node) error {
 	query := `
-	INSERT INTO nodes (id, name, kind, file_path, line_start, line_end, col_start, col_end, symbol_uri)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO nodes (id, name, qualified_name, kind, symbol_kind, file_path, line_start, line_end, col_start, col_end, symbol_uri, parent_id, signature, doc, hash, search_terms)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	ON CONFLICT(id) DO UPDATE SET
 		name = excluded.name,
+		qualified_name = excluded.qualified_name,
+		search_terms = excluded.search_terms,
 		kind = excluded.kind,
+		symbol_kind = excluded.symbol_kind,
 		file_path = excluded.file_path,
 		line_start = excluded.line_start,
 		line_end = excluded.line_end,
 		col_start = excluded.col_start,
 		col_end = excluded.col_end,
 		symbol_uri = excluded.symbol_uri,
+		parent_id = excluded.parent_id,
+		signature = excluded.signature,
+		doc = excluded.doc,
+		hash = excluded.hash,
 		created_at = CURRENT_TIMESTAMP;
 	`
 	_, err := execer.ExecContext(ctx, query,
-		n.ID, n.Name, n.Kind, n.FilePath,
-		n.LineStart, n.LineEnd, n.ColStart, n.ColEnd, n.SymbolURI,
+		n.ID, n.Name, n.QualifiedName, n.Kind, n.SymbolKind, n.FilePath,
+		n.LineStart, n.LineEnd, n.ColStart, n.ColEnd, n.SymbolURI, n.ParentID, n.Signature, n.Doc, n.Hash,
+		util.SearchTerms(n.Name, n.QualifiedName),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to upsert node %s: %w", n.ID, err)
 	}
+	// The uses inside the node may have moved; the edges found after the
+	// scan record them again.
+	if _, err := execer.ExecContext(ctx, "DELETE FROM edge_sites WHERE source_id = ?", n.ID); err != nil {
+		return fmt.Errorf("failed to clear edge sites of %s: %w", n.ID, err)
+	}
+	return s.replaceCallSites(ctx, execer, n)
+}
+
+// replaceCallSites stores the node's call sites in place of any from an
+// earlier scan.
+func (s *Store) replaceCallSites(ctx context.Context, execer db.Execer, n *Node) error {
+	if _, err := execer.ExecContext(ctx, "DELETE FROM call_sites WHERE caller_id = ?", n.ID); err != nil {
+		return fmt.Errorf("failed to clear call sites of %s: %w", n.ID, err)
+	}
+	for _, c := range n.Calls {
+		_, err := execer.ExecContext(ctx, `
+		INSERT INTO call_sites (file_path, line, col, caller_id, name, qualifier)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(file_path, line, col) DO UPDATE SET
+			caller_id = excluded.caller_id,
+			name = excluded.name,
+			qualifier = excluded.qualifier;
+		`, n.FilePath, c.Line, c.Col, n.ID, c.Name, c.Qualifier)
+		if err != nil {
+			return fmt.Errorf("failed to store call site of %s: %w", n.ID, err)
+		}
+	}
 	return nil
 }
 
+// IsCallSite reports whether a call expression names its callee at the given
+// 1-based position.
+func (s *Store) IsCallSite(ctx context.Context, path string, line, col int) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM call_sites WHERE file_path = ? AND line = ? AND col = ?",
+		path, line, col).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up call site at %s:%d: %w", path, line, err)
+	}
+	return n > 0, nil
+}
+
+// GetCallSites returns the call sites inside a definition, in source order.
+func (s *Store) GetCallSites(ctx context.Context, callerID string) ([]CallSite, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT name, qualifier, line, col FROM call_sites WHERE caller_id = ? ORDER BY line, col",
+		callerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query call sites: %w", err)
+	}
+	defer rows.Close()
+
+	var sites []CallSite
+	for rows.Next() {
+		var c CallSite
+		if err := rows.Scan(&c.Name, &c.Qualifier, &c.Line, &c.Col); err != nil {
+			return nil, err
+		}
+		sites = append(sites, c)
+	}
+	return sites, rows.Err()
+}
+
 func (s *Store) BulkUpsertNodes(ctx context.Context, nodes []*Node) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -61,123 +192,127 @@ func (s *Store) BulkUpsertNodes(ctx context.Context, nodes []*Node) error {
 	return tx.Commit()
 }
 
+// UpdateSignatures stores the signatures of nodes that are already stored,
+// such as those a language server refined after the scan.
+func (s *Store) UpdateSignatures(ctx context.Context, nodes []*Node) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, n := range nodes {
+		if n.Signature == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE nodes SET signature = ? WHERE id = ?", n.Signature, n.ID); err != nil {
+			return fmt.Errorf("failed to update signature of %s: %w", n.ID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (s *Store) UpsertEdge(ctx context.Context, e *Edge) error {
-	return s.upsertEdge(ctx, s.db, e)
+	return s.BulkUpsertEdges(ctx, []*Edge{e})
 }
 
+// upsertEdge stores an edge if both endpoints exist. Edges whose endpoints were
+// removed in the meantime (e.g. by the watcher) are skipped rather than
+// failing the whole batch on the foreign key constraint. When the same edge is
+// seen at several lines, the earliest is kept, and an LSP edge upgrades a
+// heuristic one but is never downgraded by it.
 func (s *Store) upsertEdge(ctx context.Context, execer db.Execer, e *Edge) error {
 	query := `
-	INSERT INTO edges (source_id, target_id, relation)
-	VALUES (?, ?, ?)
-	ON CONFLICT(source_id, target_id, relation) DO NOTHING;
+	INSERT INTO edges (source_id, target_id, relation, line, provenance, confidence)
+	SELECT ?, ?, ?, ?, ?, ?
+	WHERE EXISTS (SELECT 1 FROM nodes WHERE id = ?)
+	  AND EXISTS (SELECT 1 FROM nodes WHERE id = ?)
+	ON CONFLICT(source_id, target_id, relation) DO UPDATE SET
+		line = CASE
+			WHEN excluded.line > 0 AND (edges.line = 0 OR excluded.line < edges.line) THEN excluded.line
+			ELSE edges.line
+		END,
+		confidence = CASE
+			WHEN excluded.provenance = edges.provenance THEN MAX(edges.confidence, excluded.confidence)
+			WHEN excluded.provenance = 'lsp' THEN excluded.confidence
+			ELSE edges.confidence
+		END,
+		provenance = CASE WHEN excluded.provenance = 'lsp' THEN 'lsp' ELSE edges.provenance END;
 	`
-	_, err := execer.ExecContext(ctx, query, e.SourceID, e.TargetID, e.Relation)
+	provenance, confidence := e.Provenance, e.Confidence
+	if provenance == "" {
+		provenance = ProvenanceLSP
+	}
+	if confidence == 0 {
+		confidence = 1
+	}
+	_, err := execer.ExecContext(ctx, query, e.SourceID, e.TargetID, e.Relation, e.Line, provenance, confidence, e.SourceID, e.TargetID)
 	if err != nil {
 		return fmt.Errorf("failed to upsert edge %s->%s: %w", e.SourceID, e.TargetID, err)
 	}
 	return nil
 }
 
+// BulkUpsertEdges stores edges and the uses they were found at, with the
+// source line of each.
 func (s *Store) BulkUpsertEdges(ctx context.Context, edges []*Edge) error {
+	sites, err := s.sitesOf(ctx, edges)
+	if err != nil {
+		return err
+	}
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	for _, e := range edges {
+	for i, e := range edges {
 		if err := s.upsertEdge(ctx, tx, e); err != nil {
 			return err
 		}
-	}
-
-	return tx.Commit()
-}
-
-func (s *Store) FindImpact(ctx context.Context, symbolName string) ([]*Node, error) {
-	query := `
-	WITH RECURSIVE impacted AS (
-		-- Base case: Direct dependents (who calls/uses symbols with the given name)
-		SELECT source_id
-		FROM edges
-		WHERE target_id IN (SELECT id FROM nodes WHERE name = ?)
-		
-		UNION
-		
-		-- Recursive step: Dependents of dependents
-		SELECT e.source_id
-		FROM edges e
-		INNER JOIN impacted i ON e.target_id = i.source_id
-	)
-	SELECT DISTINCT n.id, n.name, n.kind, n.file_path, n.line_start, n.line_end, n.col_start, n.col_end, n.symbol_uri
-	FROM nodes n
-	JOIN impacted i ON n.id = i.source_id;
-	`
-
-	rows, err := s.db.QueryContext(ctx, query, symbolName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query impact for %s: %w", symbolName, err)
-	}
-	defer rows.Close()
-
-	var nodes []*Node
-	for rows.Next() {
-		n := &Node{}
-		if err := rows.Scan(&n.ID, &n.Name, &n.Kind, &n.FilePath, &n.LineStart, &n.LineEnd, &n.ColStart, &n.ColEnd, &n.SymbolURI); err != nil {
-			return nil, err
+		if sites[i] != nil {
+			if err := s.addSite(ctx, tx, e, sites[i]); err != nil {
+				return err
+			}
 		}
-		nodes = append(nodes, n)
 	}
 
-	return nodes, nil
+	return tx.Commit()
 }
 
+// GetSymbolLocation returns the definitions of a symbol given as a bare name
+// or in qualified form.
 func (s *Store) GetSymbolLocation(ctx context.Context, symbolName string) ([]*Node, error) {
+	filter, args := symbolFilter("", symbolName)
 	query := `
-	SELECT id, name, kind, file_path, line_start, line_end, col_start, col_end, symbol_uri
+	SELECT ` + nodeColumns + `
 	FROM nodes
-	WHERE name = ?
-	ORDER BY file_path;
+	WHERE ` + filter + `
+	ORDER BY file_path, line_start;
 	`
-	rows, err := s.db.QueryContext(ctx, query, symbolName)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query location for %s: %w", symbolName, err)
 	}
-	defer rows.Close()
-
-	var nodes []*Node
-	for rows.Next() {
-		n := &Node{}
-		if err := rows.Scan(&n.ID, &n.Name, &n.Kind, &n.FilePath, &n.LineStart, &n.LineEnd, &n.ColStart, &n.ColEnd, &n.SymbolURI); err != nil {
-			return nil, err
-		}
-		nodes = append(nodes, n)
-	}
-	return nodes, nil
+	return scanNodes(rows)
 }
 
+// GetSymbolsInFile returns the definitions in a file, excluding the node for
+// the file itself.
 func (s *Store) GetSymbolsInFile(ctx context.Context, filePath string) ([]*Node, error) {
 	query := `
-	SELECT id, name, kind, file_path, line_start, line_end, col_start, col_end, symbol_uri
+	SELECT ` + nodeColumns + `
 	FROM nodes
-	WHERE file_path = ?
+	WHERE file_path = ? AND kind != ?
 	ORDER BY line_start;
 	`
-	rows, err := s.db.QueryContext(ctx, query, filePath)
+	rows, err := s.db.QueryContext(ctx, query, filePath, KindFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query symbol map for %s: %w", filePath, err)
 	}
-	defer rows.Close()
-
-	var nodes []*Node
-	for rows.Next() {
-		n := &Node{}
-		if err := rows.Scan(&n.ID, &n.Name, &n.Kind, &n.FilePath, &n.LineStart, &n.LineEnd, &n.ColStart, &n.ColEnd, &n.SymbolURI); err != nil {
-			return nil, err
-		}
-		nodes = append(nodes, n)
-	}
-	return nodes, nil
+	return scanNodes(rows)
 }
 
 // DeleteNodesByFile removes all nodes and associated edges for a given file.
@@ -188,6 +323,10 @@ func (s *Store) DeleteNodesByFile(ctx context.Context, filePath string) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete nodes for file %s: %w", filePath, err)
 	}
+	// Without its nodes the file is no longer indexed.
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM files WHERE path = ?", filePath); err != nil {
+		return fmt.Errorf("failed to delete file record %s: %w", filePath, err)
+	}
 	return nil
 }
 
@@ -201,19 +340,20 @@ func (s *Store) Clear(ctx context.Context) error {
 	return nil
 }
 
-// FindNode finds the smallest node containing the given position.
+// FindNode finds the smallest node containing the given position. Positions
+// outside every definition resolve to the file's node.
 func (s *Store) FindNode(ctx context.Context, path string, line, col int) (*Node, error) {
 	query := `
-	SELECT id, name, kind, file_path, line_start, line_end, col_start, col_end, symbol_uri
+	SELECT ` + nodeColumns + `
 	FROM nodes
 	WHERE file_path = ? AND line_start <= ? AND line_end >= ?
-	ORDER BY (line_end - line_start) ASC
+	ORDER BY kind = ?, (line_end - line_start) ASC
 	LIMIT 1;
 	`
-	row := s.db.QueryRowContext(ctx, query, path, line, line)
+	row := s.db.QueryRowContext(ctx, query, path, line, line, KindFile)
 
-	n := &Node{}
-	if err := row.Scan(&n.ID, &n.Name, &n.Kind, &n.FilePath, &n.LineStart, &n.LineEnd, &n.ColStart, &n.ColEnd, &n.SymbolURI); err != nil {
+	n, err := scanNode(row)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -222,7 +362,9 @@ func (s *Store) FindNode(ctx context.Context, path string, line, col int) (*Node
 	return n, nil
 }
 
-func (s *Store) PruneStaleFiles(ctx context.Context, foundFilePaths []string) error {
+// PruneStaleFiles deletes the nodes and file records of the files that are
+// not among foundFilePaths, and returns the nodes it deleted.
+func (s *Store) PruneStaleFiles(ctx context.Context, foundFilePaths []string) ([]*Node, error) {
 	// 1. Create a map for O(1) lookups of found files
 	keep := make(map[string]bool)
 	for _, p := range foundFilePaths {
@@ -232,7 +374,7 @@ func (s *Store) PruneStaleFiles(ctx context.Context, foundFilePaths []string) er
 	// 2. Get all file paths currently in the DB
 	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT file_path FROM nodes")
 	if err != nil {
-		return fmt.Errorf("failed to query existing files: %w", err)
+		return nil, fmt.Errorf("failed to query existing files: %w", err)
 	}
 	defer rows.Close()
 
@@ -240,19 +382,39 @@ func (s *Store) PruneStaleFiles(ctx context.Context, foundFilePaths []string) er
 	for rows.Next() {
 		var p string
 		if err := rows.Scan(&p); err != nil {
-			return err
+			return nil, err
+		}
+		dbFiles = append(dbFiles, p)
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, "SELECT path FROM files")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexed files: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
 		}
 		dbFiles = append(dbFiles, p)
 	}
 	rows.Close()
 
 	// 3. Delete files that are in DB but not in the found set
+	var removed []*Node
 	for _, file := range dbFiles {
 		if !keep[file] {
+			nodes, err := s.GetSymbolsInFile(ctx, file)
+			if err != nil {
+				return nil, err
+			}
 			if err := s.DeleteNodesByFile(ctx, file); err != nil {
-				return fmt.Errorf("failed to prune stale file %s: %w", file, err)
+				return nil, fmt.Errorf("failed to prune stale file %s: %w", file, err)
 			}
+			removed = append(removed, nodes...)
 		}
 	}
-	return nil
+	return removed, nil
 }
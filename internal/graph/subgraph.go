@@ -0,0 +1,146 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Subgraph is a set of nodes together with the edges between them.
+type Subgraph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+}
+
+// SubgraphOptions selects part of the graph. With neither Symbol nor Dir
+// set, the whole graph is selected. Symbol seeds the selection with every
+// definition of that symbol and Dir with every node under that directory;
+// the seeds are then expanded Depth hops along edges in both directions.
+// When both are set, Dir narrows the symbol's definitions. A zero Depth
+// around a symbol means one hop.
+type SubgraphOptions struct {
+	Symbol    string
+	Dir       string
+	Depth     int
+	Relations []string
+}
+
+// Subgraph returns the nodes selected by opts, ordered by file and line, and
+// the edges among them.
+func (s *Store) Subgraph(ctx context.Context, opts SubgraphOptions) (*Subgraph, error) {
+	var seeds []*Node
+	var err error
+	switch {
+	case opts.Symbol != "":
+		seeds, err = s.GetSymbolLocation(ctx, opts.Symbol)
+		if err == nil && opts.Dir != "" {
+			seeds = nodesUnder(seeds, opts.Dir)
+		}
+	case opts.Dir != "":
+		seeds, err = s.nodesInDir(ctx, opts.Dir)
+	default:
+		seeds, err = s.queryNodes(ctx, "SELECT "+nodeColumns+" FROM nodes")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to select nodes: %w", err)
+	}
+
+	selected := make(map[string]*Node, len(seeds))
+	for _, n := range seeds {
+		selected[n.ID] = n
+	}
+	depth := opts.Depth
+	if depth == 0 && opts.Symbol != "" {
+		depth = 1
+	}
+	if depth > 0 && len(seeds) > 0 {
+		for _, dir := range []Direction{Dependents, Dependencies} {
+			reached, err := s.Traverse(ctx, seeds, dir, TraversalOptions{MaxDepth: depth, Relations: opts.Relations})
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand subgraph: %w", err)
+			}
+			for _, r := range reached {
+				selected[r.Node.ID] = r.Node
+			}
+		}
+	}
+
+	g := &Subgraph{}
+	for _, n := range selected {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sortNodes(g.Nodes)
+
+	edges, err := s.edges(ctx, opts.Relations)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range edges {
+		if selected[e.SourceID] != nil && selected[e.TargetID] != nil {
+			g.Edges = append(g.Edges, e)
+		}
+	}
+	return g, nil
+}
+
+// nodesInDir returns the nodes in files under dir, at any depth.
+func (s *Store) nodesInDir(ctx context.Context, dir string) ([]*Node, error) {
+	prefix := strings.TrimSuffix(filepath.Clean(dir), string(filepath.Separator)) + string(filepath.Separator)
+	return s.queryNodes(ctx,
+		"SELECT "+nodeColumns+" FROM nodes WHERE file_path LIKE ? ESCAPE '\\'",
+		escapeLike(prefix)+"%")
+}
+
+func nodesUnder(nodes []*Node, dir string) []*Node {
+	prefix := strings.TrimSuffix(filepath.Clean(dir), string(filepath.Separator)) + string(filepath.Separator)
+	var result []*Node
+	for _, n := range nodes {
+		if strings.HasPrefix(n.FilePath, prefix) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// edges returns all edges with one of the relations (all but contains if
+// empty), ordered.
+func (s *Store) edges(ctx context.Context, relations []string) ([]*Edge, error) {
+	cond, args := relationFilter("relation", relations)
+	query := "SELECT source_id, target_id, relation, line, provenance, confidence FROM edges WHERE " + cond
+	query += " ORDER BY source_id, target_id, relation"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load edges: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*Edge
+	for rows.Next() {
+		e := &Edge{}
+		if err := rows.Scan(&e.SourceID, &e.TargetID, &e.Relation, &e.Line, &e.Provenance, &e.Confidence); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+// sortNodes orders nodes by file, position and ID.
+func sortNodes(nodes []*Node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		if a.LineStart != b.LineStart {
+			return a.LineStart < b.LineStart
+		}
+		if a.ColStart != b.ColStart {
+			return a.ColStart < b.ColStart
+		}
+		return a.ID < b.ID
+	})
+}
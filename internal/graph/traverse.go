@@ -0,0 +1,342 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Direction selects which way edges are followed during a traversal.
+type Direction int
+
+const (
+	// Dependents follows edges from target to source: who depends on a symbol.
+	Dependents Direction = iota
+	// Dependencies follows edges from source to target: what a symbol depends on.
+	Dependencies
+)
+
+// TraversalOptions bounds a graph traversal. Zero values mean "no limit",
+// "all relations but contains" and "all kinds". Kinds, symbol or node kinds, only filter
+// the reported nodes; the traversal passes through nodes of every kind, and
+// Limit counts reported nodes.
+type TraversalOptions struct {
+	MaxDepth  int
+	Relations []string
+	Kinds     []string
+	Limit     int
+}
+
+// PathStep is one hop in an explanation path. Relation is the relation of the
+// edge between this step and the previous one (in the direction the traversal
+// followed) and is empty for the first step.
+type PathStep struct {
+	Name          string `json:"name"`
+	QualifiedName string `json:"qualified_name"`
+	FilePath      string `json:"file_path"`
+	Line          int    `json:"line"`
+	Relation      string `json:"relation,omitempty"`
+}
+
+// Reached is a node found by a traversal, with its distance from the start
+// symbol and one shortest chain of symbols leading back to it. Sites are the
+// uses behind the last edge of the path: where the node uses the previous
+// step, or where the previous step uses it when following dependencies.
+type Reached struct {
+	Node     *Node      `json:"node"`
+	Distance int        `json:"distance"`
+	Path     []PathStep `json:"path"`
+	Sites    []Site     `json:"sites,omitempty"`
+}
+
+// maxQueryParams caps the number of bound parameters per IN (...) list.
+const maxQueryParams = 500
+
+// ValidRelations lists the relations accepted by relation filters.
+var ValidRelations = []string{RelationCalls, RelationReferences, RelationImplements, RelationExtends, RelationOverrides, RelationImports, RelationContains}
+
+// relationFilter returns an SQL condition limiting the relation column col to
+// relations. Empty means every relation but contains, since a member is part
+// of its class rather than something the class depends on.
+func relationFilter(col string, relations []string) (string, []interface{}) {
+	if len(relations) == 0 {
+		return col + " != ?", []interface{}{RelationContains}
+	}
+	args := make([]interface{}, len(relations))
+	for i, r := range relations {
+		args[i] = r
+	}
+	return col + " IN (" + placeholders(len(relations)) + ")", args
+}
+
+// ValidateRelations returns an error naming the first unknown relation.
+func ValidateRelations(relations []string) error {
+	for _, r := range relations {
+		valid := false
+		for _, v := range ValidRelations {
+			if r == v {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown relation %q (valid: %s)", r, strings.Join(ValidRelations, ", "))
+		}
+	}
+	return nil
+}
+
+// FindImpact returns the nodes that transitively depend on the symbol, which
+// may be given as a bare or qualified name. Results are ordered by distance.
+func (s *Store) FindImpact(ctx context.Context, symbolName string, opts TraversalOptions) ([]*Reached, error) {
+	return s.traverseFromSymbol(ctx, symbolName, Dependents, opts)
+}
+
+// FindDependencies returns the nodes the symbol transitively depends on:
+// what it calls, references, implements or imports. Results are ordered by
+// distance.
+func (s *Store) FindDependencies(ctx context.Context, symbolName string, opts TraversalOptions) ([]*Reached, error) {
+	return s.traverseFromSymbol(ctx, symbolName, Dependencies, opts)
+}
+
+func (s *Store) traverseFromSymbol(ctx context.Context, symbolName string, dir Direction, opts TraversalOptions) ([]*Reached, error) {
+	start, err := s.GetSymbolLocation(ctx, symbolName)
+	if err != nil {
+		return nil, err
+	}
+	if len(start) == 0 {
+		return nil, nil
+	}
+	reached, err := s.Traverse(ctx, start, dir, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to traverse from %s: %w", symbolName, err)
+	}
+	return reached, nil
+}
+
+// traversalEdge is an edge as seen from the frontier during a traversal.
+type traversalEdge struct {
+	from, to, relation string
+}
+
+// Traverse runs a breadth-first search from the start nodes. Every node is
+// reported once, at its shortest distance, together with the path that first
+// reached it. Start nodes themselves are not reported.
+func (s *Store) Traverse(ctx context.Context, start []*Node, dir Direction, opts TraversalOptions) ([]*Reached, error) {
+	type visit struct {
+		prev     string
+		relation string
+		distance int
+	}
+
+	visited := make(map[string]visit)
+	var frontier []string
+	for _, n := range start {
+		if _, ok := visited[n.ID]; !ok {
+			visited[n.ID] = visit{distance: 0}
+			frontier = append(frontier, n.ID)
+		}
+	}
+
+	// Without a kind filter every node reached is reported, so the search
+	// can stop as soon as the limit is hit. With one, kinds are only known
+	// once a level's nodes are loaded.
+	nodes := make(map[string]*Node)
+	var order []string
+	full := func() bool { return opts.Limit > 0 && len(order) >= opts.Limit }
+	for depth := 1; len(frontier) > 0 && !full(); depth++ {
+		if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+			break
+		}
+
+		edges, err := s.neighbours(ctx, frontier, dir, opts.Relations)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []string
+		for _, e := range edges {
+			if _, ok := visited[e.to]; ok {
+				continue
+			}
+			visited[e.to] = visit{prev: e.from, relation: e.relation, distance: depth}
+			next = append(next, e.to)
+			if len(opts.Kinds) == 0 {
+				order = append(order, e.to)
+				if full() {
+					break
+				}
+			}
+		}
+		if len(opts.Kinds) > 0 {
+			level, err := s.nodesByID(ctx, next)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range next {
+				n := level[id]
+				if n == nil {
+					continue
+				}
+				nodes[id] = n
+				if MatchesKinds(n, opts.Kinds) {
+					order = append(order, id)
+					if full() {
+						break
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+
+	// Load the reported nodes and the ones on their paths.
+	for _, n := range start {
+		nodes[n.ID] = n
+	}
+	var ids []string
+	for _, id := range order {
+		for cur := id; nodes[cur] == nil; cur = visited[cur].prev {
+			ids = append(ids, cur)
+			if visited[cur].distance == 0 {
+				break
+			}
+		}
+	}
+	loaded, err := s.nodesByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, n := range loaded {
+		nodes[id] = n
+	}
+
+	results := make([]*Reached, 0, len(order))
+	for _, id := range order {
+		n, ok := nodes[id]
+		if !ok {
+			continue
+		}
+
+		// Walk predecessors back to a start node, then reverse so the path
+		// reads from the start symbol to the reached node.
+		var chain []string
+		for cur := id; ; cur = visited[cur].prev {
+			chain = append(chain, cur)
+			if visited[cur].distance == 0 {
+				break
+			}
+		}
+		path := make([]PathStep, 0, len(chain))
+		for i := len(chain) - 1; i >= 0; i-- {
+			cn, ok := nodes[chain[i]]
+			if !ok {
+				continue
+			}
+			path = append(path, PathStep{
+				Name:          cn.Name,
+				QualifiedName: cn.QualifiedName,
+				FilePath:      cn.FilePath,
+				Line:          cn.LineStart,
+				Relation:      visited[chain[i]].relation,
+			})
+		}
+
+		v := visited[id]
+		k := edgeKey{source: id, target: v.prev, relation: v.relation}
+		if dir == Dependencies {
+			k.source, k.target = v.prev, id
+		}
+		sites, err := s.edgeSites(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &Reached{Node: n, Distance: v.distance, Path: path, Sites: sites})
+	}
+	return results, nil
+}
+
+// neighbours returns the edges leaving the frontier in the given direction.
+func (s *Store) neighbours(ctx context.Context, frontier []string, dir Direction, relations []string) ([]traversalEdge, error) {
+	fromCol, toCol := "target_id", "source_id"
+	if dir == Dependencies {
+		fromCol, toCol = "source_id", "target_id"
+	}
+
+	var result []traversalEdge
+	for start := 0; start < len(frontier); start += maxQueryParams {
+		end := start + maxQueryParams
+		if end > len(frontier) {
+			end = len(frontier)
+		}
+		chunk := frontier[start:end]
+
+		args := make([]interface{}, 0, len(chunk)+len(relations))
+		for _, id := range chunk {
+			args = append(args, id)
+		}
+		query := fmt.Sprintf("SELECT %s, %s, relation FROM edges WHERE %s IN (%s)",
+			fromCol, toCol, fromCol, placeholders(len(chunk)))
+		cond, relArgs := relationFilter("relation", relations)
+		query += " AND " + cond
+		args = append(args, relArgs...)
+		query += fmt.Sprintf(" ORDER BY %s, %s, relation", fromCol, toCol)
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query edges: %w", err)
+		}
+		for rows.Next() {
+			var e traversalEdge
+			if err := rows.Scan(&e.from, &e.to, &e.relation); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result = append(result, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// nodesByID loads the given nodes, keyed by ID.
+func (s *Store) nodesByID(ctx context.Context, ids []string) (map[string]*Node, error) {
+	result := make(map[string]*Node, len(ids))
+	for start := 0; start < len(ids); start += maxQueryParams {
+		end := start + maxQueryParams
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := ids[start:end]
+
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		rows, err := s.db.QueryContext(ctx,
+			"SELECT "+nodeColumns+" FROM nodes WHERE id IN ("+placeholders(len(chunk))+")", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load nodes: %w", err)
+		}
+		nodes, err := scanNodes(rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			result[n.ID] = n
+		}
+	}
+	return result, nil
+}
+
+// placeholders returns "?, ?, ..." with n placeholders.
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
@@ -2,22 +2,93 @@ package graph
 
 // Node represents a symbol in the codebase.
 type Node struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Kind      string `json:"kind"`
-	FilePath  string `json:"file_path"`
-	LineStart int    `json:"line_start"`
-	LineEnd   int    `json:"line_end"`
-	ColStart  int    `json:"col_start"`
-	ColEnd    int    `json:"col_end"`
-	SymbolURI string `json:"symbol_uri"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	QualifiedName string `json:"qualified_name"` // module.Container.name, with #N for repeated definitions
+	Kind          string `json:"kind"`           // node type in the language's grammar
+	SymbolKind    string `json:"symbol_kind"`    // language-independent kind, one of SymbolKinds
+	FilePath      string `json:"file_path"`
+	LineStart     int    `json:"line_start"`
+	LineEnd       int    `json:"line_end"`
+	ColStart      int    `json:"col_start"`
+	ColEnd        int    `json:"col_end"`
+	SymbolURI     string `json:"symbol_uri"`
+	ParentID      string `json:"parent_id,omitempty"` // enclosing class, struct or file; empty for files
+	Signature     string `json:"signature,omitempty"` // declaration without the body, on one line
+	Doc           string `json:"doc,omitempty"`       // leading doc comment or docstring
+	Hash          string `json:"-"`                   // of the definition's source, to tell edits from moves
+
+	// Calls are the call expressions inside this definition, as found by the
+	// scanner. They are stored alongside the node and not serialized.
+	Calls []CallSite `json:"-"`
+
+	// Imports are the workspace files imported by a file node, resolved by
+	// the scanner. They become imports edges and are not serialized.
+	Imports []Import `json:"-"`
+
+	// Supertypes are the base classes and interfaces a class or interface
+	// declares. They are resolved to extends and implements edges and are
+	// not serialized.
+	Supertypes []Supertype `json:"-"`
+}
+
+// KindFile is the kind of the node standing for a whole source file. Its
+// qualified name is the file's slash-separated path relative to the workspace.
+const KindFile = "file"
+
+// CallSite is a call expression. Name is the called function or method and
+// Qualifier the expression it was selected from (obj in obj.Run), if any.
+// Line and Col locate Name, 1-based.
+type CallSite struct {
+	Name      string
+	Qualifier string
+	Line      int
+	Col       int
+}
+
+// Supertype is a declared base type, by name as written. Relation is
+// RelationExtends or RelationImplements. Line and Col locate Name, 1-based.
+type Supertype struct {
+	Name      string
+	Qualifier string
+	Relation  string
+	Line      int
+	Col       int
 }
 
-// Edge represents a relationship between two nodes.
+// Import is an import of another file in the workspace. TargetID is the ID of
+// the imported file's node; Line and Col locate the imported path, 1-based.
+type Import struct {
+	TargetID string
+	Line     int
+	Col      int
+}
+
+// Edge represents a relationship between two nodes. Provenance records how it
+// was found and Confidence, between 0 and 1, how sure that source is; zero
+// values mean an LSP edge with full confidence.
+//
+// An edge found at a use of the target carries its 1-based Line and Col in
+// the source's file. The same edge is usually found once per use: the store
+// keeps the earliest line on the edge and every use as a Site.
 type Edge struct {
-	SourceID string `json:"source_id"`
-	TargetID string `json:"target_id"`
-	Relation string `json:"relation"` // calls, implements, references, imports
+	SourceID   string  `json:"source_id"`
+	TargetID   string  `json:"target_id"`
+	Relation   string  `json:"relation"`       // calls, implements, extends, overrides, references, imports, contains
+	Line       int     `json:"line,omitempty"` // Line of the first use in the source's file, if known
+	Col        int     `json:"col,omitempty"`  // Column of the use at Line, if known
+	Provenance string  `json:"provenance"`     // lsp or heuristic
+	Confidence float64 `json:"confidence"`
+}
+
+// Site is one use of an edge's target in the source's file: a call, a
+// reference, an import, a base type in a class declaration. Col is 0 when
+// unknown and Snippet is the source line, trimmed.
+type Site struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+	Col      int    `json:"col,omitempty"`
+	Snippet  string `json:"snippet,omitempty"`
 }
 
 const (
@@ -25,4 +96,15 @@ const (
 	RelationImplements = "implements"
 	RelationReferences = "references"
 	RelationImports    = "imports"
+	RelationExtends    = "extends"
+	RelationOverrides  = "overrides"
+	RelationContains   = "contains"
+)
+
+const (
+	// ProvenanceLSP marks edges resolved by a language server.
+	ProvenanceLSP = "lsp"
+	// ProvenanceHeuristic marks edges inferred from names, scopes and imports
+	// without a language server.
+	ProvenanceHeuristic = "heuristic"
 )
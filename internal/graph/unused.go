@@ -0,0 +1,225 @@
+package graph
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// DefaultEntryPoints are symbol names that are reached from outside the
+// graph (the runtime, the interpreter or a framework) and are never reported
+// as unused unless UnusedOptions.EntryPoints overrides them.
+var DefaultEntryPoints = []string{"main", "init", "__init__", "__main__", "constructor"}
+
+// UnusedOptions controls which symbols FindUnused reports. Kinds restricts
+// the candidates to the given symbol or node kinds; empty means every kind. File nodes
+// are never reported.
+type UnusedOptions struct {
+	Kinds           []string
+	EntryPoints     []string
+	IncludeTests    bool
+	IncludeExported bool
+	ExcludePatterns []string
+	Limit           int
+}
+
+// UnusedSymbol is a symbol with no inbound edges.
+type UnusedSymbol struct {
+	Name          string `json:"name"`
+	QualifiedName string `json:"qualified_name"`
+	Kind          string `json:"kind"`
+	SymbolKind    string `json:"symbol_kind"`
+	Lines         string `json:"lines"`
+	Signature     string `json:"signature,omitempty"`
+	Doc           string `json:"doc,omitempty"`
+}
+
+// UnusedFile groups the unused symbols of one file, in line order.
+type UnusedFile struct {
+	FilePath string         `json:"file_path"`
+	Symbols  []UnusedSymbol `json:"symbols"`
+}
+
+// FindUnused returns symbols that no other symbol references, calls,
+// implements or imports, grouped by file. Methods overriding a used method,
+// and types implementing a used interface, count as used. Entry points, test code, exported
+// API and symbols matching an exclude pattern are skipped as configured.
+// Patterns use filepath.Match syntax and are tested against the name, the
+// qualified name, the file path and the file's base name.
+func (s *Store) FindUnused(ctx context.Context, opts UnusedOptions) ([]UnusedFile, error) {
+	for _, p := range opts.ExcludePatterns {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", p, err)
+		}
+	}
+	entryPoints := opts.EntryPoints
+	if entryPoints == nil {
+		entryPoints = DefaultEntryPoints
+	}
+	entry := make(map[string]bool, len(entryPoints))
+	for _, name := range entryPoints {
+		entry[name] = true
+	}
+
+	// A method that overrides or implements a used one, or a type that
+	// implements a used interface, is reached through dispatch: its edges
+	// point at what it overrides, so it has no inbound edge of its own.
+	query := `WITH RECURSIVE used(id) AS (
+		SELECT target_id FROM edges WHERE source_id != target_id AND relation NOT IN (?, ?, ?)
+		UNION
+		SELECT e.source_id FROM edges e JOIN used u ON e.target_id = u.id WHERE e.relation IN (?, ?)
+	)
+	SELECT ` + nodeColumns + ` FROM nodes n WHERE NOT EXISTS (` +
+		"SELECT 1 FROM edges e WHERE e.target_id = n.id AND e.source_id != n.id AND e.relation != ?) AND n.id NOT IN (SELECT id FROM used) AND n.kind != ?"
+	args := []interface{}{
+		RelationContains, RelationOverrides, RelationImplements,
+		RelationOverrides, RelationImplements,
+		RelationContains, KindFile,
+	}
+	cond, kindArgs := kindFilter("n", opts.Kinds)
+	query += cond
+	args = append(args, kindArgs...)
+	query += " ORDER BY n.file_path, n.line_start, n.col_start"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unused symbols: %w", err)
+	}
+	nodes, err := scanNodes(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []UnusedFile
+	var lines []string
+	linesOf := ""
+	count := 0
+	for _, n := range nodes {
+		if entry[n.Name] || matchesAny(opts.ExcludePatterns, n) {
+			continue
+		}
+		if !opts.IncludeTests && isTestSymbol(n) {
+			continue
+		}
+
+		if !opts.IncludeExported {
+			if linesOf != n.FilePath {
+				lines, linesOf = readLines(n.FilePath), n.FilePath
+			}
+			if isExported(n, lines) {
+				continue
+			}
+		}
+
+		last := len(result) - 1
+		if last < 0 || result[last].FilePath != n.FilePath {
+			result = append(result, UnusedFile{FilePath: n.FilePath})
+			last++
+		}
+		result[last].Symbols = append(result[last].Symbols, UnusedSymbol{
+			Name:          n.Name,
+			QualifiedName: n.QualifiedName,
+			Kind:          n.Kind,
+			SymbolKind:    n.SymbolKind,
+			Lines:         fmt.Sprintf("%d-%d", n.LineStart, n.LineEnd),
+			Signature:     n.Signature,
+			Doc:           n.Doc,
+		})
+		count++
+		if opts.Limit > 0 && count >= opts.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func matchesAny(patterns []string, n *Node) bool {
+	for _, p := range patterns {
+		for _, subject := range []string{n.Name, n.QualifiedName, n.FilePath, filepath.Base(n.FilePath)} {
+			if ok, _ := filepath.Match(p, subject); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isTestSymbol reports whether the node lives in a test file or is a test
+// function by naming convention. Go tests, benchmarks, examples and fuzz
+// targets only exist in _test.go files, so the file name is enough there.
+func isTestSymbol(n *Node) bool {
+	base := filepath.Base(n.FilePath)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	switch ext {
+	case ".go":
+		if strings.HasSuffix(stem, "_test") {
+			return true
+		}
+	case ".py":
+		// pytest collects test_* functions from any module.
+		if strings.HasPrefix(stem, "test_") || strings.HasSuffix(stem, "_test") || stem == "conftest" {
+			return true
+		}
+		if strings.HasPrefix(n.Name, "test_") {
+			return true
+		}
+	default:
+		if strings.HasSuffix(stem, ".test") || strings.HasSuffix(stem, ".spec") || strings.HasSuffix(stem, "_spec") {
+			return true
+		}
+	}
+	return false
+}
+
+// isExported reports whether the node is part of its package's public API.
+// lines holds the node's file, used where visibility is spelled out in the
+// source rather than in the name.
+func isExported(n *Node, lines []string) bool {
+	switch filepath.Ext(n.FilePath) {
+	case ".go":
+		// Package main is a program, not a library: nothing in it is API.
+		pkg, _, _ := strings.Cut(n.QualifiedName, ".")
+		if pkg == "main" {
+			return false
+		}
+		r := []rune(n.Name)
+		return len(r) > 0 && unicode.IsUpper(r[0])
+	case ".py":
+		return !strings.HasPrefix(n.Name, "_")
+	case ".js", ".jsx", ".ts", ".tsx", ".mjs", ".cjs":
+		return strings.HasPrefix(definitionLine(n, lines), "export ")
+	case ".zig":
+		return strings.HasPrefix(definitionLine(n, lines), "pub ")
+	}
+	return false
+}
+
+// definitionLine returns the trimmed source line a node starts on.
+func definitionLine(n *Node, lines []string) string {
+	if n.LineStart < 1 || n.LineStart > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[n.LineStart-1])
+}
+
+// readLines returns the lines of a file, or nil if it cannot be read.
+func readLines(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return lines
+}
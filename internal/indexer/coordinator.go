@@ -0,0 +1,136 @@
+// Package indexer serializes the writes to the code graph: the workspace
+// index run at startup and by the index tool, and the file re-indexes of the
+// watcher.
+package indexer
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Job is a unit of indexing work. It has the graph to itself while it runs.
+type Job func(ctx context.Context) error
+
+// workspaceJob is a queued Index call.
+type workspaceJob struct {
+	ctx  context.Context
+	run  Job
+	done chan error
+}
+
+// Coordinator owns all writes to the graph. It runs queued jobs one at a
+// time: workspace jobs first, in the order they were queued, then file jobs.
+// File jobs therefore wait while a workspace index is queued or running, and
+// a file queued again before its job starts keeps a single job.
+type Coordinator struct {
+	mu        sync.Mutex
+	workspace []*workspaceJob
+	files     []string       // paths with a queued job, oldest first
+	fileJobs  map[string]Job // by path
+	wake      chan struct{}
+}
+
+// New returns a coordinator with an empty queue. Run processes its jobs.
+func New() *Coordinator {
+	return &Coordinator{
+		fileJobs: make(map[string]Job),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// QueueFile queues a job for the file at path. If a job for path is already
+// queued, job replaces it and keeps its place in the queue. Errors are
+// logged.
+func (c *Coordinator) QueueFile(path string, job Job) {
+	c.mu.Lock()
+	if _, ok := c.fileJobs[path]; !ok {
+		c.files = append(c.files, path)
+	}
+	c.fileJobs[path] = job
+	c.mu.Unlock()
+	c.signal()
+}
+
+// Index queues a workspace job, which runs with ctx, and waits for it to
+// finish. It returns the job's error, or ctx's if ctx is done first, in which
+// case the job is dropped from the queue unless it has started.
+func (c *Coordinator) Index(ctx context.Context, job Job) error {
+	j := &workspaceJob{ctx: ctx, run: job, done: make(chan error, 1)}
+	c.mu.Lock()
+	c.workspace = append(c.workspace, j)
+	c.mu.Unlock()
+	c.signal()
+
+	select {
+	case err := <-j.done:
+		return err
+	case <-ctx.Done():
+		c.mu.Lock()
+		for i, w := range c.workspace {
+			if w == j {
+				c.workspace = append(c.workspace[:i], c.workspace[i+1:]...)
+				break
+			}
+		}
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// QueueDepth returns the number of queued jobs that have not started.
+func (c *Coordinator) QueueDepth() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.workspace) + len(c.files)
+}
+
+// Run runs queued jobs until ctx is done. File jobs run with ctx.
+func (c *Coordinator) Run(ctx context.Context) {
+	for {
+		if w, path, job := c.next(); w != nil {
+			w.done <- w.run(w.ctx)
+		} else if job != nil {
+			if err := job(ctx); err != nil {
+				log.Printf("Failed to reindex %s: %v", path, err)
+			}
+		} else {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.wake:
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// next removes the job to run next from the queue: the oldest workspace job,
+// else the oldest file job and its path. All are nil if the queue is empty.
+func (c *Coordinator) next() (*workspaceJob, string, Job) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.workspace) > 0 {
+		w := c.workspace[0]
+		c.workspace = c.workspace[1:]
+		return w, "", nil
+	}
+	if len(c.files) > 0 {
+		path := c.files[0]
+		c.files = c.files[1:]
+		job := c.fileJobs[path]
+		delete(c.fileJobs, path)
+		return nil, path, job
+	}
+	return nil, "", nil
+}
+
+// signal wakes Run if it is waiting for jobs.
+func (c *Coordinator) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
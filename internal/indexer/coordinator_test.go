@@ -0,0 +1,127 @@
+package indexer
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the names of the jobs that ran, in order.
+type recorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *recorder) job(name string) Job {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.ran = append(r.ran, name)
+		return nil
+	}
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.ran, ",")
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoordinator_MergesFileJobsAndRunsWorkspaceFirst(t *testing.T) {
+	c := New()
+	rec := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c.QueueFile("a.go", rec.job("a1"))
+	c.QueueFile("b.go", rec.job("b"))
+	c.QueueFile("a.go", rec.job("a2"))
+	if got := c.QueueDepth(); got != 2 {
+		t.Fatalf("QueueDepth = %d after queueing a.go twice and b.go, want 2", got)
+	}
+
+	indexed := make(chan error, 1)
+	go func() { indexed <- c.Index(ctx, rec.job("workspace")) }()
+	waitFor(t, "the workspace job to be queued", func() bool { return c.QueueDepth() == 3 })
+
+	go c.Run(ctx)
+	if err := <-indexed; err != nil {
+		t.Fatalf("Index failed: %v", err)
+	}
+	waitFor(t, "the file jobs", func() bool { return strings.Count(rec.String(), ",") == 2 })
+	if got := rec.String(); got != "workspace,a2,b" {
+		t.Errorf("jobs ran in order %s, want workspace,a2,b", got)
+	}
+}
+
+func TestCoordinator_PausesFileJobsDuringWorkspaceIndex(t *testing.T) {
+	c := New()
+	rec := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	indexed := make(chan error, 1)
+	go func() {
+		indexed <- c.Index(ctx, func(ctx context.Context) error {
+			close(started)
+			<-release
+			return rec.job("workspace")(ctx)
+		})
+	}()
+	<-started
+
+	// Run is inside the workspace job until release is closed, so the file
+	// job stays queued; had it run alongside, it would be recorded first.
+	c.QueueFile("a.go", rec.job("a"))
+	if got := c.QueueDepth(); got != 1 {
+		t.Fatalf("QueueDepth = %d during the workspace index, want the file job queued", got)
+	}
+
+	close(release)
+	if err := <-indexed; err != nil {
+		t.Fatalf("Index failed: %v", err)
+	}
+	waitFor(t, "the file job", func() bool { return c.QueueDepth() == 0 && strings.Count(rec.String(), ",") == 1 })
+	if got := rec.String(); got != "workspace,a" {
+		t.Errorf("jobs ran in order %s, want workspace,a", got)
+	}
+}
+
+func TestCoordinator_IndexReturnsWhenContextIsDone(t *testing.T) {
+	c := New() // Not running: the job would stay queued.
+	rec := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Index(ctx, rec.job("workspace")); err != context.Canceled {
+		t.Errorf("Index with a cancelled context = %v, want context.Canceled", err)
+	}
+	if got := c.QueueDepth(); got != 0 {
+		t.Fatalf("QueueDepth = %d after Index returned, want the cancelled job dropped", got)
+	}
+
+	// The dropped job does not run once the coordinator does.
+	runCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go c.Run(runCtx)
+	c.QueueFile("a.go", rec.job("a"))
+	waitFor(t, "the file job", func() bool { return rec.String() != "" })
+	if got := rec.String(); got != "a" {
+		t.Errorf("jobs ran in order %s, want a", got)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +20,10 @@ import (
 	"codemap/util"
 )
 
+// ErrNoLanguageServers is returned by Enrich when none of the language servers
+// the workspace needs could be started.
+var ErrNoLanguageServers = errors.New("failed to start any language servers")
+
 // Service manages LSP clients for different languages.
 type Service struct {
 	clients map[string]*Client
@@ -55,6 +61,12 @@ func NewService() *Service {
 	}
 }
 
+// NewLocalService returns a service that only starts language servers found
+// on PATH and never downloads any.
+func NewLocalService() *Service {
+	return &Service{clients: make(map[string]*Client)}
+}
+
 // Client represents a connection to a language server.
 type Client struct {
 	cmd      *exec.Cmd
@@ -67,6 +79,8 @@ type Client struct {
 	errChan  chan error
 	openDocs map[string]int // URI -> version
 	initTime time.Time      // When the server was initialized
+
+	capabilities ServerCapabilities // As advertised in the initialize response
 }
 
 type responseOrError struct {
@@ -125,18 +139,28 @@ func (s *Service) StartClient(ctx context.Context, lang string, cmdPath string,
 	// Initialize Handshake
 	cwd, _ := os.Getwd()
 	initParams := InitializeParams{
-		ProcessID:    os.Getpid(),
-		RootURI:      util.PathToURI(cwd),
-		Capabilities: ClientCapabilities{},
+		ProcessID: os.Getpid(),
+		RootURI:   util.PathToURI(cwd),
+		Capabilities: ClientCapabilities{
+			TextDocument: &TextDocumentClientCapabilities{
+				CallHierarchy: &CallHierarchyClientCapabilities{},
+				TypeHierarchy: &TypeHierarchyClientCapabilities{},
+			},
+		},
 	}
 
 	// Use context with timeout for initialization
 	initCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	if _, err := c.CallWithContext(initCtx, "initialize", initParams); err != nil {
+	initRes, err := c.CallWithContext(initCtx, "initialize", initParams)
+	if err != nil {
 		return fmt.Errorf("initialize failed: %w", err)
 	}
+	var result InitializeResult
+	if err := json.Unmarshal(initRes, &result); err == nil {
+		c.capabilities = result.Capabilities
+	}
 
 	// Send initialized notification
 	notif := Request{
@@ -369,6 +393,129 @@ func (c *Client) GetReferences(ctx context.Context, uri string, line, char int,
 	return locs, nil
 }
 
+// SupportsCallHierarchy reports whether the server handles the call
+// hierarchy requests.
+func (c *Client) SupportsCallHierarchy() bool {
+	return c.capabilities.SupportsCallHierarchy()
+}
+
+// PrepareCallHierarchy resolves the call hierarchy item at a position, the
+// handle for IncomingCalls and OutgoingCalls.
+func (c *Client) PrepareCallHierarchy(ctx context.Context, uri string, line, char int) ([]CallHierarchyItem, error) {
+	params := CallHierarchyPrepareParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: char},
+	}
+
+	ctx, cancel := ensureTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resBytes, err := c.CallWithContext(ctx, "textDocument/prepareCallHierarchy", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []CallHierarchyItem
+	if err := json.Unmarshal(resBytes, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse call hierarchy response: %w", err)
+	}
+
+	return items, nil
+}
+
+// IncomingCalls requests the callers of a call hierarchy item.
+func (c *Client) IncomingCalls(ctx context.Context, item CallHierarchyItem) ([]CallHierarchyIncomingCall, error) {
+	ctx, cancel := ensureTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resBytes, err := c.CallWithContext(ctx, "callHierarchy/incomingCalls", CallHierarchyIncomingCallsParams{Item: item})
+	if err != nil {
+		return nil, err
+	}
+
+	var calls []CallHierarchyIncomingCall
+	if err := json.Unmarshal(resBytes, &calls); err != nil {
+		return nil, fmt.Errorf("failed to parse incoming calls response: %w", err)
+	}
+
+	return calls, nil
+}
+
+// OutgoingCalls requests the callees of a call hierarchy item.
+func (c *Client) OutgoingCalls(ctx context.Context, item CallHierarchyItem) ([]CallHierarchyOutgoingCall, error) {
+	ctx, cancel := ensureTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resBytes, err := c.CallWithContext(ctx, "callHierarchy/outgoingCalls", CallHierarchyOutgoingCallsParams{Item: item})
+	if err != nil {
+		return nil, err
+	}
+
+	var calls []CallHierarchyOutgoingCall
+	if err := json.Unmarshal(resBytes, &calls); err != nil {
+		return nil, fmt.Errorf("failed to parse outgoing calls response: %w", err)
+	}
+
+	return calls, nil
+}
+
+// SupportsTypeHierarchy reports whether the server handles the type
+// hierarchy requests.
+func (c *Client) SupportsTypeHierarchy() bool {
+	return c.capabilities.SupportsTypeHierarchy()
+}
+
+// PrepareTypeHierarchy resolves the type hierarchy item at a position, the
+// handle for Supertypes and Subtypes.
+func (c *Client) PrepareTypeHierarchy(ctx context.Context, uri string, line, char int) ([]TypeHierarchyItem, error) {
+	params := TypeHierarchyPrepareParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: char},
+	}
+
+	ctx, cancel := ensureTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resBytes, err := c.CallWithContext(ctx, "textDocument/prepareTypeHierarchy", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []TypeHierarchyItem
+	if err := json.Unmarshal(resBytes, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse type hierarchy response: %w", err)
+	}
+
+	return items, nil
+}
+
+// Supertypes requests the direct supertypes of a type hierarchy item.
+func (c *Client) Supertypes(ctx context.Context, item TypeHierarchyItem) ([]TypeHierarchyItem, error) {
+	return c.typeHierarchy(ctx, "typeHierarchy/supertypes", TypeHierarchySupertypesParams{Item: item})
+}
+
+// Subtypes requests the direct subtypes of a type hierarchy item.
+func (c *Client) Subtypes(ctx context.Context, item TypeHierarchyItem) ([]TypeHierarchyItem, error) {
+	return c.typeHierarchy(ctx, "typeHierarchy/subtypes", TypeHierarchySubtypesParams{Item: item})
+}
+
+func (c *Client) typeHierarchy(ctx context.Context, method string, params interface{}) ([]TypeHierarchyItem, error) {
+	ctx, cancel := ensureTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resBytes, err := c.CallWithContext(ctx, method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []TypeHierarchyItem
+	if err := json.Unmarshal(resBytes, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse %s response: %w", method, err)
+	}
+
+	return items, nil
+}
+
 // GetHover requests hover information for a symbol.
 func (c *Client) GetHover(ctx context.Context, uri string, line, char int) (*Hover, error) {
 	params := HoverParams{
@@ -414,9 +561,10 @@ func (c *Client) GetDocumentSymbols(ctx context.Context, uri string) ([]Document
 	return symbols, nil
 }
 
-// NodeResolver is an interface to find nodes by location.
+// NodeResolver is an interface to find nodes and call sites by location.
 type NodeResolver interface {
 	FindNode(ctx context.Context, path string, line, col int) (*graph.Node, error)
+	IsCallSite(ctx context.Context, path string, line, col int) (bool, error)
 }
 
 // Enrich uses LSP to find cross-file references and generate edges.
@@ -444,7 +592,7 @@ func (s *Service) Enrich(ctx context.Context, nodes []*graph.Node, resolver Node
 	stats.LanguageServers = langServers
 
 	if len(langServers) == 0 {
-		return nil, fmt.Errorf("failed to start any language servers")
+		return nil, ErrNoLanguageServers
 	}
 
 	// Wait adaptively for indexing - only blocks if servers just started
@@ -505,17 +653,36 @@ func (s *Service) Enrich(ctx context.Context, nodes []*graph.Node, resolver Node
 				docsMu.Unlock()
 
 				// Only process definitions (functions, classes, methods)
-				if n.Name == "" || !isDefinitionKind(n.Kind) {
+				if n.Name == "" || !isDefinitionKind(n.SymbolKind) {
 					continue
 				}
 
+				// Prefer the server's signature for functions and methods,
+				// which carries the types it inferred
+				if isCallableKind(n.SymbolKind) {
+					if sig := s.hoverSignature(ctx, client, n); sig != "" {
+						n.Signature = sig
+					}
+				}
+
 				var nodeEdges []*graph.Edge
 				// Find references to this symbol
 				refEdges := s.findReferenceEdges(ctx, client, n, resolver)
 				nodeEdges = append(nodeEdges, refEdges...)
 
-				// Find implementations if this is an interface
-				if isInterfaceKind(n.Kind) {
+				// Find exact callers and callees of functions and methods
+				if isCallableKind(n.SymbolKind) && client.SupportsCallHierarchy() {
+					nodeEdges = append(nodeEdges, s.findCallHierarchyEdges(ctx, client, n, resolver)...)
+				}
+
+				// Find supertypes and subtypes of classes and interfaces
+				if isTypeKind(n.SymbolKind) && client.SupportsTypeHierarchy() {
+					nodeEdges = append(nodeEdges, s.findTypeHierarchyEdges(ctx, client, n, resolver)...)
+				}
+
+				// Find the implementations of an interface and the interfaces
+				// a Go type satisfies
+				if isInterfaceKind(n.SymbolKind) || satisfiesImplicitly(n) {
 					implEdges := s.findImplementationEdges(ctx, client, n, resolver)
 					nodeEdges = append(nodeEdges, implEdges...)
 				}
@@ -629,6 +796,8 @@ func (s *Service) waitForIndexing(langServers map[string]bool) {
 }
 
 // findReferenceEdges finds all references to a symbol and creates edges.
+// References the scanner recorded as call sites become calls edges; the rest
+// are plain references.
 func (s *Service) findReferenceEdges(ctx context.Context, client *Client, n *graph.Node, resolver NodeResolver) []*graph.Edge {
 	var edges []*graph.Edge
 
@@ -648,10 +817,19 @@ func (s *Service) findReferenceEdges(ctx context.Context, client *Client, n *gra
 		}
 
 		if sourceNode != nil && sourceNode.ID != n.ID {
+			line, col := loc.Range.Start.Line+1, loc.Range.Start.Character+1
+			relation := graph.RelationReferences
+			if isCall, err := resolver.IsCallSite(ctx, targetPath, line, col); err == nil && isCall {
+				relation = graph.RelationCalls
+			}
 			edges = append(edges, &graph.Edge{
-				SourceID: sourceNode.ID,
-				TargetID: n.ID,
-				Relation: "references",
+				SourceID:   sourceNode.ID,
+				TargetID:   n.ID,
+				Relation:   relation,
+				Line:       line,
+				Col:        col,
+				Provenance: graph.ProvenanceLSP,
+				Confidence: 1,
 			})
 		}
 	}
@@ -659,7 +837,160 @@ func (s *Service) findReferenceEdges(ctx context.Context, client *Client, n *gra
 	return edges
 }
 
-// findImplementationEdges finds implementations of an interface.
+// hoverSignature returns the declaration the server shows when hovering over
+// a definition's name, or "" if it shows none.
+func (s *Service) hoverSignature(ctx context.Context, client *Client, n *graph.Node) string {
+	hover, err := client.GetHover(ctx, util.PathToURI(n.FilePath), n.LineStart-1, n.ColStart-1)
+	if err != nil || hover == nil {
+		return ""
+	}
+	return signatureFromHover(hover.Contents.Value)
+}
+
+// signatureFromHover extracts the declaration from hover markdown: the first
+// fenced code block, on one line, without the "(method) " style label some
+// servers put in front of it.
+func signatureFromHover(markdown string) string {
+	start := strings.Index(markdown, "```")
+	if start < 0 {
+		return ""
+	}
+	code := markdown[start+3:]
+	if nl := strings.IndexByte(code, '\n'); nl >= 0 {
+		code = code[nl+1:] // skip the language tag
+	} else {
+		return ""
+	}
+	if end := strings.Index(code, "```"); end >= 0 {
+		code = code[:end]
+	}
+
+	sig := util.OneLine(code)
+	if strings.HasPrefix(sig, "(") {
+		if end := strings.Index(sig, ") "); end > 0 && !strings.ContainsAny(sig[1:end], " (,") {
+			sig = sig[end+2:]
+		}
+	}
+	return sig
+}
+
+// findCallHierarchyEdges creates calls edges from the callers of a function
+// to it and from it to its callees, one at each call site of a pair.
+func (s *Service) findCallHierarchyEdges(ctx context.Context, client *Client, n *graph.Node, resolver NodeResolver) []*graph.Edge {
+	var edges []*graph.Edge
+
+	uri := util.PathToURI(n.FilePath)
+	items, err := client.PrepareCallHierarchy(ctx, uri, n.LineStart-1, n.ColStart-1)
+	if err != nil || len(items) == 0 {
+		return edges
+	}
+	item := items[0]
+
+	// resolve finds the node of a call hierarchy item by its name's position.
+	resolve := func(it CallHierarchyItem) *graph.Node {
+		pos := it.SelectionRange.Start
+		node, err := resolver.FindNode(ctx, util.URIToPath(it.URI), pos.Line+1, pos.Character+1)
+		if err != nil {
+			return nil
+		}
+		return node
+	}
+	edge := func(source, target *graph.Node, ranges []Range) {
+		if source == nil || target == nil || source.ID == target.ID {
+			return
+		}
+		e := graph.Edge{
+			SourceID:   source.ID,
+			TargetID:   target.ID,
+			Relation:   graph.RelationCalls,
+			Provenance: graph.ProvenanceLSP,
+			Confidence: 1,
+		}
+		if len(ranges) == 0 {
+			edges = append(edges, &e)
+		}
+		for _, r := range ranges {
+			site := e
+			site.Line, site.Col = r.Start.Line+1, r.Start.Character+1
+			edges = append(edges, &site)
+		}
+	}
+
+	if incoming, err := client.IncomingCalls(ctx, item); err == nil {
+		for _, call := range incoming {
+			edge(resolve(call.From), n, call.FromRanges)
+		}
+	}
+	if outgoing, err := client.OutgoingCalls(ctx, item); err == nil {
+		for _, call := range outgoing {
+			edge(n, resolve(call.To), call.FromRanges)
+		}
+	}
+
+	return edges
+}
+
+// findTypeHierarchyEdges creates extends and implements edges from a type to
+// its direct supertypes and from its direct subtypes to it.
+func (s *Service) findTypeHierarchyEdges(ctx context.Context, client *Client, n *graph.Node, resolver NodeResolver) []*graph.Edge {
+	var edges []*graph.Edge
+
+	uri := util.PathToURI(n.FilePath)
+	items, err := client.PrepareTypeHierarchy(ctx, uri, n.LineStart-1, n.ColStart-1)
+	if err != nil || len(items) == 0 {
+		return edges
+	}
+	item := items[0]
+
+	resolve := func(it TypeHierarchyItem) *graph.Node {
+		pos := it.SelectionRange.Start
+		node, err := resolver.FindNode(ctx, util.URIToPath(it.URI), pos.Line+1, pos.Character+1)
+		if err != nil {
+			return nil
+		}
+		return node
+	}
+	edge := func(sub, super *graph.Node) {
+		if sub == nil || super == nil || sub.ID == super.ID {
+			return
+		}
+		edges = append(edges, &graph.Edge{
+			SourceID:   sub.ID,
+			TargetID:   super.ID,
+			Relation:   hierarchyRelation(sub, super),
+			Provenance: graph.ProvenanceLSP,
+			Confidence: 1,
+		})
+	}
+
+	if supers, err := client.Supertypes(ctx, item); err == nil {
+		for _, it := range supers {
+			edge(n, resolve(it))
+		}
+	}
+	if subs, err := client.Subtypes(ctx, item); err == nil {
+		for _, it := range subs {
+			edge(resolve(it), n)
+		}
+	}
+
+	return edges
+}
+
+// hierarchyRelation names the edge from a subtype to a supertype: a class
+// implements an interface and extends anything else.
+func hierarchyRelation(sub, super *graph.Node) string {
+	if isInterfaceKind(super.SymbolKind) && !isInterfaceKind(sub.SymbolKind) {
+		return graph.RelationImplements
+	}
+	return graph.RelationExtends
+}
+
+// findImplementationEdges creates implements edges from the implementations
+// of an interface to it, or, for a concrete Go type, from the type to the
+// interfaces it satisfies. gopls answers textDocument/implementation in both
+// directions. An interface found from another interface is extended rather
+// than implemented.
 func (s *Service) findImplementationEdges(ctx context.Context, client *Client, n *graph.Node, resolver NodeResolver) []*graph.Edge {
 	var edges []*graph.Edge
 
@@ -671,18 +1002,25 @@ func (s *Service) findImplementationEdges(ctx context.Context, client *Client, n
 
 	for _, loc := range locs {
 		targetPath := util.URIToPath(loc.URI)
-		implNode, err := resolver.FindNode(ctx, targetPath, loc.Range.Start.Line+1, loc.Range.Start.Character+1)
-		if err != nil {
+		other, err := resolver.FindNode(ctx, targetPath, loc.Range.Start.Line+1, loc.Range.Start.Character+1)
+		if err != nil || other == nil || other.ID == n.ID || other.Kind == graph.KindFile {
 			continue
 		}
 
-		if implNode != nil && implNode.ID != n.ID {
-			edges = append(edges, &graph.Edge{
-				SourceID: implNode.ID,
-				TargetID: n.ID,
-				Relation: "implements",
-			})
+		sub, super := other, n
+		if !isInterfaceKind(n.SymbolKind) {
+			if !isInterfaceKind(other.SymbolKind) {
+				continue
+			}
+			sub, super = n, other
 		}
+		edges = append(edges, &graph.Edge{
+			SourceID:   sub.ID,
+			TargetID:   super.ID,
+			Relation:   hierarchyRelation(sub, super),
+			Provenance: graph.ProvenanceLSP,
+			Confidence: 1,
+		})
 	}
 
 	return edges
@@ -848,22 +1186,54 @@ func findInPath(binaryName string) (string, error) {
 	return path, nil
 }
 
-func isDefinitionKind(kind string) bool {
-	// Check if this node kind represents a definition we want to track
-	definitionKinds := map[string]bool{
-		"function_declaration":  true,
-		"method_declaration":    true,
-		"method_definition":     true,
-		"function_definition":   true,
-		"class_definition":      true,
-		"class_declaration":     true,
-		"interface_declaration": true,
-		"type_definition":       true,
+// definitionKinds are the symbol kinds Enrich looks up references for.
+var definitionKinds = map[string]bool{
+	graph.SymbolFunction:  true,
+	graph.SymbolMethod:    true,
+	graph.SymbolClass:     true,
+	graph.SymbolInterface: true,
+	graph.SymbolStruct:    true,
+}
+
+func isDefinitionKind(symbolKind string) bool {
+	// Check if this symbol kind represents a definition we want to track
+	return definitionKinds[symbolKind]
+}
+
+// DefinitionKinds returns the symbol kinds whose inbound references are
+// resolved during enrichment, sorted.
+func DefinitionKinds() []string {
+	kinds := make([]string, 0, len(definitionKinds))
+	for k := range definitionKinds {
+		kinds = append(kinds, k)
 	}
-	return definitionKinds[kind]
+	sort.Strings(kinds)
+	return kinds
+}
+
+// isCallableKind reports whether symbols of this kind can appear in a call
+// hierarchy.
+func isCallableKind(symbolKind string) bool {
+	return symbolKind == graph.SymbolFunction || symbolKind == graph.SymbolMethod
+}
+
+// isTypeKind reports whether symbols of this kind can appear in a type
+// hierarchy.
+func isTypeKind(symbolKind string) bool {
+	switch symbolKind {
+	case graph.SymbolClass, graph.SymbolInterface, graph.SymbolStruct:
+		return true
+	}
+	return false
+}
+
+// satisfiesImplicitly reports whether n is a Go struct or other named
+// non-interface type, which satisfies interfaces without declaring them.
+func satisfiesImplicitly(n *graph.Node) bool {
+	return getLang(n.FilePath) == "go" && (n.SymbolKind == graph.SymbolStruct || n.SymbolKind == graph.SymbolClass)
 }
 
-func isInterfaceKind(kind string) bool {
+func isInterfaceKind(symbolKind string) bool {
 	// Check if this is an interface/protocol that can be implemented
-	return kind == "interface_declaration" || kind == "protocol_declaration"
+	return symbolKind == graph.SymbolInterface
 }
@@ -1,10 +1,15 @@
 package lsp
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -131,6 +136,10 @@ func (m *MockNodeResolver) FindNode(ctx context.Context, path string, line, col
 	return best, nil
 }
 
+func (m *MockNodeResolver) IsCallSite(ctx context.Context, path string, line, col int) (bool, error) {
+	return false, nil
+}
+
 func TestLSP_Enrich(t *testing.T) {
 	// Skip if gopls is not available
 	if !isCommandAvailable("gopls") {
@@ -163,24 +172,26 @@ func Helper() {}
 	// Create nodes representing the scanned functions
 	nodes := []*graph.Node{
 		{
-			ID:        "main:MainFunc",
-			Name:      "MainFunc",
-			Kind:      "function_declaration",
-			FilePath:  mainFile,
-			LineStart: 3,
-			ColStart:  6,
-			LineEnd:   5,
-			ColEnd:    1,
+			ID:         "main:MainFunc",
+			Name:       "MainFunc",
+			Kind:       "function_declaration",
+			SymbolKind: graph.SymbolFunction,
+			FilePath:   mainFile,
+			LineStart:  3,
+			ColStart:   6,
+			LineEnd:    5,
+			ColEnd:     1,
 		},
 		{
-			ID:        "main:Helper",
-			Name:      "Helper",
-			Kind:      "function_declaration",
-			FilePath:  helperFile,
-			LineStart: 3,
-			ColStart:  6,
-			LineEnd:   3,
-			ColEnd:    21,
+			ID:         "main:Helper",
+			Name:       "Helper",
+			Kind:       "function_declaration",
+			SymbolKind: graph.SymbolFunction,
+			FilePath:   helperFile,
+			LineStart:  3,
+			ColStart:   6,
+			LineEnd:    3,
+			ColEnd:     21,
 		},
 	}
 
@@ -237,12 +248,14 @@ func TestIsDefinitionKind(t *testing.T) {
 		kind string
 		want bool
 	}{
-		{"function_declaration", true},
-		{"method_definition", true},
-		{"class_definition", true},
-		{"interface_declaration", true},
-		{"variable_declaration", false},
-		{"unknown", false},
+		{graph.SymbolFunction, true},
+		{graph.SymbolMethod, true},
+		{graph.SymbolClass, true},
+		{graph.SymbolInterface, true},
+		{graph.SymbolStruct, true},
+		{graph.SymbolVariable, false},
+		{"function_declaration", false},
+		{"", false},
 	}
 
 	for _, tt := range tests {
@@ -252,15 +265,35 @@ func TestIsDefinitionKind(t *testing.T) {
 	}
 }
 
+func TestSignatureFromHover(t *testing.T) {
+	tests := []struct {
+		markdown string
+		want     string
+	}{
+		{"```go\nfunc (s *Square) Area() float64\n```\n\nArea returns the area.", "func (s *Square) Area() float64"},
+		{"```python\n(method) def save(\n    self: Self@User\n) -> None\n```\n---\nSaves.", "def save(self: Self@User) -> None"},
+		{"```typescript\n(a: number) => void\n```", "(a: number) => void"},
+		{"plain text without code", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := signatureFromHover(tt.markdown); got != tt.want {
+			t.Errorf("signatureFromHover(%q) = %q, want %q", tt.markdown, got, tt.want)
+		}
+	}
+}
+
 func TestIsInterfaceKind(t *testing.T) {
 	tests := []struct {
 		kind string
 		want bool
 	}{
-		{"interface_declaration", true},
-		{"protocol_declaration", true},
-		{"class_definition", false},
-		{"function_declaration", false},
+		{graph.SymbolInterface, true},
+		{graph.SymbolClass, false},
+		{graph.SymbolStruct, false},
+		{graph.SymbolFunction, false},
+		{"interface_declaration", false},
 	}
 
 	for _, tt := range tests {
@@ -269,3 +302,220 @@ func TestIsInterfaceKind(t *testing.T) {
 		}
 	}
 }
+
+// newPipeClient returns a client connected to an in-process server that
+// answers each request with handlers[method](params).
+func newPipeClient(t *testing.T, caps ServerCapabilities, handlers map[string]func(json.RawMessage) any) *Client {
+	t.Helper()
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	t.Cleanup(func() {
+		clientW.Close()
+		serverW.Close()
+	})
+
+	go func() {
+		r := bufio.NewReader(serverR)
+		for {
+			body, err := ReadMessage(r)
+			if err != nil {
+				return
+			}
+			var req struct {
+				ID     int             `json:"id"`
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}
+			if err := json.Unmarshal(body, &req); err != nil {
+				return
+			}
+			var result any
+			if h, ok := handlers[req.Method]; ok {
+				result = h(req.Params)
+			}
+			WriteMessage(serverW, Response{JSONRPC: "2.0", ID: req.ID, Result: result})
+		}
+	}()
+
+	c := &Client{
+		stdin:        clientW,
+		stdout:       bufio.NewReader(clientR),
+		pending:      make(map[int]chan responseOrError),
+		errChan:      make(chan error, 1),
+		openDocs:     make(map[string]int),
+		capabilities: caps,
+	}
+	go c.readLoop()
+	return c
+}
+
+func TestServerCapabilities_SupportsCallHierarchy(t *testing.T) {
+	for _, tc := range []struct {
+		json string
+		want bool
+	}{
+		{`{}`, false},
+		{`{"callHierarchyProvider": false}`, false},
+		{`{"callHierarchyProvider": true}`, true},
+		{`{"callHierarchyProvider": {"workDoneProgress": true}}`, true},
+	} {
+		var caps ServerCapabilities
+		if err := json.Unmarshal([]byte(tc.json), &caps); err != nil {
+			t.Fatalf("Unmarshal(%s) failed: %v", tc.json, err)
+		}
+		if got := caps.SupportsCallHierarchy(); got != tc.want {
+			t.Errorf("SupportsCallHierarchy(%s) = %v, want %v", tc.json, got, tc.want)
+		}
+	}
+}
+
+func TestFindCallHierarchyEdges(t *testing.T) {
+	mainFile := "/ws/main.go"
+	nodes := []*graph.Node{
+		{ID: "run", Name: "run", Kind: "function_declaration", SymbolKind: graph.SymbolFunction, FilePath: mainFile, LineStart: 3, ColStart: 6, LineEnd: 7},
+		{ID: "helper", Name: "helper", Kind: "function_declaration", SymbolKind: graph.SymbolFunction, FilePath: mainFile, LineStart: 9, ColStart: 6, LineEnd: 9},
+		{ID: "main", Name: "main", Kind: "function_declaration", SymbolKind: graph.SymbolFunction, FilePath: "/ws/cmd.go", LineStart: 1, ColStart: 6, LineEnd: 5},
+	}
+	item := func(path string, line, char int) CallHierarchyItem {
+		pos := Position{Line: line, Character: char}
+		return CallHierarchyItem{URI: util.PathToURI(path), SelectionRange: Range{Start: pos, End: pos}}
+	}
+	at := func(line int) Range { return Range{Start: Position{Line: line}} }
+
+	var prepared struct {
+		Position Position `json:"position"`
+	}
+	client := newPipeClient(t, ServerCapabilities{CallHierarchyProvider: json.RawMessage("true")}, map[string]func(json.RawMessage) any{
+		"textDocument/prepareCallHierarchy": func(p json.RawMessage) any {
+			json.Unmarshal(p, &prepared)
+			return []CallHierarchyItem{item(mainFile, 2, 5)}
+		},
+		"callHierarchy/incomingCalls": func(json.RawMessage) any {
+			return []CallHierarchyIncomingCall{{From: item("/ws/cmd.go", 0, 5), FromRanges: []Range{at(3), at(2)}}}
+		},
+		"callHierarchy/outgoingCalls": func(json.RawMessage) any {
+			return []CallHierarchyOutgoingCall{
+				{To: item(mainFile, 8, 5), FromRanges: []Range{at(4)}},
+				{To: item("/usr/lib/go/fmt/print.go", 10, 5), FromRanges: []Range{at(5)}},
+			}
+		},
+	})
+	if !client.SupportsCallHierarchy() {
+		t.Fatal("client does not report call hierarchy support")
+	}
+
+	svc := &Service{}
+	edges := svc.findCallHierarchyEdges(context.Background(), client, nodes[0], &MockNodeResolver{nodes: nodes})
+	if prepared.Position != (Position{Line: 2, Character: 5}) {
+		t.Errorf("prepareCallHierarchy at %+v, want 0-based position of run", prepared.Position)
+	}
+
+	var got []string
+	for _, e := range edges {
+		if e.Relation != graph.RelationCalls || e.Provenance != graph.ProvenanceLSP {
+			t.Errorf("edge %+v is not an LSP calls edge", e)
+		}
+		got = append(got, fmt.Sprintf("%s->%s@%d", e.SourceID, e.TargetID, e.Line))
+	}
+	// One edge per call site; the callee outside the workspace has no node
+	// and is dropped.
+	want := "main->run@4,main->run@3,run->helper@5"
+	if strings.Join(got, ",") != want {
+		t.Errorf("edges = %s, want %s", strings.Join(got, ","), want)
+	}
+}
+
+func TestFindTypeHierarchyEdges(t *testing.T) {
+	file := "/ws/shapes.ts"
+	nodes := []*graph.Node{
+		{ID: "Shape", Name: "Shape", Kind: "class_declaration", SymbolKind: graph.SymbolClass, FilePath: file, LineStart: 5, ColStart: 7, LineEnd: 9},
+		{ID: "Drawable", Name: "Drawable", Kind: "interface_declaration", SymbolKind: graph.SymbolInterface, FilePath: file, LineStart: 1, ColStart: 11, LineEnd: 3},
+		{ID: "Base", Name: "Base", Kind: "class_declaration", SymbolKind: graph.SymbolClass, FilePath: "/ws/base.ts", LineStart: 1, ColStart: 7, LineEnd: 2},
+		{ID: "Circle", Name: "Circle", Kind: "class_declaration", SymbolKind: graph.SymbolClass, FilePath: file, LineStart: 11, ColStart: 7, LineEnd: 13},
+	}
+	item := func(path string, line, char int) TypeHierarchyItem {
+		pos := Position{Line: line, Character: char}
+		return TypeHierarchyItem{URI: util.PathToURI(path), SelectionRange: Range{Start: pos, End: pos}}
+	}
+
+	client := newPipeClient(t, ServerCapabilities{TypeHierarchyProvider: json.RawMessage("{}")}, map[string]func(json.RawMessage) any{
+		"textDocument/prepareTypeHierarchy": func(json.RawMessage) any {
+			return []TypeHierarchyItem{item(file, 4, 6)}
+		},
+		"typeHierarchy/supertypes": func(json.RawMessage) any {
+			return []TypeHierarchyItem{item("/ws/base.ts", 0, 6), item(file, 0, 10)}
+		},
+		"typeHierarchy/subtypes": func(json.RawMessage) any {
+			return []TypeHierarchyItem{item(file, 10, 6)}
+		},
+	})
+	if !client.SupportsTypeHierarchy() {
+		t.Fatal("client does not report type hierarchy support")
+	}
+
+	svc := &Service{}
+	edges := svc.findTypeHierarchyEdges(context.Background(), client, nodes[0], &MockNodeResolver{nodes: nodes})
+
+	var got []string
+	for _, e := range edges {
+		if e.Provenance != graph.ProvenanceLSP {
+			t.Errorf("edge %+v is not an LSP edge", e)
+		}
+		got = append(got, fmt.Sprintf("%s-%s->%s", e.SourceID, e.Relation, e.TargetID))
+	}
+	want := "Shape-extends->Base,Shape-implements->Drawable,Circle-extends->Shape"
+	if strings.Join(got, ",") != want {
+		t.Errorf("edges = %s, want %s", strings.Join(got, ","), want)
+	}
+}
+
+func TestFindImplementationEdges(t *testing.T) {
+	file := "/ws/io.go"
+	nodes := []*graph.Node{
+		{ID: "Reader", Name: "Reader", Kind: "type_spec", SymbolKind: graph.SymbolInterface, FilePath: file, LineStart: 3, ColStart: 6, LineEnd: 5},
+		{ID: "ReadCloser", Name: "ReadCloser", Kind: "type_spec", SymbolKind: graph.SymbolInterface, FilePath: file, LineStart: 7, ColStart: 6, LineEnd: 10},
+		{ID: "File", Name: "File", Kind: "type_spec", SymbolKind: graph.SymbolStruct, FilePath: "/ws/file.go", LineStart: 3, ColStart: 6, LineEnd: 5},
+		{ID: "Buffer", Name: "Buffer", Kind: "type_spec", SymbolKind: graph.SymbolClass, FilePath: "/ws/buf.go", LineStart: 3, ColStart: 6, LineEnd: 3},
+	}
+	loc := func(path string, line, char int) Location {
+		pos := Position{Line: line, Character: char}
+		return Location{URI: util.PathToURI(path), Range: Range{Start: pos, End: pos}}
+	}
+
+	var implementations map[string][]Location
+	client := newPipeClient(t, ServerCapabilities{}, map[string]func(json.RawMessage) any{
+		"textDocument/implementation": func(p json.RawMessage) any {
+			var params ImplementationParams
+			json.Unmarshal(p, &params)
+			return implementations[util.URIToPath(params.TextDocument.URI)]
+		},
+	})
+	svc := &Service{}
+	resolver := &MockNodeResolver{nodes: nodes}
+
+	// gopls answers with the implementations of an interface, and with the
+	// interfaces a concrete type satisfies, including ones outside the
+	// workspace that have no node.
+	for _, tc := range []struct {
+		node *graph.Node
+		locs []Location
+		want string
+	}{
+		{nodes[0], []Location{loc("/ws/file.go", 2, 5), loc("/ws/buf.go", 2, 5), loc(file, 6, 5)},
+			"File-implements->Reader,Buffer-implements->Reader,ReadCloser-extends->Reader"},
+		{nodes[2], []Location{loc(file, 2, 5), loc(file, 6, 5), loc("/usr/lib/go/src/io/io.go", 80, 5)},
+			"File-implements->Reader,File-implements->ReadCloser"},
+	} {
+		implementations = map[string][]Location{tc.node.FilePath: tc.locs}
+		var got []string
+		for _, e := range svc.findImplementationEdges(context.Background(), client, tc.node, resolver) {
+			if e.Provenance != graph.ProvenanceLSP {
+				t.Errorf("edge %+v is not an LSP edge", e)
+			}
+			got = append(got, fmt.Sprintf("%s-%s->%s", e.SourceID, e.Relation, e.TargetID))
+		}
+		if strings.Join(got, ",") != tc.want {
+			t.Errorf("edges of %s = %s, want %s", tc.node.Name, strings.Join(got, ","), tc.want)
+		}
+	}
+}
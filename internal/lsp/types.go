@@ -1,5 +1,7 @@
 package lsp
 
+import "encoding/json"
+
 // JSON-RPC 2.0 Types
 
 type Request struct {
@@ -31,7 +33,20 @@ type InitializeParams struct {
 }
 
 type ClientCapabilities struct {
-	// Minimal for now
+	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+}
+
+type TextDocumentClientCapabilities struct {
+	CallHierarchy *CallHierarchyClientCapabilities `json:"callHierarchy,omitempty"`
+	TypeHierarchy *TypeHierarchyClientCapabilities `json:"typeHierarchy,omitempty"`
+}
+
+type CallHierarchyClientCapabilities struct {
+	DynamicRegistration bool `json:"dynamicRegistration"`
+}
+
+type TypeHierarchyClientCapabilities struct {
+	DynamicRegistration bool `json:"dynamicRegistration"`
 }
 
 type InitializeResult struct {
@@ -40,9 +55,30 @@ type InitializeResult struct {
 
 type ServerCapabilities struct {
 	ReferencesProvider bool `json:"referencesProvider,omitempty"`
+	// CallHierarchyProvider and TypeHierarchyProvider are true or an options
+	// object when supported.
+	CallHierarchyProvider json.RawMessage `json:"callHierarchyProvider,omitempty"`
+	TypeHierarchyProvider json.RawMessage `json:"typeHierarchyProvider,omitempty"`
 	// Add others as needed
 }
 
+// SupportsCallHierarchy reports whether the server advertised the call
+// hierarchy requests.
+func (c ServerCapabilities) SupportsCallHierarchy() bool {
+	return advertised(c.CallHierarchyProvider)
+}
+
+// SupportsTypeHierarchy reports whether the server advertised the type
+// hierarchy requests.
+func (c ServerCapabilities) SupportsTypeHierarchy() bool {
+	return advertised(c.TypeHierarchyProvider)
+}
+
+func advertised(provider json.RawMessage) bool {
+	p := string(provider)
+	return p != "" && p != "false" && p != "null"
+}
+
 type ReferenceParams struct {
 	TextDocument TextDocumentIdentifier `json:"textDocument"`
 	Position     Position               `json:"position"`
@@ -117,6 +153,70 @@ type ImplementationParams struct {
 	Position     Position               `json:"position"`
 }
 
+// Call Hierarchy Types
+
+type CallHierarchyPrepareParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type CallHierarchyItem struct {
+	Name           string          `json:"name"`
+	Kind           int             `json:"kind"`
+	Detail         string          `json:"detail,omitempty"`
+	URI            string          `json:"uri"`
+	Range          Range           `json:"range"`
+	SelectionRange Range           `json:"selectionRange"`
+	Data           json.RawMessage `json:"data,omitempty"`
+}
+
+type CallHierarchyIncomingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyIncomingCall is a caller of an item. FromRanges are the call
+// sites inside From.
+type CallHierarchyIncomingCall struct {
+	From       CallHierarchyItem `json:"from"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+type CallHierarchyOutgoingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyOutgoingCall is a callee of an item. FromRanges are the call
+// sites inside the item that was asked about.
+type CallHierarchyOutgoingCall struct {
+	To         CallHierarchyItem `json:"to"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+// Type Hierarchy Types
+
+type TypeHierarchyPrepareParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type TypeHierarchyItem struct {
+	Name           string          `json:"name"`
+	Kind           int             `json:"kind"`
+	Detail         string          `json:"detail,omitempty"`
+	URI            string          `json:"uri"`
+	Range          Range           `json:"range"`
+	SelectionRange Range           `json:"selectionRange"`
+	Data           json.RawMessage `json:"data,omitempty"`
+}
+
+type TypeHierarchySupertypesParams struct {
+	Item TypeHierarchyItem `json:"item"`
+}
+
+type TypeHierarchySubtypesParams struct {
+	Item TypeHierarchyItem `json:"item"`
+}
+
 // Hover Types
 
 type HoverParams struct {
@@ -0,0 +1,101 @@
+package scanner
+
+import (
+	sitter "github.com/tree-sitter/go-tree-sitter"
+
+	"codemap/internal/graph"
+)
+
+// supertypes returns the base classes and interfaces a class or interface
+// definition declares: Python base classes, JS/TS extends clauses and TS
+// implements clauses. Keyword arguments such as metaclass= are skipped.
+func supertypes(langKey string, def *sitter.Node, content []byte) []graph.Supertype {
+	var result []graph.Supertype
+	add := func(n *sitter.Node, relation string) {
+		if st, ok := supertypeRef(n, content); ok {
+			st.Relation = relation
+			result = append(result, st)
+		}
+	}
+
+	switch langKey {
+	case "python":
+		if def.Kind() != "class_definition" {
+			return nil
+		}
+		if bases := def.ChildByFieldName("superclasses"); bases != nil {
+			for i := uint(0); i < bases.NamedChildCount(); i++ {
+				add(bases.NamedChild(i), graph.RelationExtends)
+			}
+		}
+	case "javascript", "typescript":
+		if def.Kind() == "variable_declarator" {
+			value := def.ChildByFieldName("value")
+			if value == nil || value.Kind() != "class" {
+				return nil
+			}
+			def = value
+		}
+		for i := uint(0); i < def.NamedChildCount(); i++ {
+			child := def.NamedChild(i)
+			switch child.Kind() {
+			case "class_heritage":
+				for j := uint(0); j < child.NamedChildCount(); j++ {
+					clause := child.NamedChild(j)
+					switch clause.Kind() {
+					case "extends_clause":
+						for k := uint(0); k < clause.NamedChildCount(); k++ {
+							if clause.FieldNameForNamedChild(uint32(k)) == "value" {
+								add(clause.NamedChild(k), graph.RelationExtends)
+							}
+						}
+					case "implements_clause":
+						for k := uint(0); k < clause.NamedChildCount(); k++ {
+							add(clause.NamedChild(k), graph.RelationImplements)
+						}
+					default:
+						// Plain JavaScript: class_heritage holds the expression.
+						add(clause, graph.RelationExtends)
+					}
+				}
+			case "extends_type_clause":
+				for j := uint(0); j < child.NamedChildCount(); j++ {
+					add(child.NamedChild(j), graph.RelationExtends)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// supertypeRef reads a base type expression: a name, a member of a module
+// (mixins.Walker, ns.Named) or a generic instantiation (Other<X>).
+func supertypeRef(n *sitter.Node, content []byte) (graph.Supertype, bool) {
+	switch n.Kind() {
+	case "identifier", "type_identifier":
+		return graph.Supertype{Name: n.Utf8Text(content), Line: int(n.StartPosition().Row) + 1, Col: int(n.StartPosition().Column) + 1}, true
+	case "attribute", "member_expression", "nested_type_identifier":
+		var name, qualifier *sitter.Node
+		switch n.Kind() {
+		case "attribute":
+			name, qualifier = n.ChildByFieldName("attribute"), n.ChildByFieldName("object")
+		case "member_expression":
+			name, qualifier = n.ChildByFieldName("property"), n.ChildByFieldName("object")
+		default:
+			name, qualifier = n.ChildByFieldName("name"), n.ChildByFieldName("module")
+		}
+		if name == nil {
+			return graph.Supertype{}, false
+		}
+		st := graph.Supertype{Name: name.Utf8Text(content), Line: int(name.StartPosition().Row) + 1, Col: int(name.StartPosition().Column) + 1}
+		if qualifier != nil {
+			st.Qualifier = qualifier.Utf8Text(content)
+		}
+		return st, true
+	case "generic_type":
+		if name := n.ChildByFieldName("name"); name != nil {
+			return supertypeRef(name, content)
+		}
+	}
+	return graph.Supertype{}, false
+}
@@ -0,0 +1,187 @@
+package scanner
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// importSpec is an import statement waiting to be resolved. Names are the
+// names imported from a Python module, which may themselves be submodules.
+type importSpec struct {
+	path  string
+	names []string
+	line  int
+	col   int
+}
+
+// scriptExtensions are tried, in order, when resolving a JS/TS import that
+// omits the file extension.
+var scriptExtensions = []string{".ts", ".tsx", ".js", ".jsx"}
+
+// newImportSpec reads the module path captured by @import.
+func newImportSpec(n sitter.Node, content []byte) importSpec {
+	imp := importSpec{
+		path: strings.Trim(n.Utf8Text(content), "\"'`"),
+		line: int(n.StartPosition().Row) + 1,
+		col:  int(n.StartPosition().Column) + 1,
+	}
+	if parent := n.Parent(); parent != nil && parent.Kind() == "import_from_statement" {
+		cursor := parent.Walk()
+		defer cursor.Close()
+		for _, name := range parent.ChildrenByFieldName("name", cursor) {
+			if name.Kind() == "aliased_import" {
+				if orig := name.ChildByFieldName("name"); orig != nil {
+					name = *orig
+				}
+			}
+			imp.names = append(imp.names, name.Utf8Text(content))
+		}
+	}
+	return imp
+}
+
+// resolveImport returns the workspace files an import refers to. Imports of
+// the standard library and third-party packages resolve to nothing.
+func (s *Scanner) resolveImport(langKey, path string, imp importSpec) []string {
+	dir := filepath.Dir(path)
+	switch langKey {
+	case "go":
+		return s.resolveGoImport(imp.path)
+	case "python":
+		return s.resolvePythonImport(dir, imp)
+	case "javascript", "typescript":
+		if !strings.HasPrefix(imp.path, "./") && !strings.HasPrefix(imp.path, "../") {
+			return nil
+		}
+		return firstFile(scriptCandidates(filepath.Join(dir, filepath.FromSlash(imp.path))))
+	case "lua":
+		if s.root == "" {
+			return nil
+		}
+		base := filepath.Join(s.root, filepath.FromSlash(strings.ReplaceAll(imp.path, ".", "/")))
+		return firstFile([]string{base + ".lua", filepath.Join(base, "init.lua")})
+	case "zig":
+		if !strings.HasSuffix(imp.path, ".zig") {
+			return nil
+		}
+		return firstFile([]string{filepath.Join(dir, filepath.FromSlash(imp.path))})
+	}
+	return nil
+}
+
+// resolveGoImport maps an import path inside the workspace module to the
+// non-test files of the package directory.
+func (s *Scanner) resolveGoImport(importPath string) []string {
+	if s.root == "" || s.goModule == "" {
+		return nil
+	}
+	var rel string
+	switch {
+	case importPath == s.goModule:
+	case strings.HasPrefix(importPath, s.goModule+"/"):
+		rel = strings.TrimPrefix(importPath, s.goModule+"/")
+	default:
+		return nil
+	}
+
+	pkgDir := filepath.Join(s.root, filepath.FromSlash(rel))
+	entries, err := os.ReadDir(pkgDir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		files = append(files, filepath.Join(pkgDir, name))
+	}
+	return files
+}
+
+// resolvePythonImport resolves absolute imports against the workspace root
+// and relative ones against the importing file's package. A name imported
+// from a package resolves to its submodule when there is one.
+func (s *Scanner) resolvePythonImport(dir string, imp importSpec) []string {
+	module := imp.path
+	base := s.root
+	if strings.HasPrefix(module, ".") {
+		base = dir
+		for module = module[1:]; strings.HasPrefix(module, "."); module = module[1:] {
+			base = filepath.Dir(base)
+		}
+	}
+	if base == "" {
+		return nil
+	}
+
+	moduleDir := filepath.Join(base, filepath.FromSlash(strings.ReplaceAll(module, ".", "/")))
+	var files []string
+	for _, name := range imp.names {
+		files = append(files, firstFile(pythonCandidates(filepath.Join(moduleDir, name)))...)
+	}
+	if len(files) < len(imp.names) || len(imp.names) == 0 {
+		if module == "" {
+			files = append(files, firstFile([]string{filepath.Join(moduleDir, "__init__.py")})...)
+		} else {
+			files = append(files, firstFile(pythonCandidates(moduleDir))...)
+		}
+	}
+	return files
+}
+
+func pythonCandidates(base string) []string {
+	return []string{base + ".py", filepath.Join(base, "__init__.py")}
+}
+
+// scriptCandidates lists the files a JS/TS module path may name: the path
+// itself, with each extension added, a TypeScript source standing in for its
+// compiled .js name, and a directory index.
+func scriptCandidates(base string) []string {
+	candidates := []string{base}
+	for _, ext := range scriptExtensions {
+		candidates = append(candidates, base+ext)
+	}
+	if strings.HasSuffix(base, ".js") {
+		trimmed := strings.TrimSuffix(base, ".js")
+		candidates = append(candidates, trimmed+".ts", trimmed+".tsx")
+	}
+	for _, ext := range scriptExtensions {
+		candidates = append(candidates, filepath.Join(base, "index"+ext))
+	}
+	return candidates
+}
+
+// firstFile returns the first candidate that is a regular file, if any.
+func firstFile(candidates []string) []string {
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && info.Mode().IsRegular() {
+			return []string{c}
+		}
+	}
+	return nil
+}
+
+// readGoModule returns the module path declared in root/go.mod, or "" if
+// there is none.
+func readGoModule(root string) string {
+	f, err := os.Open(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			return strings.Trim(strings.TrimSpace(rest), "\"")
+		}
+	}
+	return ""
+}
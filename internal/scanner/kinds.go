@@ -0,0 +1,86 @@
+package scanner
+
+import (
+	sitter "github.com/tree-sitter/go-tree-sitter"
+
+	"codemap/internal/graph"
+)
+
+// symbolKind returns the symbol kind of a definition, refining the default
+// for its node kind where the grammar uses one node for several kinds:
+// Python functions directly inside a class and Lua functions declared with
+// a colon are methods, Python class attributes are fields, JS/TS variables
+// holding a class or function are classes and functions, or constants when
+// declared const, Go type specs are interfaces, structs or, for other
+// named types, classes by their underlying type, and Zig declarations are
+// the struct or enum they hold, otherwise constants or variables.
+func symbolKind(langKey string, def *sitter.Node, kind string) string {
+	switch {
+	case langKey == "python" && kind == "function_definition":
+		parent := def.Parent()
+		if parent != nil && parent.Kind() == "decorated_definition" {
+			parent = parent.Parent()
+		}
+		if parent != nil && parent.Kind() == "block" {
+			if owner := parent.Parent(); owner != nil && owner.Kind() == "class_definition" {
+				return graph.SymbolMethod
+			}
+		}
+	case langKey == "python" && kind == "assignment":
+		// Only assignments in a class body are captured.
+		return graph.SymbolField
+	case langKey == "lua" && kind == "function_declaration":
+		if name := def.ChildByFieldName("name"); name != nil && name.Kind() == "method_index_expression" {
+			return graph.SymbolMethod
+		}
+	case langKey == "go" && kind == "type_spec":
+		if t := def.ChildByFieldName("type"); t != nil {
+			switch t.Kind() {
+			case "interface_type":
+				return graph.SymbolInterface
+			case "struct_type":
+				return graph.SymbolStruct
+			}
+		}
+		return graph.SymbolClass
+	case langKey == "zig" && kind == "variable_declaration":
+		if c := zigContainer(def); c != nil {
+			if c.Kind() == "enum_declaration" {
+				return graph.SymbolEnum
+			}
+			return graph.SymbolStruct
+		}
+		for i := uint(0); i < def.ChildCount(); i++ {
+			if def.Child(i).Kind() == "const" {
+				return graph.SymbolConstant
+			}
+		}
+	case kind == "variable_declarator":
+		if value := def.ChildByFieldName("value"); value != nil {
+			switch value.Kind() {
+			case "class":
+				return graph.SymbolClass
+			case "arrow_function", "function_expression", "function", "generator_function":
+				return graph.SymbolFunction
+			}
+		}
+		if decl := def.Parent(); decl != nil {
+			if k := decl.ChildByFieldName("kind"); k != nil && k.Kind() == "const" {
+				return graph.SymbolConstant
+			}
+		}
+	}
+	return graph.SymbolKindOf(kind)
+}
+
+// zigContainer returns the struct, enum or union a Zig declaration holds, or
+// nil.
+func zigContainer(def *sitter.Node) *sitter.Node {
+	for i := uint(0); i < def.NamedChildCount(); i++ {
+		switch c := def.NamedChild(i); c.Kind() {
+		case "struct_declaration", "enum_declaration", "union_declaration":
+			return c
+		}
+	}
+	return nil
+}
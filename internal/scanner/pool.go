@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"sync"
+
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// parserPool keeps parsers, per file extension, and query cursors for reuse
+// across files. They hold C memory the garbage collector does not free, so
+// they are kept until close rather than in a sync.Pool. The pool grows to the
+// number of files parsed at once.
+type parserPool struct {
+	mu      sync.Mutex
+	parsers map[string][]*sitter.Parser
+	cursors []*sitter.QueryCursor
+}
+
+func newParserPool() *parserPool {
+	return &parserPool{parsers: make(map[string][]*sitter.Parser)}
+}
+
+// parser returns an idle parser for ext, creating one for lang if there is
+// none. Hand it back with putParser.
+func (p *parserPool) parser(ext string, lang *sitter.Language) *sitter.Parser {
+	p.mu.Lock()
+	if free := p.parsers[ext]; len(free) > 0 {
+		parser := free[len(free)-1]
+		p.parsers[ext] = free[:len(free)-1]
+		p.mu.Unlock()
+		return parser
+	}
+	p.mu.Unlock()
+
+	parser := sitter.NewParser()
+	parser.SetLanguage(lang)
+	return parser
+}
+
+func (p *parserPool) putParser(ext string, parser *sitter.Parser) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.parsers[ext] = append(p.parsers[ext], parser)
+}
+
+// cursor returns an idle query cursor. Hand it back with putCursor.
+func (p *parserPool) cursor() *sitter.QueryCursor {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := len(p.cursors); n > 0 {
+		qc := p.cursors[n-1]
+		p.cursors = p.cursors[:n-1]
+		return qc
+	}
+	return sitter.NewQueryCursor()
+}
+
+func (p *parserPool) putCursor(qc *sitter.QueryCursor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cursors = append(p.cursors, qc)
+}
+
+// close frees the idle parsers and cursors.
+func (p *parserPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for ext, free := range p.parsers {
+		for _, parser := range free {
+			parser.Close()
+		}
+		delete(p.parsers, ext)
+	}
+	for _, qc := range p.cursors {
+		qc.Close()
+	}
+	p.cursors = nil
+}
@@ -0,0 +1,155 @@
+package scanner
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// scopeKinds lists, per language, the node kinds that contribute a segment to
+// the qualified name of the definitions nested inside them.
+var scopeKinds = map[string]map[string]bool{
+	"go": {
+		"type_spec": true,
+	},
+	"python": {
+		"class_definition":    true,
+		"function_definition": true,
+	},
+	"javascript": {
+		"class_declaration":              true,
+		"class":                          true,
+		"function_declaration":           true,
+		"generator_function_declaration": true,
+		"method_definition":              true,
+		"variable_declarator":            true,
+	},
+	"typescript": {
+		"class_declaration":              true,
+		"abstract_class_declaration":     true,
+		"class":                          true,
+		"function_declaration":           true,
+		"generator_function_declaration": true,
+		"method_definition":              true,
+		"interface_declaration":          true,
+		"internal_module":                true,
+		"variable_declarator":            true,
+	},
+	"lua": {
+		"function_declaration": true,
+	},
+	"zig": {
+		"function_declaration": true,
+		"variable_declaration": true,
+	},
+}
+
+// moduleName returns the package or module segment of qualified names for a
+// file: the package clause for Go, the dotted module path for Python and the
+// extension-less relative path for everything else.
+func moduleName(langKey, relPath string, root *sitter.Node, content []byte) string {
+	if langKey == "go" {
+		for i := uint(0); i < root.NamedChildCount(); i++ {
+			child := root.NamedChild(i)
+			if child.Kind() != "package_clause" {
+				continue
+			}
+			for j := uint(0); j < child.NamedChildCount(); j++ {
+				if id := child.NamedChild(j); id.Kind() == "package_identifier" {
+					return id.Utf8Text(content)
+				}
+			}
+		}
+		return path.Base(filepath.ToSlash(filepath.Dir(relPath)))
+	}
+
+	mod := filepath.ToSlash(strings.TrimSuffix(relPath, filepath.Ext(relPath)))
+	if langKey == "python" {
+		mod = strings.TrimSuffix(mod, "/__init__")
+		mod = strings.ReplaceAll(mod, "/", ".")
+	}
+	return mod
+}
+
+// containerNames returns the names of the scopes enclosing def, outermost
+// first. For Go methods this is the receiver's base type.
+func containerNames(langKey string, def *sitter.Node, content []byte) []string {
+	if langKey == "go" && def.Kind() == "method_declaration" {
+		if recv := receiverTypeName(def, content); recv != "" {
+			return []string{recv}
+		}
+		return nil
+	}
+
+	kinds := scopeKinds[langKey]
+	var names []string
+	for n := def.Parent(); n != nil; n = n.Parent() {
+		if !kinds[n.Kind()] {
+			continue
+		}
+		if name := scopeName(n, content); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	// Collected innermost first; reverse into declaration order.
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names
+}
+
+// scopeName extracts the declared name of a scope node, if it has one.
+func scopeName(n *sitter.Node, content []byte) string {
+	if name := n.ChildByFieldName("name"); name != nil {
+		return name.Utf8Text(content)
+	}
+	for i := uint(0); i < n.NamedChildCount(); i++ {
+		if child := n.NamedChild(i); child.Kind() == "identifier" {
+			return child.Utf8Text(content)
+		}
+	}
+	return ""
+}
+
+// receiverTypeName returns the base type name of a Go method receiver,
+// stripping pointers and type parameters.
+func receiverTypeName(method *sitter.Node, content []byte) string {
+	recv := method.ChildByFieldName("receiver")
+	if recv == nil {
+		return ""
+	}
+	for i := uint(0); i < recv.NamedChildCount(); i++ {
+		param := recv.NamedChild(i)
+		if param.Kind() != "parameter_declaration" {
+			continue
+		}
+		t := param.ChildByFieldName("type")
+		for t != nil {
+			switch t.Kind() {
+			case "type_identifier":
+				return t.Utf8Text(content)
+			case "pointer_type", "parenthesized_type":
+				t = t.NamedChild(0)
+			case "generic_type":
+				t = t.ChildByFieldName("type")
+			default:
+				return t.Utf8Text(content)
+			}
+		}
+	}
+	return ""
+}
+
+// qualifiedName joins module, enclosing scopes and the symbol name.
+func qualifiedName(module string, containers []string, name string) string {
+	parts := make([]string, 0, len(containers)+2)
+	if module != "" {
+		parts = append(parts, module)
+	}
+	parts = append(parts, containers...)
+	parts = append(parts, name)
+	return strings.Join(parts, ".")
+}
@@ -1,20 +1,70 @@
 package scanner
 
+// Queries holds the tree-sitter queries run on each file. @def/@name pairs
+// capture definitions; @call/@callee pairs capture call expressions and the
+// identifier naming the called function or method; @import captures the
+// module path of an import, require or @import statement.
 var Queries = map[string]string{
 	"go": `
 		(function_declaration name: (identifier) @name) @def
 		(method_declaration name: (field_identifier) @name) @def
-		(type_declaration (type_spec name: (type_identifier) @name)) @def
+		(type_declaration (type_spec name: (type_identifier) @name) @def)
+		(type_spec type: (struct_type (field_declaration_list
+			(field_declaration name: (field_identifier) @name) @def)))
+		(type_spec type: (interface_type
+			(method_elem name: (field_identifier) @name) @def))
+		(source_file (const_declaration
+			(const_spec name: (identifier) @name) @def))
+
+		(call_expression function: [
+			(identifier) @callee
+			(selector_expression field: (field_identifier) @callee)
+		]) @call
+
+		(import_spec path: (interpreted_string_literal) @import)
 	`,
 	"python": `
 		(function_definition name: (identifier) @name) @def
 		(class_definition name: (identifier) @name) @def
+		(class_definition body: (block (expression_statement
+			(assignment left: (identifier) @name) @def)))
+
+		(call function: [
+			(identifier) @callee
+			(attribute attribute: (identifier) @callee)
+		]) @call
+
+		(import_statement name: [
+			(dotted_name) @import
+			(aliased_import name: (dotted_name) @import)
+		])
+		(import_from_statement module_name: (_) @import)
 	`,
 	"javascript": `
 		(function_declaration name: (identifier) @name) @def
 		(class_declaration name: (identifier) @name) @def
 		(method_definition name: (property_identifier) @name) @def
+		(field_definition property: [
+			(property_identifier)
+			(private_property_identifier)
+		] @name) @def
 		(variable_declarator name: (identifier) @name) @def
+
+		(call_expression function: [
+			(identifier) @callee
+			(member_expression property: (property_identifier) @callee)
+		]) @call
+		(new_expression constructor: (identifier) @callee) @call
+
+		(import_statement source: (string) @import)
+		(export_statement source: (string) @import)
+		(call_expression
+			function: (identifier) @_require
+			arguments: (arguments (string) @import)
+			(#eq? @_require "require"))
+		(call_expression
+			function: (import)
+			arguments: (arguments (string) @import))
 	`,
 	"typescript": `
 		(function_declaration name: (identifier) @name) @def
@@ -22,9 +72,49 @@ var Queries = map[string]string{
 		(method_definition name: (property_identifier) @name) @def
 		(interface_declaration name: (type_identifier) @name) @def
 		(type_alias_declaration name: (type_identifier) @name) @def
+		(enum_declaration name: (identifier) @name) @def
+		(public_field_definition name: [
+			(property_identifier)
+			(private_property_identifier)
+		] @name) @def
+		(interface_body (property_signature name: (property_identifier) @name) @def)
+		(interface_body (method_signature name: (property_identifier) @name) @def)
+		(variable_declarator name: (identifier) @name) @def
+
+		(call_expression function: [
+			(identifier) @callee
+			(member_expression property: (property_identifier) @callee)
+		]) @call
+		(new_expression constructor: (identifier) @callee) @call
+
+		(import_statement source: (string) @import)
+		(export_statement source: (string) @import)
+		(call_expression
+			function: (identifier) @_require
+			arguments: (arguments (string) @import)
+			(#eq? @_require "require"))
+		(call_expression
+			function: (import)
+			arguments: (arguments (string) @import))
 	`,
 	"zig": `
 		(function_declaration name: (identifier) @name) @def
+		([
+			(source_file (variable_declaration (identifier) @name) @def)
+			(struct_declaration (variable_declaration (identifier) @name) @def)
+			(enum_declaration (variable_declaration (identifier) @name) @def)
+			(union_declaration (variable_declaration (identifier) @name) @def)
+		])
+
+		(call_expression function: [
+			(identifier) @callee
+			(field_expression member: (identifier) @callee)
+		]) @call
+
+		(builtin_function
+			(builtin_identifier) @_builtin
+			(arguments (string) @import)
+			(#eq? @_builtin "@import"))
 	`,
 	"lua": `
 		(function_declaration name: [
@@ -38,5 +128,16 @@ var Queries = map[string]string{
 		(assignment_statement
 			(variable_list
 				(variable (identifier) @name))) @def
+
+		(function_call name: [
+			(identifier) @callee
+			(dot_index_expression field: (identifier) @callee)
+			(method_index_expression method: (identifier) @callee)
+		]) @call
+
+		(function_call
+			name: (identifier) @_require
+			arguments: (arguments (string) @import)
+			(#eq? @_require "require"))
 	`,
 }
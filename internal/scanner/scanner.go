@@ -2,10 +2,14 @@ package scanner
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	tslua "github.com/tree-sitter-grammars/tree-sitter-lua/bindings/go"
 	tszig "github.com/tree-sitter-grammars/tree-sitter-zig/bindings/go"
@@ -25,12 +29,19 @@ type Scanner struct {
 	languages map[string]*sitter.Language
 	queries   map[string]*sitter.Query
 	root      string
+	goModule  string // module path from root/go.mod, for resolving imports
+	pool      *parserPool
+	trees     *treeCache // trees kept by Reparse
+	workers   int        // files parsed at once
 }
 
 func New() (*Scanner, error) {
 	s := &Scanner{
 		languages: make(map[string]*sitter.Language),
 		queries:   make(map[string]*sitter.Query),
+		pool:      newParserPool(),
+		trees:     &treeCache{},
+		workers:   runtime.GOMAXPROCS(0),
 	}
 
 	// Register languages
@@ -59,6 +70,22 @@ func New() (*Scanner, error) {
 	return s, nil
 }
 
+// SetWorkers sets how many files Scan and ScanFiles parse at once. Below 1
+// means one per CPU.
+func (s *Scanner) SetWorkers(n int) {
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	s.workers = n
+}
+
+// Close frees the idle parsers and query cursors of the scanner and the
+// trees kept by Reparse.
+func (s *Scanner) Close() {
+	s.pool.close()
+	s.trees.close()
+}
+
 func getLangKey(ext string) string {
 	switch ext {
 	case "go":
@@ -84,43 +111,107 @@ func getLangKey(ext string) string {
 
 // ScanFile scans a single file and returns its nodes.
 func (s *Scanner) ScanFile(ctx context.Context, path string) ([]*graph.Node, error) {
-	relPath := path
-	if s.root != "" {
-		if rel, err := filepath.Rel(s.root, path); err == nil {
-			relPath = rel
-		}
+	ext, err := s.sourceExt(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	return s.parseFile(path, ext, content)
+}
+
+// sourceExt returns the extension of a file the scanner can parse.
+func (s *Scanner) sourceExt(path string) (string, error) {
 	ext := strings.TrimPrefix(filepath.Ext(path), ".")
-	lang, ok := s.languages[ext]
-	if !ok {
-		return nil, fmt.Errorf("unsupported file extension: %s", ext)
+	if _, ok := s.languages[ext]; !ok {
+		return "", fmt.Errorf("unsupported file extension: %s", ext)
 	}
+	if _, ok := s.queries[ext]; !ok {
+		return "", fmt.Errorf("no query for extension: %s", ext)
+	}
+	return ext, nil
+}
 
-	query, ok := s.queries[ext]
-	if !ok {
-		return nil, fmt.Errorf("no query for extension: %s", ext)
+// Stat describes a source file for the files table. When prev, the record
+// stored for the path, has the same size and modification time, the file is
+// taken to be unchanged and prev's hash is reused without reading it.
+func (s *Scanner) Stat(path string, prev *graph.File) (*graph.File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &graph.File{
+		Path:     path,
+		Language: getLangKey(strings.TrimPrefix(filepath.Ext(path), ".")),
+		Size:     info.Size(),
+		ModTime:  info.ModTime().UnixNano(),
+	}
+	if prev != nil && prev.Size == f.Size && prev.ModTime == f.ModTime {
+		f.Hash = prev.Hash
+		return f, nil
 	}
 
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	f.Hash = sourceHash(content)
+	return f, nil
+}
 
-	parser := sitter.NewParser()
-	parser.SetLanguage(lang)
+// sourceHash returns the hex SHA-256 of a file or definition's source.
+func sourceHash(source []byte) string {
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])
+}
 
-	tree := parser.Parse(content, nil)
+// parseFile extracts the definitions in content. Each node is identified by
+// its qualified name: module or package, enclosing scopes, the symbol name
+// and, for repeated definitions in the same file, an ordinal discriminator.
+// The last node stands for the file itself and carries its imports.
+func (s *Scanner) parseFile(path, ext string, content []byte) ([]*graph.Node, error) {
+	tree := s.parse(ext, content, nil)
 	if tree == nil {
 		return nil, fmt.Errorf("failed to parse file")
 	}
 	defer tree.Close()
 
-	qc := sitter.NewQueryCursor()
-	defer qc.Close()
+	nodes, _ := s.extract(path, ext, content, tree)
+	return nodes, nil
+}
+
+// parse parses content with a pooled parser. A non-nil old tree, edited to
+// match content, lets the parser reuse its unchanged parts.
+func (s *Scanner) parse(ext string, content []byte, old *sitter.Tree) *sitter.Tree {
+	parser := s.pool.parser(ext, s.languages[ext])
+	defer s.pool.putParser(ext, parser)
+	return parser.Parse(content, old)
+}
+
+// extract returns the nodes of a parsed file, as parseFile does, and the byte
+// range of each definition, aligned with the nodes but for the file node.
+func (s *Scanner) extract(path, ext string, content []byte, tree *sitter.Tree) ([]*graph.Node, []span) {
+	relPath := s.relPath(path)
+
+	query := s.queries[ext]
+	langKey := getLangKey(ext)
+
+	qc := s.pool.cursor()
+	defer s.pool.putCursor(qc)
+
+	root := tree.RootNode()
+	module := moduleName(langKey, relPath, root, content)
+	seen := make(map[string]int)
 
 	var nodes []*graph.Node
-	matches := qc.Matches(query, tree.RootNode(), content)
+	var spans []span
+	var calls []callMatch
+	var imports []importSpec
+	matches := qc.Matches(query, root, content)
 	captureNames := query.CaptureNames()
 
 	for {
@@ -143,6 +234,10 @@ func (s *Scanner) ScanFile(ctx context.Context, path string) ([]*graph.Node, err
 			case "def":
 				defNode = capture.Node
 				foundDef = true
+			case "callee":
+				calls = append(calls, callMatch{site: callSite(capture.Node, content), offset: capture.Node.StartByte()})
+			case "import":
+				imports = append(imports, newImportSpec(capture.Node, content))
 			}
 		}
 
@@ -157,36 +252,279 @@ func (s *Scanner) ScanFile(ctx context.Context, path string) ([]*graph.Node, err
 				rangeNode = *parentNode
 			}
 
+			qualified := qualifiedName(module, containerNames(langKey, &rangeNode, content), name)
+			seen[qualified]++
+			if n := seen[qualified]; n > 1 {
+				qualified = fmt.Sprintf("%s#%d", qualified, n)
+			}
+
 			startPos := nameNode.StartPosition()
 			endPos := rangeNode.EndPosition()
 			nodes = append(nodes, &graph.Node{
-				ID:        util.GenerateNodeID(relPath, name),
-				Name:      name,
-				Kind:      kind,
-				FilePath:  path,
-				LineStart: int(startPos.Row) + 1,
-				LineEnd:   int(endPos.Row) + 1,
-				ColStart:  int(startPos.Column) + 1,
-				ColEnd:    int(endPos.Column) + 1,
-				SymbolURI: util.PathToURI(path),
+				ID:            util.GenerateNodeID(relPath, qualified),
+				Name:          name,
+				QualifiedName: qualified,
+				Kind:          kind,
+				SymbolKind:    symbolKind(langKey, &rangeNode, kind),
+				FilePath:      path, // Absolute path for LSP compatibility
+				LineStart:     int(startPos.Row) + 1,
+				LineEnd:       int(endPos.Row) + 1,
+				ColStart:      int(startPos.Column) + 1,
+				ColEnd:        int(endPos.Column) + 1,
+				SymbolURI:     util.PathToURI(path),
+				Signature:     signature(langKey, &rangeNode, content),
+				Doc:           docComment(langKey, &rangeNode, content),
+				Supertypes:    supertypes(langKey, &rangeNode, content),
 			})
+			sp := span{start: rangeNode.StartByte(), end: rangeNode.EndByte()}
+			nodes[len(nodes)-1].Hash = sourceHash(content[sp.start:sp.end])
+			spans = append(spans, sp)
 		}
 	}
 
-	return nodes, nil
+	// Attribute each call to the innermost definition containing it. Calls
+	// outside any definition (package-level initializers) have no caller.
+	for _, c := range calls {
+		best := -1
+		for i, sp := range spans {
+			if c.offset >= sp.start && c.offset < sp.end && (best < 0 || sp.end-sp.start < spans[best].end-spans[best].start) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			nodes[best].Calls = append(nodes[best].Calls, c.site)
+		}
+	}
+
+	file := s.fileNode(path, langKey, root, imports)
+	file.Hash = sourceHash(content)
+	setParents(langKey, nodes, spans, file)
+	return append(nodes, file), spans
+}
+
+// setParents records the parent of each definition: the innermost definition
+// strictly containing it, else for a Go method the receiver type when it is
+// declared in the same file, else the file.
+func setParents(langKey string, nodes []*graph.Node, spans []span, file *graph.Node) {
+	byName := make(map[string]*graph.Node, len(nodes))
+	for _, n := range nodes {
+		byName[n.QualifiedName] = n
+	}
+	for i, n := range nodes {
+		n.ParentID = file.ID
+		best := -1
+		for j, sp := range spans {
+			if sp.start <= spans[i].start && sp.end >= spans[i].end && sp.end-sp.start > spans[i].end-spans[i].start &&
+				(best < 0 || sp.end-sp.start < spans[best].end-spans[best].start) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			n.ParentID = nodes[best].ID
+			continue
+		}
+		if langKey == "go" && n.Kind == "method_declaration" {
+			if dot := strings.LastIndex(n.QualifiedName, "."); dot >= 0 {
+				if recv := byName[n.QualifiedName[:dot]]; recv != nil {
+					n.ParentID = recv.ID
+				}
+			}
+		}
+	}
+}
+
+// fileNode returns the node for the file at path, spanning all of it, with
+// its imports resolved to the nodes of the imported files.
+func (s *Scanner) fileNode(path, langKey string, root *sitter.Node, imports []importSpec) *graph.Node {
+	relPath := s.relPath(path)
+	qualified := filepath.ToSlash(relPath)
+	endPos := root.EndPosition()
+	n := &graph.Node{
+		ID:            util.GenerateNodeID(relPath, qualified),
+		Name:          filepath.Base(path),
+		QualifiedName: qualified,
+		Kind:          graph.KindFile,
+		SymbolKind:    graph.SymbolModule,
+		FilePath:      path,
+		LineStart:     1,
+		LineEnd:       int(endPos.Row) + 1,
+		ColStart:      1,
+		ColEnd:        int(endPos.Column) + 1,
+		SymbolURI:     util.PathToURI(path),
+	}
+
+	seen := make(map[string]bool)
+	for _, imp := range imports {
+		for _, target := range s.resolveImport(langKey, path, imp) {
+			rel := s.relPath(target)
+			id := util.GenerateNodeID(rel, filepath.ToSlash(rel))
+			if id == n.ID || seen[id] {
+				continue
+			}
+			seen[id] = true
+			n.Imports = append(n.Imports, graph.Import{TargetID: id, Line: imp.line, Col: imp.col})
+		}
+	}
+	return n
+}
+
+// relPath returns path relative to the scan root, which node IDs are built
+// from.
+func (s *Scanner) relPath(path string) string {
+	if s.root != "" {
+		if rel, err := filepath.Rel(s.root, path); err == nil {
+			return rel
+		}
+	}
+	return path
+}
+
+// span is the byte range of a definition, parallel to the nodes slice.
+type span struct {
+	start, end uint
+}
+
+// callMatch is a call site waiting to be attributed to its caller.
+type callMatch struct {
+	site   graph.CallSite
+	offset uint
+}
+
+// qualifierFields are the fields naming the object a member is selected from,
+// across the supported grammars.
+var qualifierFields = []string{"operand", "object", "table"}
+
+// callSite describes the call whose callee identifier is n.
+func callSite(n sitter.Node, content []byte) graph.CallSite {
+	pos := n.StartPosition()
+	site := graph.CallSite{
+		Name: n.Utf8Text(content),
+		Line: int(pos.Row) + 1,
+		Col:  int(pos.Column) + 1,
+	}
+	if parent := n.Parent(); parent != nil {
+		for _, field := range qualifierFields {
+			if q := parent.ChildByFieldName(field); q != nil && q.Id() != n.Id() {
+				site.Qualifier = q.Utf8Text(content)
+				break
+			}
+		}
+	}
+	return site
 }
 
+// Scan parses every source file under root and returns their nodes, in walk
+// order. Files are parsed by a pool of workers while the walk goes on.
 func (s *Scanner) Scan(ctx context.Context, root string) ([]*graph.Node, error) {
-	s.root = root
+	paths := make(chan string)
+	var walkErr error
+	go func() {
+		defer close(paths)
+		walkErr = s.walk(ctx, root, func(path string) {
+			paths <- path
+		})
+	}()
+
 	var nodes []*graph.Node
+	for _, fileNodes := range s.parseFiles(ctx, paths) {
+		nodes = append(nodes, fileNodes...)
+	}
+	return nodes, walkErr
+}
+
+// ScanFiles parses the given files on a pool of workers and returns the
+// nodes of each, aligned with paths: nil for a file that could not be read
+// or parsed.
+func (s *Scanner) ScanFiles(ctx context.Context, paths []string) [][]*graph.Node {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, path := range paths {
+			ch <- path
+		}
+	}()
+	results := s.parseFiles(ctx, ch)
+	for len(results) < len(paths) {
+		results = append(results, nil)
+	}
+	return results
+}
+
+// parseFiles parses the files received from paths with s.workers workers and
+// returns their nodes in the order the paths were received.
+func (s *Scanner) parseFiles(ctx context.Context, paths <-chan string) [][]*graph.Node {
+	type job struct {
+		i    int
+		path string
+	}
+	type result struct {
+		i     int
+		nodes []*graph.Node
+	}
+	jobs := make(chan job)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for w := 0; w < s.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				var nodes []*graph.Node
+				if ctx.Err() == nil {
+					nodes, _ = s.ScanFile(ctx, j.path) // Skip unreadable files
+				}
+				results <- result{j.i, nodes}
+			}
+		}()
+	}
+	go func() {
+		i := 0
+		for path := range paths {
+			jobs <- job{i, path}
+			i++
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var out [][]*graph.Node
+	for r := range results {
+		for len(out) <= r.i {
+			out = append(out, nil)
+		}
+		out[r.i] = r.nodes
+	}
+	return out
+}
+
+// Files returns the source files under root in walk order, skipping hidden,
+// vendored and gitignored paths, and makes root the directory node IDs are
+// relative to.
+func (s *Scanner) Files(ctx context.Context, root string) ([]string, error) {
+	var paths []string
+	err := s.walk(ctx, root, func(path string) {
+		paths = append(paths, path)
+	})
+	return paths, err
+}
+
+// walk calls fn with each source file under root, as listed by Files.
+func (s *Scanner) walk(ctx context.Context, root string, fn func(path string)) error {
+	s.root = root
+	s.goModule = readGoModule(root)
 
 	// Load gitignore
 	ign, _ := ignore.CompileIgnoreFile(filepath.Join(root, ".gitignore"))
 
-	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		// Skip hidden files and common ignore dirs
 		if strings.HasPrefix(d.Name(), ".") && d.Name() != "." && d.Name() != ".gitignore" {
@@ -214,92 +552,14 @@ func (s *Scanner) Scan(ctx context.Context, root string) ([]*graph.Node, error)
 
 		// Check extension
 		ext := strings.TrimPrefix(filepath.Ext(path), ".")
-		lang, ok := s.languages[ext]
-		if !ok {
+		if _, ok := s.languages[ext]; !ok {
 			return nil
 		}
-		query, ok := s.queries[ext]
-		if !ok {
-			return nil
-		}
-
-		// Parse
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return nil // Skip unreadable files
-		}
-
-		parser := sitter.NewParser()
-		parser.SetLanguage(lang)
-		tree := parser.Parse(content, nil)
-		if tree == nil {
+		if _, ok := s.queries[ext]; !ok {
 			return nil
 		}
-		defer tree.Close()
-
-		rootNode := tree.RootNode()
-		qc := sitter.NewQueryCursor()
-		defer qc.Close()
-
-		matches := qc.Matches(query, rootNode, content)
-		captureNames := query.CaptureNames()
-
-		for {
-			match := matches.Next()
-			if match == nil {
-				break
-			}
-
-			var nameNode sitter.Node
-			var defNode sitter.Node
-			var foundName bool
-			var foundDef bool
-			var kind string = "symbol"
-
-			for _, capture := range match.Captures {
-				cName := captureNames[capture.Index]
-
-				if cName == "name" {
-					nameNode = capture.Node
-					foundName = true
-				} else if cName == "def" {
-					defNode = capture.Node
-					foundDef = true
-				}
-			}
-
-			if foundName {
-				// Extract content
-				name := nameNode.Utf8Text(content)
-
-				// simple kind inference
-				rangeNode := nameNode
-				if foundDef {
-					kind = defNode.Kind()
-					rangeNode = defNode
-				} else if parentNode := nameNode.Parent(); parentNode != nil {
-					kind = parentNode.Kind()
-					rangeNode = *parentNode
-				}
-
-				startPos := nameNode.StartPosition()
-				endPos := rangeNode.EndPosition()
-				node := &graph.Node{
-					ID:        util.GenerateNodeID(relPath, name),
-					Name:      name,
-					Kind:      kind,
-					FilePath:  path, // Store absolute path for LSP compatibility
-					LineStart: int(startPos.Row) + 1,
-					LineEnd:   int(endPos.Row) + 1,
-					ColStart:  int(startPos.Column) + 1,
-					ColEnd:    int(endPos.Column) + 1,
-				}
-				nodes = append(nodes, node)
-			}
-		}
 
+		fn(path)
 		return nil
 	})
-
-	return nodes, err
 }
@@ -0,0 +1,174 @@
+package scanner
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	sitter "github.com/tree-sitter/go-tree-sitter"
+
+	"codemap/util"
+)
+
+// maxSignatureLength caps signatures, in runes, so that a field or variable
+// initialised with a long expression does not carry all of it.
+const maxSignatureLength = 300
+
+// signature returns the declaration of a definition without its body, on one
+// line: the parameters and result types of a function, the base types of a
+// class, the type of a field.
+func signature(langKey string, def *sitter.Node, content []byte) string {
+	start, end := def.StartByte(), def.EndByte()
+	prefix, suffix := "", ""
+	value := def.ChildByFieldName("value")
+	if value == nil {
+		value = def.ChildByFieldName("right")
+	}
+	switch {
+	case langKey == "go" && def.Kind() == "type_spec":
+		// type Name struct, not the field list.
+		prefix = "type "
+		if t := def.ChildByFieldName("type"); t != nil {
+			switch t.Kind() {
+			case "struct_type", "interface_type":
+				end = t.StartByte() + uint(len(strings.TrimSuffix(t.Kind(), "_type")))
+			}
+		}
+	case langKey == "go" && def.Kind() == "const_spec":
+		prefix = "const "
+	case langKey == "zig" && def.Kind() == "variable_declaration":
+		// const Name = struct, not the container's members.
+		if c := zigContainer(def); c != nil {
+			end = c.StartByte() + uint(len(strings.TrimSuffix(c.Kind(), "_declaration")))
+		}
+	case def.ChildByFieldName("body") != nil:
+		end = def.ChildByFieldName("body").StartByte()
+	case value != nil && value.ChildByFieldName("body") != nil:
+		// A variable or field holding a function or class.
+		end = value.ChildByFieldName("body").StartByte()
+	case value != nil && value.StartPosition().Row != value.EndPosition().Row:
+		// A variable or field initialised with a literal spanning lines.
+		end, suffix = value.StartByte(), " ..."
+	case langKey == "lua" && def.ChildByFieldName("parameters") != nil:
+		// The grammar leaves out the body of an empty function.
+		end = def.ChildByFieldName("parameters").EndByte()
+	}
+
+	sig := prefix + util.OneLine(string(content[start:end]))
+	sig = strings.TrimRight(sig, " ;{")
+	if langKey == "python" {
+		sig = strings.TrimSuffix(sig, ":")
+	}
+	sig += suffix
+	if utf8.RuneCountInString(sig) > maxSignatureLength {
+		sig = string([]rune(sig)[:maxSignatureLength]) + "..."
+	}
+	return sig
+}
+
+// docComment returns the documentation of a definition: the docstring of a
+// Python function or class, otherwise the comment lines directly above the
+// declaration, without comment markers.
+func docComment(langKey string, def *sitter.Node, content []byte) string {
+	if langKey == "python" {
+		return docstring(def, content)
+	}
+
+	// Comments sit above the whole declaration: the export statement
+	// around a class, the const around a variable, the type keyword
+	// around a Go type spec.
+	n := def
+	for {
+		parent := n.Parent()
+		if parent == nil || !declarationWrappers[parent.Kind()] || parent.NamedChild(0).Id() != n.Id() {
+			break
+		}
+		n = parent
+	}
+
+	var lines []string
+	for prev := n.PrevNamedSibling(); prev != nil && prev.Kind() == "comment"; prev = prev.PrevNamedSibling() {
+		// Stop at a blank line and at a comment trailing code.
+		if prev.EndPosition().Row+1 < n.StartPosition().Row {
+			break
+		}
+		if before := prev.PrevSibling(); before != nil && before.EndPosition().Row == prev.StartPosition().Row {
+			break
+		}
+		lines = append(commentLines(prev.Utf8Text(content)), lines...)
+		n = prev
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// declarationWrappers are the nodes that wrap a definition without adding a
+// doc comment of their own.
+var declarationWrappers = map[string]bool{
+	"type_declaration":     true,
+	"const_declaration":    true,
+	"export_statement":     true,
+	"lexical_declaration":  true,
+	"variable_declaration": true,
+}
+
+// commentLines strips the markers from a line or block comment.
+func commentLines(text string) []string {
+	switch {
+	case strings.HasPrefix(text, "/*"):
+		text = strings.TrimSuffix(strings.TrimLeft(text, "/*!"), "*/")
+	case strings.HasPrefix(text, "--[["):
+		text = strings.TrimSuffix(strings.TrimLeft(text, "-[="), "]]")
+	default:
+		text = strings.TrimLeft(text, "/-!")
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(lines) > 1 {
+			line = strings.TrimPrefix(line, "*")
+		}
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+	return lines
+}
+
+// docstring returns the docstring of a Python function or class: a string
+// as the first statement of its body, with its indentation removed.
+func docstring(def *sitter.Node, content []byte) string {
+	body := def.ChildByFieldName("body")
+	if body == nil || body.NamedChildCount() == 0 {
+		return ""
+	}
+	stmt := body.NamedChild(0)
+	if stmt.Kind() != "expression_statement" || stmt.NamedChildCount() == 0 || stmt.NamedChild(0).Kind() != "string" {
+		return ""
+	}
+
+	text := strings.TrimLeft(stmt.NamedChild(0).Utf8Text(content), "rRuUbBfF")
+	for _, quote := range []string{`"""`, `'''`, `"`, `'`} {
+		if strings.HasPrefix(text, quote) && strings.HasSuffix(text, quote) && len(text) >= 2*len(quote) {
+			text = text[len(quote) : len(text)-len(quote)]
+			break
+		}
+	}
+
+	// Like inspect.cleandoc: the first line is flush with the quotes, the
+	// rest share the body's indentation.
+	lines := strings.Split(text, "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		if trimmed := strings.TrimLeft(line, " \t"); trimmed != "" {
+			if n := len(line) - len(trimmed); indent < 0 || n < indent {
+				indent = n
+			}
+		}
+	}
+	lines[0] = strings.TrimSpace(lines[0])
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= indent && indent > 0 {
+			lines[i] = lines[i][indent:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
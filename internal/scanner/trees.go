@@ -0,0 +1,186 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	sitter "github.com/tree-sitter/go-tree-sitter"
+
+	"codemap/internal/graph"
+)
+
+// maxCachedTrees bounds how many files Reparse keeps parse trees for.
+const maxCachedTrees = 64
+
+// cachedTree is the parse tree of a file and the content it was parsed from.
+type cachedTree struct {
+	path    string
+	ext     string
+	tree    *sitter.Tree
+	content []byte
+}
+
+// treeCache holds the parse trees of the files most recently reparsed, least
+// recently used first. Like parsers, trees hold C memory, so evicted trees
+// are closed.
+type treeCache struct {
+	mu    sync.Mutex
+	trees []*cachedTree
+}
+
+// take removes the tree of path from the cache and returns it, or nil.
+func (c *treeCache) take(path string) *cachedTree {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, t := range c.trees {
+		if t.path == path {
+			c.trees = append(c.trees[:i], c.trees[i+1:]...)
+			return t
+		}
+	}
+	return nil
+}
+
+// put caches t as the most recently used tree, replacing any other tree of
+// its path and evicting the least recently used beyond maxCachedTrees.
+func (c *treeCache) put(t *cachedTree) {
+	c.drop(t.path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trees = append(c.trees, t)
+	for len(c.trees) > maxCachedTrees {
+		c.trees[0].tree.Close()
+		c.trees = c.trees[1:]
+	}
+}
+
+// drop closes and forgets the tree of path, if any.
+func (c *treeCache) drop(path string) {
+	if t := c.take(path); t != nil {
+		t.tree.Close()
+	}
+}
+
+func (c *treeCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.trees {
+		t.tree.Close()
+	}
+	c.trees = nil
+}
+
+// Reparse scans a file like ScanFile, but keeps its parse tree so that the
+// next Reparse of the file applies the edit in between to the tree and only
+// reparses what it changed. edited holds the IDs of the nodes whose source
+// the edit touched, the file node's included; it is nil when there was no
+// tree to compare with, as for the first edit after a restart, and only the
+// nodes' hashes can tell what changed. A changed doc comment lies outside the
+// node it documents and is not reported.
+func (s *Scanner) Reparse(ctx context.Context, path string) (nodes []*graph.Node, edited map[string]bool, err error) {
+	ext, err := s.sourceExt(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	prev := s.trees.take(path)
+	if prev != nil && prev.ext != ext {
+		prev.tree.Close()
+		prev = nil
+	}
+
+	var tree *sitter.Tree
+	var changed []span
+	if prev != nil {
+		edit, ok := inputEdit(prev.content, content)
+		if ok {
+			prev.tree.Edit(edit)
+			changed = append(changed, span{start: edit.StartByte, end: edit.NewEndByte})
+		}
+		tree = s.parse(ext, content, prev.tree)
+		if tree != nil && ok {
+			for _, r := range prev.tree.ChangedRanges(tree) {
+				changed = append(changed, span{start: r.StartByte, end: r.EndByte})
+			}
+		}
+		prev.tree.Close()
+	} else {
+		tree = s.parse(ext, content, nil)
+	}
+	if tree == nil {
+		return nil, nil, fmt.Errorf("failed to parse file")
+	}
+
+	nodes, spans := s.extract(path, ext, content, tree)
+	s.trees.put(&cachedTree{path: path, ext: ext, tree: tree, content: content})
+	if prev == nil {
+		return nodes, nil, nil
+	}
+
+	edited = make(map[string]bool)
+	for i, n := range nodes {
+		// The file node, last, spans the whole file.
+		if i == len(spans) {
+			if len(changed) > 0 {
+				edited[n.ID] = true
+			}
+			continue
+		}
+		for _, c := range changed {
+			if c.start <= spans[i].end && spans[i].start <= c.end {
+				edited[n.ID] = true
+				break
+			}
+		}
+	}
+	return nodes, edited, nil
+}
+
+// Forget drops the parse tree Reparse keeps for path, such as for a deleted
+// file.
+func (s *Scanner) Forget(path string) {
+	s.trees.drop(path)
+}
+
+// inputEdit describes the change from before to after as a single edit
+// spanning everything between their common prefix and suffix. ok is false
+// when they are the same.
+func inputEdit(before, after []byte) (edit *sitter.InputEdit, ok bool) {
+	if bytes.Equal(before, after) {
+		return nil, false
+	}
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	oldEnd, newEnd := len(before)-suffix, len(after)-suffix
+	return &sitter.InputEdit{
+		StartByte:      uint(prefix),
+		OldEndByte:     uint(oldEnd),
+		NewEndByte:     uint(newEnd),
+		StartPosition:  pointAt(before, prefix),
+		OldEndPosition: pointAt(before, oldEnd),
+		NewEndPosition: pointAt(after, newEnd),
+	}, true
+}
+
+// pointAt returns the row and byte column of an offset in content.
+func pointAt(content []byte, offset int) sitter.Point {
+	head := content[:offset]
+	return sitter.Point{
+		Row:    uint(bytes.Count(head, []byte("\n"))),
+		Column: uint(offset - (bytes.LastIndexByte(head, '\n') + 1)),
+	}
+}
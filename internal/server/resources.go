@@ -63,8 +63,19 @@ func buildSchemaMap() map[string]string {
 	addSchema[IndexArgs](m, "index")
 	addSchema[IndexStatusArgs](m, "index_status")
 	addSchema[GetSymbolsInFileArgs](m, "get_symbols_in_file")
+	addSchema[SearchSymbolsArgs](m, "search_symbols")
 	addSchema[FindImpactArgs](m, "find_impact")
+	addSchema[FindDependenciesArgs](m, "find_dependencies")
+	addSchema[FindReferencesArgs](m, "find_references")
+	addSchema[FindPathArgs](m, "find_path")
+	addSchema[GetTypeHierarchyArgs](m, "get_type_hierarchy")
+	addSchema[GetMembersArgs](m, "get_members")
+	addSchema[FindCyclesArgs](m, "find_cycles")
+	addSchema[GetModuleGraphArgs](m, "get_module_graph")
+	addSchema[FindUnusedArgs](m, "find_unused")
 	addSchema[GetSymbolArgs](m, "get_symbol")
+	addSchema[ExportGraphArgs](m, "export_graph")
+	addSchema[CheckIntegrityArgs](m, "check_integrity")
 	return m
 }
 
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"codemap/internal/graph"
+	"codemap/internal/indexer"
 	"codemap/internal/lsp"
 	"codemap/internal/scanner"
 
@@ -27,6 +28,7 @@ type Server struct {
 	scanner      *scanner.Scanner
 	store        *graph.Store
 	lsp          *lsp.Service
+	coord        *indexer.Coordinator
 	mcpServer    *mcp.Server
 	systemPrompt string
 
@@ -38,7 +40,7 @@ type Server struct {
 	indexReady     chan struct{}
 }
 
-func New(scn *scanner.Scanner, store *graph.Store, lspSvc *lsp.Service, systemPrompt string) *Server {
+func New(scn *scanner.Scanner, store *graph.Store, lspSvc *lsp.Service, coord *indexer.Coordinator, systemPrompt string) *Server {
 	s := mcp.NewServer(&mcp.Implementation{
 		Name:    "codemap",
 		Version: "0.1.0",
@@ -48,6 +50,7 @@ func New(scn *scanner.Scanner, store *graph.Store, lspSvc *lsp.Service, systemPr
 		scanner:      scn,
 		store:        store,
 		lsp:          lspSvc,
+		coord:        coord,
 		mcpServer:    s,
 		systemPrompt: systemPrompt,
 		indexStatus:  IndexStatusNotStarted,
@@ -106,50 +109,210 @@ func (s *Server) Run(ctx context.Context) error {
 	return s.mcpServer.Run(ctx, &mcp.StdioTransport{})
 }
 
+// RunInitialIndex brings the graph up to date with the workspace at startup,
+// scanning and enriching only the files changed since the last session.
 func (s *Server) RunInitialIndex(ctx context.Context, projectRoot string) {
 	s.setIndexStatus(IndexStatusInProgress, nil)
-	
-	nodes, err := s.scanner.Scan(ctx, projectRoot)
-	if err != nil {
-		s.setIndexStatus(IndexStatusFailed, fmt.Errorf("scan failed: %w", err))
+
+	if _, err := s.Index(ctx, projectRoot, false); err != nil {
+		s.setIndexStatus(IndexStatusFailed, err)
 		return
 	}
 
-	// COLLECT VALID FILES
-	validFiles := make(map[string]bool)
-	var validFileList []string
-	for _, n := range nodes {
-		if !validFiles[n.FilePath] {
-			validFiles[n.FilePath] = true
-			validFileList = append(validFileList, n.FilePath)
+	s.setIndexStatus(IndexStatusReady, nil)
+}
+
+// IndexResult summarizes an index run. Nodes counts the nodes of the scanned
+// files and Edges the edges stored for those that changed; LSPErr is set if
+// enrichment failed and only heuristic edges were stored.
+type IndexResult struct {
+	Nodes     int
+	Edges     int
+	Scanned   int
+	Unchanged int
+	LSPErr    error
+}
+
+// Index indexes the workspace at root as a coordinator job, so that file
+// re-indexes wait until it is done, and returns what it stored.
+func (s *Server) Index(ctx context.Context, root string, full bool) (*IndexResult, error) {
+	var res *IndexResult
+	err := s.coord.Index(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = s.indexWorkspace(ctx, root, full)
+		return err
+	})
+	return res, err
+}
+
+// indexWorkspace scans the source files under root and stores their nodes
+// and edges. Unless full is set, files whose size and modification time, or
+// failing that content hash, match the files table are left as stored, so
+// only added and changed files are scanned and enriched, along with those
+// the language server failed on last time; with full, every node's edges
+// are found again, whether it changed or not. Files that are gone
+// are removed either way. The calls and overrides edges of unchanged files
+// into what changed are found again.
+func (s *Server) indexWorkspace(ctx context.Context, root string, full bool) (*IndexResult, error) {
+	paths, err := s.scanner.Files(ctx, root)
+	if err != nil {
+		return nil, fmt.Errorf("scan failed: %w", err)
+	}
+	stored := make(map[string]*graph.File)
+	if !full {
+		if stored, err = s.store.Files(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &IndexResult{}
+	var files, changed, retry []*graph.File
+	var changedPaths []string
+	for _, path := range paths {
+		prev := stored[path]
+		f, err := s.scanner.Stat(path, prev)
+		if err != nil {
+			continue // Skip unreadable files
+		}
+		if prev != nil && prev.Hash == f.Hash {
+			res.Unchanged++
+			if !prev.Enriched {
+				retry = append(retry, f)
+			} else if prev.ModTime != f.ModTime || prev.Size != f.Size {
+				f.Enriched = true
+				files = append(files, f)
+			}
+			continue
 		}
+		changed = append(changed, f)
+		changedPaths = append(changedPaths, path)
 	}
 
-	if err := s.store.BulkUpsertNodes(ctx, nodes); err != nil {
-		s.setIndexStatus(IndexStatusFailed, fmt.Errorf("failed to store nodes: %w", err))
-		return
+	// The trees the watcher keeps for changed files no longer match the
+	// nodes stored for them below.
+	for _, path := range changedPaths {
+		s.scanner.Forget(path)
+	}
+
+	// Like the watcher, a changed file keeps the stored nodes and edges of
+	// definitions that did not change, and those that did lose their
+	// outgoing edges, which are found again below.
+	var nodes, removed []*graph.Node
+	var scanned []*graph.File
+	for i, fileNodes := range s.scanner.ScanFiles(ctx, changedPaths) {
+		if fileNodes == nil {
+			continue
+		}
+		res.Scanned++
+		res.Nodes += len(fileNodes)
+		updated, gone, err := s.store.UpdateFileNodes(ctx, changedPaths[i], fileNodes, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store nodes of %s: %w", changedPaths[i], err)
+		}
+		if full {
+			// A forced index finds the edges of every node again, so that
+			// those stored without a language server are upgraded and stale
+			// heuristic calls are dropped.
+			if err := s.store.ClearHeuristicCalls(ctx, fileNodes); err != nil {
+				return nil, err
+			}
+			updated = fileNodes
+		}
+		nodes = append(nodes, updated...)
+		removed = append(removed, gone...)
+		scanned = append(scanned, changed[i])
+	}
+
+	// The language server failed on the nodes of the retried files when they
+	// were last indexed; they are enriched again, with their stored call
+	// sites, while their heuristic edges are kept.
+	var pending []*graph.Node
+	for _, f := range retry {
+		fileNodes, err := s.store.GetSymbolsInFile(ctx, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range fileNodes {
+			if n.Calls, err = s.store.GetCallSites(ctx, n.ID); err != nil {
+				return nil, err
+			}
+		}
+		pending = append(pending, fileNodes...)
 	}
 
 	// PRUNE STALE DATA
-	if err := s.store.PruneStaleFiles(ctx, validFileList); err != nil {
+	pruned, err := s.store.PruneStaleFiles(ctx, paths)
+	if err != nil {
 		// Log warning but don't fail
 		fmt.Fprintf(os.Stderr, "Warning: Failed to prune stale files: %v\n", err)
 	}
+	removed = append(removed, pruned...)
+
+	if len(nodes) == 0 && len(pending) == 0 {
+		if _, err := s.store.RefreshDependents(ctx, nil, removed); err != nil {
+			return nil, err
+		}
+		return res, s.store.UpsertFiles(ctx, files)
+	}
 
-	edges, err := s.lsp.Enrich(ctx, nodes, s.store)
+	edges, err := s.store.HeuristicEdges(ctx, nodes)
 	if err != nil {
-		s.setIndexStatus(IndexStatusFailed, fmt.Errorf("LSP enrichment failed: %w", err))
-		return
+		return nil, fmt.Errorf("heuristic resolution failed: %w", err)
+	}
+
+	// A language server reports a reference from the changed code to another
+	// definition only when asked for the references to that definition, so
+	// those it calls or derives from are enriched along with the changed
+	// nodes. LSP edges are stored after the heuristic ones so that they
+	// upgrade them.
+	pending = append(pending, nodes...)
+	mentioned, err := s.store.MentionedNodes(ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("finding mentioned definitions failed: %w", err)
+	}
+	enrich := append(pending, mentioned...)
+	lspEdges, lspErr := s.lsp.Enrich(ctx, enrich, s.store)
+	if lspErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: LSP enrichment failed, keeping heuristic edges only: %v\n", lspErr)
+		res.LSPErr = lspErr
+	} else if err := s.store.UpdateSignatures(ctx, enrich); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to store LSP signatures: %v\n", err)
 	}
+	edges = append(edges, lspEdges...)
 
 	if err := s.store.BulkUpsertEdges(ctx, edges); err != nil {
-		s.setIndexStatus(IndexStatusFailed, fmt.Errorf("failed to store edges: %w", err))
-		return
+		return nil, fmt.Errorf("failed to store edges: %w", err)
 	}
 
-	s.setIndexStatus(IndexStatusReady, nil)
-}
+	// Overrides follow the hierarchy edges just stored.
+	overrides, err := s.store.OverrideEdges(ctx, nodes)
+	if err == nil {
+		err = s.store.BulkUpsertEdges(ctx, overrides)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store overrides: %w", err)
+	}
 
+	// Calls from unchanged files to the names defined or removed may
+	// resolve differently now, and their subtypes may override other
+	// methods.
+	dependent, err := s.store.RefreshDependents(ctx, nodes, removed)
+	if err != nil {
+		return nil, err
+	}
+	res.Edges = len(edges) + len(overrides) + len(dependent)
+
+	// Files the language server failed on are recorded as such, so that the
+	// next index enriches them again.
+	for _, f := range append(scanned, retry...) {
+		f.Enriched = lspErr == nil
+		files = append(files, f)
+	}
+	if err := s.store.UpsertFiles(ctx, files); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
 
 func textResult(text string) *mcp.CallToolResult {
 	return &mcp.CallToolResult{
@@ -5,11 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"codemap/internal/export"
 	"codemap/internal/graph"
+	"codemap/internal/lsp"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -17,24 +21,104 @@ import (
 // Arguments structs
 
 type IndexArgs struct {
-	Force bool `json:"force" jsonschema:"description:Force a full re-index even if no changes are detected"`
+	Force bool `json:"force" jsonschema:"description:Re-scan and re-enrich every file, not only those added or changed since the last index"`
 }
 
 type IndexStatusArgs struct{}
 
 type GetSymbolsInFileArgs struct {
-	FilePath string `json:"file_path" jsonschema:"required,description:The absolute path to the file to analyze"`
+	FilePath string   `json:"file_path" jsonschema:"required,description:The absolute path to the file to analyze"`
+	Kinds    []string `json:"kinds,omitempty" jsonschema:"description:Only return symbols of these kinds: function, method, class, interface, struct, enum, constant, variable, field, module or a node kind such as function_declaration"`
+}
+
+type SearchSymbolsArgs struct {
+	Query    string   `json:"query" jsonschema:"required,description:Part of a symbol name in any case: a prefix (proc), words of a camelCase or snake_case name (req handler), a substring or a misspelling"`
+	Kinds    []string `json:"kinds,omitempty" jsonschema:"description:Only return symbols of these kinds: function, method, class, interface, struct, enum, constant, variable, field, module or a node kind such as function_declaration"`
+	Language string   `json:"language,omitempty" jsonschema:"description:Only return symbols from files of this language: go, python, javascript, typescript, lua, zig"`
+	PathGlob string   `json:"path_glob,omitempty" jsonschema:"description:Only return symbols whose file path matches this glob (e.g. */internal/*)"`
+	Limit    int      `json:"limit,omitempty" jsonschema:"description:Maximum number of results, best first (default 20)"`
 }
 
 type FindImpactArgs struct {
-	SymbolName string `json:"symbol_name" jsonschema:"required,description:The name of the symbol to analyze for impact"`
+	SymbolName string   `json:"symbol_name" jsonschema:"required,description:The name of the symbol to analyze for impact, bare (Run) or qualified (server.Server.Run)"`
+	MaxDepth   int      `json:"max_depth,omitempty" jsonschema:"description:Maximum number of hops from the symbol (0 = unlimited)"`
+	Relations  []string `json:"relations,omitempty" jsonschema:"description:Only follow these relations: calls, references, implements, extends, overrides, imports, contains (default: all but contains)"`
+	Kinds      []string `json:"kinds,omitempty" jsonschema:"description:Only report symbols of these kinds: function, method, class, interface, struct, enum, constant, variable, field, module or a node kind; the traversal still passes through other kinds"`
+	Limit      int      `json:"limit,omitempty" jsonschema:"description:Maximum number of results, nearest first (0 = unlimited)"`
 }
 
 type GetSymbolArgs struct {
-	SymbolName string `json:"symbol_name" jsonschema:"required,description:The name of the symbol to locate"`
-	WithSource bool   `json:"with_source" jsonschema:"description:If true, includes the source code of the symbol in the response"`
+	SymbolName string   `json:"symbol_name" jsonschema:"required,description:The name of the symbol to locate, bare (Run) or qualified (server.Server.Run)"`
+	WithSource bool     `json:"with_source" jsonschema:"description:If true, includes the source code of the symbol in the response; the signature and doc comment are always included"`
+	Kinds      []string `json:"kinds,omitempty" jsonschema:"description:Only return definitions of these kinds: function, method, class, interface, struct, enum, constant, variable, field, module or a node kind"`
+}
+
+type FindDependenciesArgs struct {
+	SymbolName string   `json:"symbol_name" jsonschema:"required,description:The name of the symbol whose dependencies to list, bare (Run) or qualified (server.Server.Run)"`
+	MaxDepth   int      `json:"max_depth,omitempty" jsonschema:"description:Maximum number of hops from the symbol (0 = unlimited)"`
+	Relations  []string `json:"relations,omitempty" jsonschema:"description:Only follow these relations: calls, references, implements, extends, overrides, imports, contains (default: all but contains)"`
+	Kinds      []string `json:"kinds,omitempty" jsonschema:"description:Only report symbols of these kinds: function, method, class, interface, struct, enum, constant, variable, field, module or a node kind; the traversal still passes through other kinds"`
+	Limit      int      `json:"limit,omitempty" jsonschema:"description:Maximum number of results, nearest first (0 = unlimited)"`
+}
+
+type FindReferencesArgs struct {
+	SymbolName string   `json:"symbol_name" jsonschema:"required,description:The symbol whose uses to list, bare (Run) or qualified (server.Server.Run)"`
+	Relations  []string `json:"relations,omitempty" jsonschema:"description:Only report uses through these relations: calls, references, implements, extends, overrides, imports (default: all)"`
+	Kinds      []string `json:"kinds,omitempty" jsonschema:"description:Only report uses inside symbols of these kinds: function, method, class, module or a node kind"`
+	Limit      int      `json:"limit,omitempty" jsonschema:"description:Maximum number of uses, in file and line order (0 = unlimited)"`
+}
+
+type FindPathArgs struct {
+	FromSymbol string   `json:"from_symbol" jsonschema:"required,description:The symbol the chain starts at (e.g. an HTTP handler), bare or qualified"`
+	ToSymbol   string   `json:"to_symbol" jsonschema:"required,description:The symbol the chain ends at (e.g. a database function), bare or qualified"`
+	Relations  []string `json:"relations,omitempty" jsonschema:"description:Only follow these relations: calls, references, implements, extends, overrides, imports, contains (default: all but contains)"`
+	MaxLength  int      `json:"max_length,omitempty" jsonschema:"description:Maximum number of hops in a path (default 10)"`
+	MaxPaths   int      `json:"max_paths,omitempty" jsonschema:"description:Number of distinct paths to return, shortest first (default 1)"`
+}
+
+type GetTypeHierarchyArgs struct {
+	SymbolName string `json:"symbol_name" jsonschema:"required,description:The class or interface whose hierarchy to show, bare (Animal) or qualified (models.Animal)"`
+	MaxDepth   int    `json:"max_depth,omitempty" jsonschema:"description:Maximum number of levels above and below the type (0 = unlimited)"`
+}
+
+type GetMembersArgs struct {
+	SymbolName string   `json:"symbol_name" jsonschema:"required,description:The class, struct or interface whose members to list, bare (Server) or qualified (server.Server)"`
+	Kinds      []string `json:"kinds,omitempty" jsonschema:"description:Only return members of these kinds: method, field, function, class or a node kind such as method_declaration"`
+}
+
+type FindCyclesArgs struct {
+	Level     string   `json:"level,omitempty" jsonschema:"description:Which cycles to report: symbol, package or both (default both)"`
+	Relations []string `json:"relations,omitempty" jsonschema:"description:Only consider these relations: calls, references, implements, extends, overrides, imports, contains (default: all but contains)"`
+	MinSize   int      `json:"min_size,omitempty" jsonschema:"description:Only report cycles with at least this many members (default 2)"`
+	Limit     int      `json:"limit,omitempty" jsonschema:"description:Maximum number of cycles per level, largest first (default 20)"`
+}
+
+type GetModuleGraphArgs struct {
+	Relations []string `json:"relations,omitempty" jsonschema:"description:Relations that make one package depend on another: calls, references, implements, extends, overrides, imports (default: imports)"`
+	Dir       string   `json:"dir,omitempty" jsonschema:"description:Only include packages under this absolute directory"`
 }
 
+type FindUnusedArgs struct {
+	Kinds           []string `json:"kinds,omitempty" jsonschema:"description:Only report symbols of these kinds (default: function, method, class, interface and struct, whose references the language servers resolve)"`
+	EntryPoints     []string `json:"entry_points,omitempty" jsonschema:"description:Symbol names that are used from outside the graph and never reported (default: main, init, __init__, __main__, constructor)"`
+	IncludeTests    bool     `json:"include_tests,omitempty" jsonschema:"description:If true, also reports symbols in test files and test functions"`
+	IncludeExported bool     `json:"include_exported,omitempty" jsonschema:"description:If true, also reports the exported API of library packages"`
+	ExcludePatterns []string `json:"exclude_patterns,omitempty" jsonschema:"description:Glob patterns matched against symbol names, qualified names and file paths to skip (e.g. Handle*, */generated/*)"`
+	Limit           int      `json:"limit,omitempty" jsonschema:"description:Maximum number of symbols to report (0 = unlimited)"`
+}
+
+type ExportGraphArgs struct {
+	Format     string   `json:"format" jsonschema:"required,description:Output format: dot, graphml, mermaid or json"`
+	Symbol     string   `json:"symbol,omitempty" jsonschema:"description:Export the neighbourhood of this symbol instead of the whole graph, bare or qualified"`
+	Dir        string   `json:"dir,omitempty" jsonschema:"description:Export the symbols under this absolute directory"`
+	Depth      int      `json:"depth,omitempty" jsonschema:"description:Hops to expand around the symbol or directory (default 1 for a symbol, 0 for a directory)"`
+	Relations  []string `json:"relations,omitempty" jsonschema:"description:Only include these relations: calls, references, implements, extends, overrides, imports, contains (default: all but contains)"`
+	Cluster    string   `json:"cluster,omitempty" jsonschema:"description:Group nodes by file or package (default: no grouping)"`
+	OutputPath string   `json:"output_path,omitempty" jsonschema:"description:Write the document to this file inside the workspace instead of returning it"`
+}
+
+type CheckIntegrityArgs struct{}
+
 func (s *Server) registerTools() {
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
 		Name:        "index",
@@ -62,53 +146,25 @@ func (s *Server) registerTools() {
 		s.setIndexStatus(IndexStatusInProgress, nil)
 		startTime := time.Now()
 
-		nodes, err := s.scanner.Scan(ctx, cwd)
+		// Re-indexes of saved files wait until the workspace is done.
+		res, err := s.Index(ctx, cwd, args.Force)
 		if err != nil {
-			s.setIndexStatus(IndexStatusFailed, fmt.Errorf("scan failed: %w", err))
-			return errorResult(fmt.Sprintf("Scan failed: %v", err)), nil, nil
-		}
-
-		// COLLECT VALID FILES
-		validFiles := make(map[string]bool)
-		var validFileList []string
-		for _, n := range nodes {
-			if !validFiles[n.FilePath] {
-				validFiles[n.FilePath] = true
-				validFileList = append(validFileList, n.FilePath)
-			}
-		}
-
-		if err := s.store.BulkUpsertNodes(ctx, nodes); err != nil {
-			s.setIndexStatus(IndexStatusFailed, fmt.Errorf("failed to store nodes: %w", err))
-			return errorResult(fmt.Sprintf("Failed to store nodes: %v", err)), nil, nil
-		}
-
-		// PRUNE STALE DATA
-		if err := s.store.PruneStaleFiles(ctx, validFileList); err != nil {
-			// Log warning but don't fail
-			fmt.Fprintf(os.Stderr, "Warning: Failed to prune stale files: %v\n", err)
-		}
-
-		edges, err := s.lsp.Enrich(ctx, nodes, s.store)
-		if err != nil {
-			s.setIndexStatus(IndexStatusFailed, fmt.Errorf("LSP enrichment failed: %w", err))
-			return errorResult(fmt.Sprintf("Enrich failed: %v", err)), nil, nil
-		}
-
-		if err := s.store.BulkUpsertEdges(ctx, edges); err != nil {
-			s.setIndexStatus(IndexStatusFailed, fmt.Errorf("failed to store edges: %w", err))
-			return errorResult(fmt.Sprintf("Failed to store edges: %v", err)), nil, nil
+			s.setIndexStatus(IndexStatusFailed, err)
+			return errorResult(fmt.Sprintf("Indexing failed: %v", err)), nil, nil
 		}
 
 		s.setIndexStatus(IndexStatusReady, nil)
 		duration := time.Since(startTime)
-		msg := fmt.Sprintf("Indexed %d nodes and %d edges in %.2fs", len(nodes), len(edges), duration.Seconds())
+		msg := fmt.Sprintf("Indexed %d nodes and %d edges from %d files in %.2fs (%d unchanged files skipped)", res.Nodes, res.Edges, res.Scanned, duration.Seconds(), res.Unchanged)
+		if res.LSPErr != nil {
+			msg += fmt.Sprintf(" (heuristic edges only: %v)", res.LSPErr)
+		}
 		return textResult(msg), nil, nil
 	})
 
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
 		Name:        "index_status",
-		Description: "Returns the current indexing status of the workspace",
+		Description: "Returns the current indexing status of the workspace and the number of queued indexing jobs",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args IndexStatusArgs) (*mcp.CallToolResult, any, error) {
 		status, err, duration := s.GetIndexStatus()
 
@@ -124,6 +180,9 @@ func (s *Server) registerTools() {
 			result["error"] = err.Error()
 		}
 
+		// Jobs waiting for the coordinator, such as saved files to re-index.
+		result["queue_depth"] = s.coord.QueueDepth()
+
 		jsonBytes, _ := json.MarshalIndent(result, "", "  ")
 		return textResult(string(jsonBytes)), nil, nil
 	})
@@ -132,18 +191,8 @@ func (s *Server) registerTools() {
 		Name:        "get_symbols_in_file",
 		Description: "Returns the structure of a file",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetSymbolsInFileArgs) (*mcp.CallToolResult, any, error) {
-		// Wait for initial indexing with timeout
-		waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
-		if err := s.WaitForIndex(waitCtx); err != nil {
-			status, indexErr, _ := s.GetIndexStatus()
-			if indexErr != nil {
-				return errorResult(fmt.Sprintf("Indexing failed: %v", indexErr)), nil, nil
-			}
-			if status == IndexStatusInProgress {
-				return errorResult("Indexing in progress, please try again"), nil, nil
-			}
-			return errorResult(fmt.Sprintf("Indexing wait failed: %v", err)), nil, nil
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
 		}
 
 		nodes, err := s.store.GetSymbolsInFile(ctx, args.FilePath)
@@ -152,84 +201,458 @@ func (s *Server) registerTools() {
 		}
 
 		type SimpleNode struct {
-			Name  string `json:"name"`
-			Kind  string `json:"kind"`
-			Range string `json:"range"`
+			Name          string       `json:"name"`
+			QualifiedName string       `json:"qualified_name"`
+			Kind          string       `json:"kind"`
+			SymbolKind    string       `json:"symbol_kind"`
+			Range         string       `json:"range"`
+			Signature     string       `json:"signature,omitempty"`
+			Doc           string       `json:"doc,omitempty"`
+			Children      []SimpleNode `json:"children,omitempty"`
 		}
-		var simple []SimpleNode
-		for _, n := range nodes {
-			simple = append(simple, SimpleNode{
-				Name:  n.Name,
-				Kind:  n.Kind,
-				Range: fmt.Sprintf("%d:%d-%d:%d", n.LineStart, n.ColStart, n.LineEnd, n.ColEnd),
-			})
+		var simplify func(outline []*graph.OutlineNode) []SimpleNode
+		simplify = func(outline []*graph.OutlineNode) []SimpleNode {
+			var simple []SimpleNode
+			for _, o := range outline {
+				simple = append(simple, SimpleNode{
+					Name:          o.Name,
+					QualifiedName: o.QualifiedName,
+					Kind:          o.Kind,
+					SymbolKind:    o.SymbolKind,
+					Range:         fmt.Sprintf("%d:%d-%d:%d", o.LineStart, o.ColStart, o.LineEnd, o.ColEnd),
+					Signature:     o.Signature,
+					Doc:           o.Doc,
+					Children:      simplify(o.Children),
+				})
+			}
+			return simple
 		}
+		simple := simplify(graph.Outline(nodes, func(n *graph.Node) bool {
+			return graph.MatchesKinds(n, args.Kinds)
+		}))
 
 		jsonBytes, _ := json.MarshalIndent(simple, "", "  ")
 		return textResult(string(jsonBytes)), nil, nil
 	})
 
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "search_symbols",
+		Description: "Searches symbol names by prefix, camelCase/snake_case words, substring or approximate spelling, returning ranked matches with a score",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args SearchSymbolsArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		results, err := s.store.SearchSymbols(ctx, args.Query, graph.SearchOptions{
+			Kinds:    args.Kinds,
+			Language: args.Language,
+			PathGlob: args.PathGlob,
+			Limit:    args.Limit,
+		})
+		if err != nil {
+			return errorResult(fmt.Sprintf("Search failed: %v", err)), nil, nil
+		}
+
+		if len(results) == 0 {
+			return textResult("No matching symbols found."), nil, nil
+		}
+
+		type SymbolMatch struct {
+			Name          string  `json:"name"`
+			QualifiedName string  `json:"qualified_name"`
+			Kind          string  `json:"kind"`
+			SymbolKind    string  `json:"symbol_kind"`
+			FilePath      string  `json:"file_path"`
+			Line          int     `json:"line"`
+			Signature     string  `json:"signature,omitempty"`
+			Doc           string  `json:"doc,omitempty"`
+			Score         float64 `json:"score"`
+			Match         string  `json:"match"`
+		}
+
+		matches := make([]SymbolMatch, 0, len(results))
+		for _, r := range results {
+			matches = append(matches, SymbolMatch{
+				Name:          r.Node.Name,
+				QualifiedName: r.Node.QualifiedName,
+				Kind:          r.Node.Kind,
+				SymbolKind:    r.Node.SymbolKind,
+				FilePath:      r.Node.FilePath,
+				Line:          r.Node.LineStart,
+				Signature:     r.Node.Signature,
+				Doc:           r.Node.Doc,
+				Score:         math.Round(r.Score*100) / 100,
+				Match:         r.Match,
+			})
+		}
+
+		jsonBytes, _ := json.MarshalIndent(matches, "", "  ")
+		return textResult(string(jsonBytes)), nil, nil
+	})
+
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
 		Name:        "find_impact",
-		Description: "Finds downstream dependents of a symbol",
+		Description: "Finds downstream dependents of a symbol, with their distance, a chain of symbols explaining why each is affected and the exact sites where each uses the previous symbol in the chain",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args FindImpactArgs) (*mcp.CallToolResult, any, error) {
-		// Wait for initial indexing with timeout
-		waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
-		if err := s.WaitForIndex(waitCtx); err != nil {
-			status, indexErr, _ := s.GetIndexStatus()
-			if indexErr != nil {
-				return errorResult(fmt.Sprintf("Indexing failed: %v", indexErr)), nil, nil
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		if err := graph.ValidateRelations(args.Relations); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+
+		reached, err := s.store.FindImpact(ctx, args.SymbolName, graph.TraversalOptions{
+			MaxDepth:  args.MaxDepth,
+			Relations: args.Relations,
+			Kinds:     args.Kinds,
+			Limit:     args.Limit,
+		})
+		if err != nil {
+			return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
+		}
+
+		if len(reached) == 0 {
+			return textResult("No impacted symbols found."), nil, nil
+		}
+
+		jsonBytes, _ := json.MarshalIndent(reachedNodes(reached), "", "  ")
+		return textResult(string(jsonBytes)), nil, nil
+	})
+
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "find_dependencies",
+		Description: "Finds the functions and types a symbol transitively depends on, with their distance and the chain of symbols leading to each",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args FindDependenciesArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		if err := graph.ValidateRelations(args.Relations); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+
+		reached, err := s.store.FindDependencies(ctx, args.SymbolName, graph.TraversalOptions{
+			MaxDepth:  args.MaxDepth,
+			Relations: args.Relations,
+			Kinds:     args.Kinds,
+			Limit:     args.Limit,
+		})
+		if err != nil {
+			return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
+		}
+
+		if len(reached) == 0 {
+			return textResult("No dependencies found."), nil, nil
+		}
+
+		jsonBytes, _ := json.MarshalIndent(reachedNodes(reached), "", "  ")
+		return textResult(string(jsonBytes)), nil, nil
+	})
+
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "find_references",
+		Description: "Lists every use of a symbol with its file, line, column and source line, and the symbol each use occurs in",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args FindReferencesArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		if err := graph.ValidateRelations(args.Relations); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+
+		refs, err := s.store.FindReferences(ctx, args.SymbolName, graph.ReferenceOptions{
+			Relations: args.Relations,
+			Kinds:     args.Kinds,
+			Limit:     args.Limit,
+		})
+		if err != nil {
+			return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
+		}
+
+		if len(refs) == 0 {
+			return textResult("No references found."), nil, nil
+		}
+
+		type Use struct {
+			FilePath   string  `json:"file_path"`
+			Line       int     `json:"line"`
+			Col        int     `json:"col,omitempty"`
+			Snippet    string  `json:"snippet,omitempty"`
+			Relation   string  `json:"relation"`
+			Provenance string  `json:"provenance"`
+			Confidence float64 `json:"confidence"`
+			From       string  `json:"from"`
+			FromKind   string  `json:"from_kind"`
+			Target     string  `json:"target"`
+		}
+		result := make([]Use, 0, len(refs))
+		for _, r := range refs {
+			u := Use{
+				FilePath:   r.FilePath,
+				Line:       r.Line,
+				Col:        r.Col,
+				Snippet:    r.Snippet,
+				Relation:   r.Relation,
+				Provenance: r.Provenance,
+				Confidence: r.Confidence,
+				From:       r.From.QualifiedName,
+				FromKind:   r.From.SymbolKind,
 			}
-			if status == IndexStatusInProgress {
-				return errorResult("Indexing in progress, please try again"), nil, nil
+			if r.To != nil {
+				u.Target = r.To.QualifiedName
 			}
-			return errorResult(fmt.Sprintf("Indexing wait failed: %v", err)), nil, nil
+			result = append(result, u)
 		}
 
-		nodes, err := s.store.FindImpact(ctx, args.SymbolName)
+		jsonBytes, _ := json.MarshalIndent(result, "", "  ")
+		return textResult(string(jsonBytes)), nil, nil
+	})
+
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "find_path",
+		Description: "Finds the shortest dependency chain (or several distinct chains) from one symbol to another, with the file and line of every hop",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args FindPathArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		if err := graph.ValidateRelations(args.Relations); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+
+		paths, err := s.store.FindPaths(ctx, args.FromSymbol, args.ToSymbol, graph.PathOptions{
+			MaxLength: args.MaxLength,
+			Relations: args.Relations,
+			MaxPaths:  args.MaxPaths,
+		})
 		if err != nil {
 			return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
 		}
 
-		if len(nodes) == 0 {
-			return textResult("No impacted symbols found."), nil, nil
+		if len(paths) == 0 {
+			return textResult(fmt.Sprintf("No path found from %s to %s.", args.FromSymbol, args.ToSymbol)), nil, nil
 		}
 
-		type ImpactNode struct {
-			Name     string `json:"name"`
-			FilePath string `json:"file_path"`
-			Kind     string `json:"kind"`
+		type Path struct {
+			Length int              `json:"length"`
+			Steps  []graph.PathStep `json:"steps"`
 		}
-		var impacted []ImpactNode
-		for _, n := range nodes {
-			impacted = append(impacted, ImpactNode{
-				Name:     n.Name,
-				FilePath: n.FilePath,
-				Kind:     n.Kind,
-			})
+		var result []Path
+		for _, p := range paths {
+			result = append(result, Path{Length: len(p) - 1, Steps: p})
 		}
 
-		jsonBytes, _ := json.MarshalIndent(impacted, "", "  ")
+		jsonBytes, _ := json.MarshalIndent(result, "", "  ")
 		return textResult(string(jsonBytes)), nil, nil
 	})
 
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
-		Name:        "get_symbol",
-		Description: "Finds the location and optionally the source code of a symbol",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetSymbolArgs) (*mcp.CallToolResult, any, error) {
-		// Wait for initial indexing with timeout
-		waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
-		if err := s.WaitForIndex(waitCtx); err != nil {
-			status, indexErr, _ := s.GetIndexStatus()
-			if indexErr != nil {
-				return errorResult(fmt.Sprintf("Indexing failed: %v", indexErr)), nil, nil
+		Name:        "get_type_hierarchy",
+		Description: "Shows the supertypes and subtypes of a class or interface as a tree, with the relation (extends or implements) and provenance of every link",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetTypeHierarchyArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		roots, err := s.store.TypeHierarchy(ctx, args.SymbolName, args.MaxDepth)
+		if err != nil {
+			return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
+		}
+
+		if len(roots) == 0 {
+			return textResult("Symbol not found."), nil, nil
+		}
+
+		jsonBytes, _ := json.MarshalIndent(roots, "", "  ")
+		return textResult(string(jsonBytes)), nil, nil
+	})
+
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "get_members",
+		Description: "Lists the members of a class, struct or interface: its methods, fields and nested types, including Go methods declared in other files of the package",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetMembersArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		defs, err := s.store.GetSymbolLocation(ctx, args.SymbolName)
+		if err != nil {
+			return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
+		}
+
+		if len(defs) == 0 {
+			return textResult("Symbol not found."), nil, nil
+		}
+
+		type Member struct {
+			Name          string `json:"name"`
+			QualifiedName string `json:"qualified_name"`
+			Kind          string `json:"kind"`
+			SymbolKind    string `json:"symbol_kind"`
+			FilePath      string `json:"file_path"`
+			Range         string `json:"range"`
+			Signature     string `json:"signature,omitempty"`
+			Doc           string `json:"doc,omitempty"`
+		}
+		type Container struct {
+			QualifiedName string   `json:"qualified_name"`
+			SymbolKind    string   `json:"symbol_kind"`
+			FilePath      string   `json:"file_path"`
+			Line          int      `json:"line"`
+			Signature     string   `json:"signature,omitempty"`
+			Doc           string   `json:"doc,omitempty"`
+			Members       []Member `json:"members"`
+		}
+		var result []Container
+		for _, def := range defs {
+			members, err := s.store.Members(ctx, def)
+			if err != nil {
+				return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
 			}
-			if status == IndexStatusInProgress {
-				return errorResult("Indexing in progress, please try again"), nil, nil
+			c := Container{
+				QualifiedName: def.QualifiedName,
+				SymbolKind:    def.SymbolKind,
+				FilePath:      def.FilePath,
+				Line:          def.LineStart,
+				Signature:     def.Signature,
+				Doc:           def.Doc,
+				Members:       []Member{},
 			}
-			return errorResult(fmt.Sprintf("Indexing wait failed: %v", err)), nil, nil
+			for _, m := range members {
+				if !graph.MatchesKinds(m, args.Kinds) {
+					continue
+				}
+				c.Members = append(c.Members, Member{
+					Name:          m.Name,
+					QualifiedName: m.QualifiedName,
+					Kind:          m.Kind,
+					SymbolKind:    m.SymbolKind,
+					FilePath:      m.FilePath,
+					Range:         fmt.Sprintf("%d:%d-%d:%d", m.LineStart, m.ColStart, m.LineEnd, m.ColEnd),
+					Signature:     m.Signature,
+					Doc:           m.Doc,
+				})
+			}
+			result = append(result, c)
+		}
+
+		jsonBytes, _ := json.MarshalIndent(result, "", "  ")
+		return textResult(string(jsonBytes)), nil, nil
+	})
+
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "find_cycles",
+		Description: "Finds dependency cycles between symbols and between packages (directories), largest first, with the edges that close each loop",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args FindCyclesArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		if err := graph.ValidateRelations(args.Relations); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+
+		level := args.Level
+		if level == "" {
+			level = "both"
+		}
+		if level != "symbol" && level != "package" && level != "both" {
+			return errorResult(fmt.Sprintf("unknown level %q (valid: symbol, package, both)", args.Level)), nil, nil
+		}
+
+		opts := graph.CycleOptions{
+			Relations: args.Relations,
+			MinSize:   args.MinSize,
+			Limit:     args.Limit,
+		}
+		if opts.Limit <= 0 {
+			opts.Limit = 20
+		}
+
+		result := map[string]any{}
+		if level == "symbol" || level == "both" {
+			cycles, err := s.store.FindSymbolCycles(ctx, opts)
+			if err != nil {
+				return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
+			}
+			result["symbol_cycles"] = cycles
+		}
+		if level == "package" || level == "both" {
+			cycles, err := s.store.FindPackageCycles(ctx, opts)
+			if err != nil {
+				return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
+			}
+			result["package_cycles"] = cycles
+		}
+
+		jsonBytes, _ := json.MarshalIndent(result, "", "  ")
+		return textResult(string(jsonBytes)), nil, nil
+	})
+
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "get_module_graph",
+		Description: "Shows which packages (directories) depend on which through imports, with fan-in and fan-out counts per package",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetModuleGraphArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		if err := graph.ValidateRelations(args.Relations); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+
+		g, err := s.store.ModuleGraph(ctx, graph.ModuleGraphOptions{
+			Relations: args.Relations,
+			Dir:       args.Dir,
+		})
+		if err != nil {
+			return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
+		}
+
+		jsonBytes, _ := json.MarshalIndent(g, "", "  ")
+		return textResult(string(jsonBytes)), nil, nil
+	})
+
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "find_unused",
+		Description: "Lists definitions with no inbound edges, grouped by file with line ranges, skipping entry points, tests and exported API by default",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args FindUnusedArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		kinds := args.Kinds
+		if len(kinds) == 0 {
+			kinds = lsp.DefinitionKinds()
+		}
+		files, err := s.store.FindUnused(ctx, graph.UnusedOptions{
+			Kinds:           kinds,
+			EntryPoints:     args.EntryPoints,
+			IncludeTests:    args.IncludeTests,
+			IncludeExported: args.IncludeExported,
+			ExcludePatterns: args.ExcludePatterns,
+			Limit:           args.Limit,
+		})
+		if err != nil {
+			return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
+		}
+
+		if len(files) == 0 {
+			return textResult("No unused symbols found."), nil, nil
+		}
+
+		jsonBytes, _ := json.MarshalIndent(files, "", "  ")
+		return textResult(string(jsonBytes)), nil, nil
+	})
+
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "get_symbol",
+		Description: "Finds the location, signature and doc comment and optionally the source code of a symbol",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetSymbolArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
 		}
 
 		nodes, err := s.store.GetSymbolLocation(ctx, args.SymbolName)
@@ -248,6 +671,9 @@ func (s *Server) registerTools() {
 
 		var info []SymbolInfo
 		for _, n := range nodes {
+			if !graph.MatchesKinds(n, args.Kinds) {
+				continue
+			}
 			si := SymbolInfo{Node: *n}
 			if args.WithSource {
 				source, err := s.readSource(n.FilePath, n.LineStart, n.LineEnd)
@@ -260,10 +686,134 @@ func (s *Server) registerTools() {
 			}
 			info = append(info, si)
 		}
+		if len(info) == 0 {
+			return textResult("Symbol not found."), nil, nil
+		}
 
 		jsonBytes, _ := json.MarshalIndent(info, "", "  ")
 		return textResult(string(jsonBytes)), nil, nil
 	})
+
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "export_graph",
+		Description: "Exports the code graph, or a subgraph around a symbol or directory, as GraphViz DOT, GraphML, Mermaid or JSON",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args ExportGraphArgs) (*mcp.CallToolResult, any, error) {
+		if res := s.awaitIndex(ctx); res != nil {
+			return res, nil, nil
+		}
+
+		opts := export.Options{Format: args.Format, Cluster: args.Cluster}
+		if err := opts.Validate(); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+		if err := graph.ValidateRelations(args.Relations); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+
+		g, err := s.store.Subgraph(ctx, graph.SubgraphOptions{
+			Symbol:    args.Symbol,
+			Dir:       args.Dir,
+			Depth:     args.Depth,
+			Relations: args.Relations,
+		})
+		if err != nil {
+			return errorResult(fmt.Sprintf("Query failed: %v", err)), nil, nil
+		}
+		if len(g.Nodes) == 0 {
+			return textResult("No symbols selected."), nil, nil
+		}
+
+		var sb strings.Builder
+		if err := export.Write(&sb, g, opts); err != nil {
+			return errorResult(fmt.Sprintf("Export failed: %v", err)), nil, nil
+		}
+
+		if args.OutputPath != "" {
+			cwd, _ := os.Getwd()
+			path, err := workspacePath(cwd, args.OutputPath)
+			if err != nil {
+				return errorResult(err.Error()), nil, nil
+			}
+			if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+				return errorResult(fmt.Sprintf("Failed to write %s: %v", path, err)), nil, nil
+			}
+			return textResult(fmt.Sprintf("Exported %d nodes and %d edges to %s", len(g.Nodes), len(g.Edges), path)), nil, nil
+		}
+		return textResult(sb.String()), nil, nil
+	})
+
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "check_integrity",
+		Description: "Reports orphaned edges, duplicate symbols and indexed files that no longer exist on disk",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args CheckIntegrityArgs) (*mcp.CallToolResult, any, error) {
+		report, err := s.store.CheckIntegrity(ctx)
+		if err != nil {
+			return errorResult(fmt.Sprintf("Integrity check failed: %v", err)), nil, nil
+		}
+
+		result := map[string]any{
+			"ok":                report.OK(),
+			"orphaned_edges":    report.OrphanedEdges,
+			"duplicate_symbols": report.DuplicateSymbols,
+			"missing_files":     report.MissingFiles,
+		}
+
+		jsonBytes, _ := json.MarshalIndent(result, "", "  ")
+		return textResult(string(jsonBytes)), nil, nil
+	})
+}
+
+// awaitIndex waits for the initial index to finish. It returns an error
+// result to hand back to the client if the graph is not ready.
+func (s *Server) awaitIndex(ctx context.Context) *mcp.CallToolResult {
+	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := s.WaitForIndex(waitCtx); err != nil {
+		status, indexErr, _ := s.GetIndexStatus()
+		if indexErr != nil {
+			return errorResult(fmt.Sprintf("Indexing failed: %v", indexErr))
+		}
+		if status == IndexStatusInProgress {
+			return errorResult("Indexing in progress, please try again")
+		}
+		return errorResult(fmt.Sprintf("Indexing wait failed: %v", err))
+	}
+	return nil
+}
+
+// ReachedNode is the tool representation of a node found by a traversal.
+type ReachedNode struct {
+	Name          string           `json:"name"`
+	QualifiedName string           `json:"qualified_name"`
+	FilePath      string           `json:"file_path"`
+	Line          int              `json:"line"`
+	Kind          string           `json:"kind"`
+	SymbolKind    string           `json:"symbol_kind"`
+	Signature     string           `json:"signature,omitempty"`
+	Doc           string           `json:"doc,omitempty"`
+	Distance      int              `json:"distance"`
+	Path          []graph.PathStep `json:"path"`
+	Sites         []graph.Site     `json:"sites,omitempty"`
+}
+
+func reachedNodes(reached []*graph.Reached) []ReachedNode {
+	result := make([]ReachedNode, 0, len(reached))
+	for _, r := range reached {
+		result = append(result, ReachedNode{
+			Name:          r.Node.Name,
+			QualifiedName: r.Node.QualifiedName,
+			FilePath:      r.Node.FilePath,
+			Line:          r.Node.LineStart,
+			Kind:          r.Node.Kind,
+			SymbolKind:    r.Node.SymbolKind,
+			Signature:     r.Node.Signature,
+			Doc:           r.Node.Doc,
+			Distance:      r.Distance,
+			Path:          r.Path,
+			Sites:         r.Sites,
+		})
+	}
+	return result
 }
 
 func (s *Server) readSource(filePath string, lineStart, lineEnd int) (string, error) {
@@ -297,3 +847,29 @@ func (s *Server) readSource(filePath string, lineStart, lineEnd int) (string, er
 
 	return builder.String(), nil
 }
+
+// workspacePath resolves a path given by a client, relative to root unless
+// absolute, and rejects it unless it lies inside root once symbolic links are
+// followed, so that tools cannot write elsewhere on the machine.
+func workspacePath(root, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace %s: %w", root, err)
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	if rel, err := filepath.Rel(realRoot, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the workspace %s", path, root)
+	}
+	path = filepath.Join(dir, filepath.Base(path))
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", fmt.Errorf("%s is a symbolic link", path)
+	}
+	return path, nil
+}
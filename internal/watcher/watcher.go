@@ -14,15 +14,18 @@ import (
 	ignore "github.com/sabhiram/go-gitignore"
 
 	"codemap/internal/graph"
+	"codemap/internal/indexer"
 	"codemap/internal/lsp"
 	"codemap/internal/scanner"
 )
 
-// Watcher monitors file system changes and triggers re-indexing.
+// Watcher monitors file system changes and queues the re-indexing of the
+// changed files with the coordinator.
 type Watcher struct {
 	scanner   *scanner.Scanner
 	store     *graph.Store
 	lsp       *lsp.Service
+	coord     *indexer.Coordinator
 	watcher   *fsnotify.Watcher
 	root      string
 	gitignore *ignore.GitIgnore
@@ -34,7 +37,7 @@ type Watcher struct {
 }
 
 // New creates a new file watcher.
-func New(scn *scanner.Scanner, store *graph.Store, lspSvc *lsp.Service, root string) (*Watcher, error) {
+func New(scn *scanner.Scanner, store *graph.Store, lspSvc *lsp.Service, coord *indexer.Coordinator, root string) (*Watcher, error) {
 	fw, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create fsnotify watcher: %w", err)
@@ -47,6 +50,7 @@ func New(scn *scanner.Scanner, store *graph.Store, lspSvc *lsp.Service, root str
 		scanner:      scn,
 		store:        store,
 		lsp:          lspSvc,
+		coord:        coord,
 		watcher:      fw,
 		root:         root,
 		gitignore:    ign,
@@ -119,10 +123,10 @@ func (w *Watcher) handleEvent(ctx context.Context, event fsnotify.Event) {
 		w.debounceFile(event.Name)
 	case event.Op&fsnotify.Remove != 0:
 		log.Printf("File deleted: %s", relPath)
-		w.handleFileDeleted(ctx, event.Name)
+		w.queue(event.Name)
 	case event.Op&fsnotify.Rename != 0:
 		log.Printf("File renamed: %s", relPath)
-		w.handleFileDeleted(ctx, event.Name)
+		w.queue(event.Name)
 	}
 }
 
@@ -160,12 +164,18 @@ func (w *Watcher) processPendingFiles(ctx context.Context) {
 	w.mu.Unlock()
 
 	for _, path := range ready {
-		if err := w.reindexFile(ctx, path); err != nil {
-			log.Printf("Failed to reindex %s: %v", path, err)
-		}
+		w.queue(path)
 	}
 }
 
+// queue has the coordinator re-index path, or remove its nodes if it is gone
+// by the time the job runs.
+func (w *Watcher) queue(path string) {
+	w.coord.QueueFile(path, func(ctx context.Context) error {
+		return w.reindexFile(ctx, path)
+	})
+}
+
 func (w *Watcher) reindexFile(ctx context.Context, path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return w.handleFileDeleted(ctx, path)
@@ -173,35 +183,96 @@ func (w *Watcher) reindexFile(ctx context.Context, path string) error {
 
 	log.Printf("Re-indexing: %s", path)
 
-	nodes, err := w.scanner.ScanFile(ctx, path)
+	file, statErr := w.scanner.Stat(path, nil)
+	scanned, edited, err := w.scanner.Reparse(ctx, path)
 	if err != nil {
 		return fmt.Errorf("scan failed: %w", err)
 	}
 
-	if err := w.store.DeleteNodesByFile(ctx, path); err != nil {
-		return fmt.Errorf("delete old nodes failed: %w", err)
+	// Only the nodes the edit changed are stored again and lose their edges;
+	// the rest of the file keeps its nodes and edges, moved along with any
+	// lines added or removed above them.
+	nodes, removed, err := w.store.UpdateFileNodes(ctx, path, scanned, edited)
+	if err != nil {
+		return fmt.Errorf("store nodes failed: %w", err)
 	}
 
-	if err := w.store.BulkUpsertNodes(ctx, nodes); err != nil {
-		return fmt.Errorf("bulk store nodes failed: %w", err)
+	edges, err := w.store.HeuristicEdges(ctx, nodes)
+	if err != nil {
+		return fmt.Errorf("heuristic resolution failed: %w", err)
 	}
 
-	edges, err := w.lsp.Enrich(ctx, nodes, w.store)
-	if err != nil {
-		log.Printf("LSP enrichment failed for %s: %v", path, err)
+	// A language server reports a reference from the edited code to another
+	// definition only when asked for the references to that definition, so
+	// those it calls or derives from are enriched along with the changed
+	// nodes.
+	var lspEdges []*graph.Edge
+	var lspErr error
+	if len(nodes) > 0 {
+		mentioned, err := w.store.MentionedNodes(ctx, nodes)
+		if err != nil {
+			return fmt.Errorf("finding mentioned definitions failed: %w", err)
+		}
+		enrich := append(append([]*graph.Node{}, nodes...), mentioned...)
+		lspEdges, lspErr = w.lsp.Enrich(ctx, enrich, w.store)
+		if lspErr != nil {
+			log.Printf("LSP enrichment failed for %s: %v", path, lspErr)
+		} else if err := w.store.UpdateSignatures(ctx, enrich); err != nil {
+			log.Printf("Failed to store LSP signatures for %s: %v", path, err)
+		}
 	}
+	edges = append(edges, lspEdges...)
 
 	if err := w.store.BulkUpsertEdges(ctx, edges); err != nil {
 		return fmt.Errorf("bulk store edges failed: %w", err)
 	}
 
-	log.Printf("✓ Re-indexed %s: %d nodes, %d edges", filepath.Base(path), len(nodes), len(edges))
+	// Overrides follow the hierarchy edges just stored.
+	overrides, err := w.store.OverrideEdges(ctx, nodes)
+	if err != nil {
+		return fmt.Errorf("override resolution failed: %w", err)
+	}
+	if err := w.store.BulkUpsertEdges(ctx, overrides); err != nil {
+		return fmt.Errorf("bulk store overrides failed: %w", err)
+	}
+	edges = append(edges, overrides...)
+
+	// Calls elsewhere to the names defined or removed here may resolve
+	// differently now, and subtypes may override other methods.
+	dependent, err := w.store.RefreshDependents(ctx, nodes, removed)
+	if err != nil {
+		return err
+	}
+	edges = append(edges, dependent...)
+
+	// Record the file, as it was before the scan, so the next startup can
+	// skip it, or only enrich it again if the language server failed. When
+	// no node changed, neither did the content the record describes.
+	if statErr == nil && len(nodes) > 0 {
+		file.Enriched = lspErr == nil
+		if err := w.store.UpsertFiles(ctx, []*graph.File{file}); err != nil {
+			log.Printf("Failed to record %s as indexed: %v", path, err)
+		}
+	}
+
+	log.Printf("✓ Re-indexed %s: %d of %d nodes changed, %d edges", filepath.Base(path), len(nodes), len(scanned), len(edges))
 	return nil
 }
 
 func (w *Watcher) handleFileDeleted(ctx context.Context, path string) error {
 	log.Printf("Removing nodes for deleted file: %s", path)
-	return w.store.DeleteNodesByFile(ctx, path)
+	w.scanner.Forget(path)
+	removed, err := w.store.GetSymbolsInFile(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := w.store.DeleteNodesByFile(ctx, path); err != nil {
+		return err
+	}
+
+	// Calls to the deleted definitions may resolve to others now.
+	_, err = w.store.RefreshDependents(ctx, nil, removed)
+	return err
 }
 
 func (w *Watcher) addDirectoriesRecursively(root string) error {
@@ -0,0 +1,173 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"codemap/internal/db"
+	"codemap/internal/graph"
+	"codemap/internal/indexer"
+	"codemap/internal/lsp"
+	"codemap/internal/scanner"
+)
+
+func TestReindexFile_KeepsOverridesOfEditedMethod(t *testing.T) {
+	database, err := db.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	root := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	readerPath := write("reader.go", "package files\n\ntype Reader interface {\n\tRead() error\n}\n")
+	filePath := write("file.go", "package files\n\ntype File struct{}\n\nfunc (f *File) Read() error { return nil }\n")
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	defer scn.Close()
+	if _, err := scn.Files(ctx, root); err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	w, err := New(scn, store, lsp.NewLocalService(), indexer.New(), root)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	for _, path := range []string{readerPath, filePath} {
+		if err := w.reindexFile(ctx, path); err != nil {
+			t.Fatalf("reindexFile(%s) failed: %v", path, err)
+		}
+	}
+
+	// File implements Reader by its method set, so File.Read overrides
+	// Reader.Read.
+	overrides := func() bool {
+		t.Helper()
+		reached, err := store.FindDependencies(ctx, "files.File.Read", graph.TraversalOptions{
+			MaxDepth:  1,
+			Relations: []string{graph.RelationOverrides},
+		})
+		if err != nil {
+			t.Fatalf("FindDependencies failed: %v", err)
+		}
+		return len(reached) == 1 && reached[0].Node.QualifiedName == "files.Reader.Read"
+	}
+	if !overrides() {
+		t.Fatal("no overrides edge from File.Read to Reader.Read after the initial index")
+	}
+
+	// Editing the method stores it again, but not File, its receiver type.
+	write("file.go", "package files\n\ntype File struct{}\n\nfunc (f *File) Read() error {\n\treturn nil\n}\n")
+	if err := w.reindexFile(ctx, filePath); err != nil {
+		t.Fatalf("reindexFile failed: %v", err)
+	}
+	if !overrides() {
+		t.Fatal("no overrides edge from File.Read to Reader.Read after editing its body")
+	}
+
+	// Moving it keeps the edge too.
+	write("file.go", "package files\n\ntype File struct{}\n\n\nfunc (f *File) Read() error {\n\treturn nil\n}\n")
+	if err := w.reindexFile(ctx, filePath); err != nil {
+		t.Fatalf("reindexFile failed: %v", err)
+	}
+	if !overrides() {
+		t.Error("no overrides edge from File.Read to Reader.Read after moving it")
+	}
+}
+
+func TestReindexFile_RefreshesCallersFromStoredCallSites(t *testing.T) {
+	database, err := db.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	root := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	appPath := write("app.py", "from util import helper\n\n\ndef run():\n    helper()\n")
+	utilPath := write("util.py", "def helper():\n    pass\n")
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	defer scn.Close()
+	if _, err := scn.Files(ctx, root); err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	w, err := New(scn, store, lsp.NewLocalService(), indexer.New(), root)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	calls := func() string {
+		t.Helper()
+		refs, err := store.FindReferences(ctx, "helper", graph.ReferenceOptions{Relations: []string{graph.RelationCalls}})
+		if err != nil {
+			t.Fatalf("FindReferences failed: %v", err)
+		}
+		var out []string
+		for _, r := range refs {
+			out = append(out, fmt.Sprintf("%s->%s %.2f", r.From.Name, filepath.Base(r.To.FilePath), r.Confidence))
+		}
+		sort.Strings(out)
+		return strings.Join(out, ",")
+	}
+	for _, path := range []string{utilPath, appPath} {
+		if err := w.reindexFile(ctx, path); err != nil {
+			t.Fatalf("reindexFile(%s) failed: %v", path, err)
+		}
+	}
+	if got := calls(); got != "run->util.py 0.80" {
+		t.Fatalf("calls to helper = %s, want run->util.py 0.80", got)
+	}
+
+	// A second helper, in a file app.py does not import, leaves the call
+	// as it was: the caller, read back from the store, still imports util.
+	extraPath := write("extra.py", "def helper():\n    pass\n")
+	if err := w.reindexFile(ctx, extraPath); err != nil {
+		t.Fatalf("reindexFile failed: %v", err)
+	}
+	if got := calls(); got != "run->util.py 0.80" {
+		t.Errorf("calls to helper after adding another = %s, want run->util.py 0.80", got)
+	}
+
+	// Without the imported one, the call goes to the other.
+	if err := os.Remove(utilPath); err != nil {
+		t.Fatalf("Failed to remove util.py: %v", err)
+	}
+	if err := w.reindexFile(ctx, utilPath); err != nil {
+		t.Fatalf("reindexFile failed: %v", err)
+	}
+	if got := calls(); got != "run->extra.py 0.70" {
+		t.Errorf("calls to helper after removing util.py = %s, want run->extra.py 0.70", got)
+	}
+}
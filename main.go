@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -13,6 +14,7 @@ import (
 
 	"codemap/internal/db"
 	"codemap/internal/graph"
+	"codemap/internal/indexer"
 	"codemap/internal/lsp"
 	"codemap/internal/scanner"
 	"codemap/internal/server"
@@ -24,47 +26,18 @@ import (
 var systemPrompt string
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
+
 	projectDir := flag.String("project-dir", "", "Project directory to index (default: current working directory)")
 	flag.Parse()
 
-	if *projectDir != "" {
-		absProjectDir, err := filepath.Abs(*projectDir)
-		if err != nil {
-			log.Fatalf("Failed to resolve project directory: %v", err)
-		}
-		info, err := os.Stat(absProjectDir)
-		if err != nil {
-			log.Fatalf("Failed to access project directory: %v", err)
-		}
-		if !info.IsDir() {
-			log.Fatalf("Project directory is not a directory: %s", absProjectDir)
-		}
-		if err := os.Chdir(absProjectDir); err != nil {
-			log.Fatalf("Failed to change to project directory: %v", err)
-		}
-	}
+	enterProjectDir(*projectDir)
 
 	// 1. Setup DB
-	// Try to find git root for project-specific DB
-	projectRoot, err := util.FindGitRoot()
-	dbDir := ".ctxhub"
-	dbName := "codemap.sqlite"
-	var dbPath string
-	if err == nil && projectRoot != "" {
-		dbPath = filepath.Join(projectRoot, dbDir, dbName)
-	} else {
-		// Fallback to CWD
-		cwd, err := os.Getwd()
-		if err != nil {
-			log.Fatalf("Failed to get working directory: %v", err)
-		}
-		dbPath = filepath.Join(cwd, dbDir, dbName)
-	}
-
-	database, err := db.New(dbPath)
-	if err != nil {
-		log.Fatalf("Failed to init DB at %s: %v", dbPath, err)
-	}
+	database := openDatabase()
 	defer database.Close()
 
 	store := graph.NewStore(database)
@@ -74,6 +47,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to init scanner: %v", err)
 	}
+	defer scn.Close()
 
 	// 3. Setup LSP
 	lspSvc := lsp.NewService()
@@ -98,8 +72,12 @@ func main() {
 		log.Fatalf("Failed to get working directory: %v", err)
 	}
 
-	// 6. Start MCP Server
-	srv := server.New(scn, store, lspSvc, systemPrompt)
+	// 6. Start the indexing coordinator, which makes every write to the
+	// graph, and the MCP Server
+	coord := indexer.New()
+	go coord.Run(ctx)
+
+	srv := server.New(scn, store, lspSvc, coord, systemPrompt)
 
 	log.Println("Starting MCP server on stdio...")
 
@@ -116,7 +94,7 @@ func main() {
 	}()
 
 	// 8. Start file watcher in background
-	w, err := watcher.New(scn, store, lspSvc, cwd)
+	w, err := watcher.New(scn, store, lspSvc, coord, cwd)
 	if err != nil {
 		log.Fatalf("Failed to create watcher: %v", err)
 	}
@@ -153,3 +131,57 @@ func main() {
 		log.Println("Shutting down gracefully...")
 	}
 }
+
+// enterProjectDir makes dir the working directory. An empty dir keeps the
+// current one.
+func enterProjectDir(dir string) {
+	if dir == "" {
+		return
+	}
+	absProjectDir, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatalf("Failed to resolve project directory: %v", err)
+	}
+	info, err := os.Stat(absProjectDir)
+	if err != nil {
+		log.Fatalf("Failed to access project directory: %v", err)
+	}
+	if !info.IsDir() {
+		log.Fatalf("Project directory is not a directory: %s", absProjectDir)
+	}
+	if err := os.Chdir(absProjectDir); err != nil {
+		log.Fatalf("Failed to change to project directory: %v", err)
+	}
+}
+
+// openDatabase opens the project database, kept under the git root or, outside
+// a repository, under the working directory.
+func openDatabase() *db.DB {
+	// Try to find git root for project-specific DB
+	projectRoot, err := util.FindGitRoot()
+	dbDir := ".ctxhub"
+	dbName := "codemap.sqlite"
+	var dbPath string
+	if err == nil && projectRoot != "" {
+		dbPath = filepath.Join(projectRoot, dbDir, dbName)
+	} else {
+		// Fallback to CWD
+		cwd, err := os.Getwd()
+		if err != nil {
+			log.Fatalf("Failed to get working directory: %v", err)
+		}
+		dbPath = filepath.Join(cwd, dbDir, dbName)
+	}
+
+	database, err := db.New(dbPath)
+	if errors.Is(err, db.ErrSchemaTooNew) {
+		log.Fatalf("Database at %s was created by a newer version of codemap: %v. Upgrade codemap or delete %s to rebuild it.", dbPath, err, filepath.Dir(dbPath))
+	}
+	if errors.Is(err, db.ErrSearchUnavailable) {
+		log.Fatalf("Database at %s was indexed with SQLite FTS5: %v. Build codemap with -tags sqlite_fts5 or delete %s to rebuild it.", dbPath, err, filepath.Dir(dbPath))
+	}
+	if err != nil {
+		log.Fatalf("Failed to init DB at %s: %v", dbPath, err)
+	}
+	return database
+}
@@ -2,13 +2,20 @@ package tests
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
 	"codemap/internal/db"
+	"codemap/internal/export"
 	"codemap/internal/graph"
+	"codemap/internal/indexer"
+	"codemap/internal/lsp"
 	"codemap/internal/scanner"
+	"codemap/internal/server"
 )
 
 func TestIntegration_ReindexAndQuery(t *testing.T) {
@@ -170,3 +177,2535 @@ func createFile(t *testing.T, dir, name, content string) {
 		t.Fatal(err)
 	}
 }
+
+func TestIntegration_QualifiedNames(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "types.go", `package shapes
+
+type Circle struct{}
+type Square struct{}
+
+func (c Circle) String() string { return "circle" }
+func (s *Square) String() string { return "square" }
+
+func init() {}
+func init() {}
+`)
+	createFile(t, wsDir, "models.py", `
+class User:
+    def save(self):
+        pass
+
+class Order:
+    def save(self):
+        pass
+`)
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	nodes, err := scn.Scan(context.Background(), wsDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(context.Background(), nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		symbol string
+		want   []string
+	}{
+		{"String", []string{"shapes.Circle.String", "shapes.Square.String"}},
+		{"Square.String", []string{"shapes.Square.String"}},
+		{"shapes.Circle.String", []string{"shapes.Circle.String"}},
+		{"save", []string{"models.Order.save", "models.User.save"}},
+		{"User.save", []string{"models.User.save"}},
+		{"init", []string{"shapes.init", "shapes.init#2"}},
+		{"shapes.init", []string{"shapes.init", "shapes.init#2"}},
+	} {
+		locs, err := store.GetSymbolLocation(context.Background(), tc.symbol)
+		if err != nil {
+			t.Fatalf("GetSymbolLocation(%q) failed: %v", tc.symbol, err)
+		}
+		var got []string
+		for _, n := range locs {
+			got = append(got, n.QualifiedName)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("GetSymbolLocation(%q) = %v, want %v", tc.symbol, got, tc.want)
+		}
+	}
+}
+
+func TestIntegration_CallSites(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "main.go", `package main
+
+import "fmt"
+
+var ready = setup()
+
+func setup() bool { return true }
+
+func run(c *Client) {
+	c.Send(fmt.Sprint(1))
+	helper := func() { cleanup() }
+	helper()
+}
+`)
+	createFile(t, wsDir, "app.py", `
+class App:
+    def start(self):
+        self.load()
+        print("started")
+`)
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	nodes, err := scn.Scan(ctx, wsDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+
+	sites := func(qualified string) string {
+		t.Helper()
+		locs, err := store.GetSymbolLocation(ctx, qualified)
+		if err != nil || len(locs) != 1 {
+			t.Fatalf("GetSymbolLocation(%q) = %v, %v", qualified, locs, err)
+		}
+		calls, err := store.GetCallSites(ctx, locs[0].ID)
+		if err != nil {
+			t.Fatalf("GetCallSites(%q) failed: %v", qualified, err)
+		}
+		var got []string
+		for _, c := range calls {
+			name := c.Name
+			if c.Qualifier != "" {
+				name = c.Qualifier + "." + name
+			}
+			got = append(got, fmt.Sprintf("%s@%d:%d", name, c.Line, c.Col))
+		}
+		return strings.Join(got, ",")
+	}
+
+	// Calls in nested function literals belong to the enclosing declaration;
+	// package-level initializers have no caller.
+	if got, want := sites("main.run"), "c.Send@10:4,fmt.Sprint@10:13,cleanup@11:21,helper@12:2"; got != want {
+		t.Errorf("call sites of main.run = %s, want %s", got, want)
+	}
+	if got := sites("main.setup"); got != "" {
+		t.Errorf("call sites of main.setup = %s, want none", got)
+	}
+	if got, want := sites("app.App.start"), "self.load@4:14,print@5:9"; got != want {
+		t.Errorf("call sites of app.App.start = %s, want %s", got, want)
+	}
+
+	mainPath := filepath.Join(wsDir, "main.go")
+	for _, tc := range []struct {
+		line, col int
+		want      bool
+	}{
+		{10, 4, true},  // Send in c.Send(...)
+		{10, 2, false}, // c, the receiver
+		{5, 13, false}, // setup() at package level is not attributed
+	} {
+		got, err := store.IsCallSite(ctx, mainPath, tc.line, tc.col)
+		if err != nil {
+			t.Fatalf("IsCallSite failed: %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("IsCallSite(%d:%d) = %v, want %v", tc.line, tc.col, got, tc.want)
+		}
+	}
+
+	// Rescanning replaces the call sites of a definition.
+	createFile(t, wsDir, "main.go", `package main
+
+func setup() bool { return true }
+
+func run(c *Client) {
+	setup()
+}
+`)
+	nodes, err = scn.ScanFile(ctx, mainPath)
+	if err != nil {
+		t.Fatalf("ScanFile failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+	if got, want := sites("main.run"), "setup@6:2"; got != want {
+		t.Errorf("call sites of main.run after rescan = %s, want %s", got, want)
+	}
+
+	// A calls edge keeps the earliest line it was seen at.
+	run, _ := store.GetSymbolLocation(ctx, "main.run")
+	setup, _ := store.GetSymbolLocation(ctx, "main.setup")
+	for _, line := range []int{9, 6, 0} {
+		e := &graph.Edge{SourceID: run[0].ID, TargetID: setup[0].ID, Relation: graph.RelationCalls, Line: line}
+		if err := store.UpsertEdge(ctx, e); err != nil {
+			t.Fatalf("UpsertEdge failed: %v", err)
+		}
+	}
+	sub, err := store.Subgraph(ctx, graph.SubgraphOptions{Symbol: "main.run"})
+	if err != nil {
+		t.Fatalf("Subgraph failed: %v", err)
+	}
+	if len(sub.Edges) != 1 || sub.Edges[0].Relation != graph.RelationCalls || sub.Edges[0].Line != 6 {
+		t.Errorf("edges = %+v, want one calls edge at line 6", sub.Edges)
+	}
+}
+
+func TestIntegration_ImportEdges(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	for _, dir := range []string{"cmd", "store", "app/models", "web/lib", "lua/util", "zig"} {
+		if err := os.MkdirAll(filepath.Join(wsDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createFile(t, wsDir, "go.mod", "module example.com/shop\n\ngo 1.22\n")
+	createFile(t, wsDir, "cmd/main.go", `package main
+
+import (
+	"fmt"
+
+	"example.com/shop/store"
+)
+
+func main() { fmt.Println(store.Open()) }
+`)
+	createFile(t, wsDir, "store/store.go", "package store\n\nfunc Open() bool { return true }\n")
+	createFile(t, wsDir, "store/cache.go", "package store\n")
+	createFile(t, wsDir, "store/store_test.go", "package store\n")
+	createFile(t, wsDir, "app/__init__.py", "")
+	createFile(t, wsDir, "app/models/__init__.py", "")
+	createFile(t, wsDir, "app/models/user.py", "class User:\n    pass\n")
+	createFile(t, wsDir, "app/views.py", `import os
+from .models import user
+from app.models.user import User
+`)
+	createFile(t, wsDir, "web/lib/format.ts", "export function format() {}\n")
+	createFile(t, wsDir, "web/index.ts", `import { format } from './lib/format';
+import React from 'react';
+const util = require('./lib/format.js');
+`)
+	createFile(t, wsDir, "lua/util/init.lua", "local M = {}\nreturn M\n")
+	createFile(t, wsDir, "lua/main.lua", `local util = require("lua.util")
+local json = require "cjson"
+`)
+	createFile(t, wsDir, "zig/main.zig", `const std = @import("std");
+const math = @import("math.zig");
+`)
+	createFile(t, wsDir, "zig/math.zig", "pub fn add() void {}\n")
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	nodes, err := scn.Scan(ctx, wsDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+	edges := graph.ImportEdges(nodes)
+	if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+
+	// Every edge must land on a stored file node.
+	sub, err := store.Subgraph(ctx, graph.SubgraphOptions{Relations: []string{graph.RelationImports}})
+	if err != nil {
+		t.Fatalf("Subgraph failed: %v", err)
+	}
+	if len(sub.Edges) != len(edges) {
+		t.Errorf("stored %d of %d import edges", len(sub.Edges), len(edges))
+	}
+	byID := make(map[string]*graph.Node)
+	for _, n := range sub.Nodes {
+		byID[n.ID] = n
+	}
+	var got []string
+	for _, e := range sub.Edges {
+		got = append(got, fmt.Sprintf("%s->%s@%d", byID[e.SourceID].QualifiedName, byID[e.TargetID].QualifiedName, e.Line))
+	}
+	sort.Strings(got)
+	// The two imports of user.py collapse into one edge at the first line.
+	want := []string{
+		"app/views.py->app/models/user.py@2",
+		"cmd/main.go->store/cache.go@6",
+		"cmd/main.go->store/store.go@6",
+		"lua/main.lua->lua/util/init.lua@1",
+		"web/index.ts->web/lib/format.ts@1",
+		"zig/main.zig->zig/math.zig@2",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("import edges:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// File nodes are not symbols of their file.
+	syms, err := store.GetSymbolsInFile(ctx, filepath.Join(wsDir, "store/store.go"))
+	if err != nil {
+		t.Fatalf("GetSymbolsInFile failed: %v", err)
+	}
+	if len(syms) != 1 || syms[0].Name != "Open" {
+		t.Errorf("GetSymbolsInFile(store.go) = %v, want [Open]", syms)
+	}
+
+	mg, err := store.ModuleGraph(ctx, graph.ModuleGraphOptions{})
+	if err != nil {
+		t.Fatalf("ModuleGraph failed: %v", err)
+	}
+	pkgs := make(map[string]graph.ModulePackage)
+	for _, p := range mg.Packages {
+		pkgs[strings.TrimPrefix(p.Package, wsDir)] = p
+	}
+	for _, tc := range []struct {
+		pkg           string
+		files         int
+		fanIn, fanOut int
+	}{
+		{"/cmd", 1, 0, 1},
+		{"/store", 3, 1, 0},
+		{"/app", 2, 0, 1},
+		{"/app/models", 2, 1, 0},
+		{"/web", 1, 0, 1},
+		{"/zig", 2, 0, 0},
+	} {
+		p, ok := pkgs[tc.pkg]
+		if !ok {
+			t.Errorf("package %s missing from module graph", tc.pkg)
+			continue
+		}
+		if p.Files != tc.files || p.FanIn != tc.fanIn || p.FanOut != tc.fanOut {
+			t.Errorf("package %s: files=%d fan_in=%d fan_out=%d, want %d/%d/%d",
+				tc.pkg, p.Files, p.FanIn, p.FanOut, tc.files, tc.fanIn, tc.fanOut)
+		}
+	}
+	storeDep := mg.Dependencies[0]
+	for _, d := range mg.Dependencies {
+		if strings.HasSuffix(d.To, "/store") {
+			storeDep = d
+		}
+	}
+	if storeDep.Edges != 2 || !strings.HasSuffix(storeDep.From, "/cmd") {
+		t.Errorf("cmd -> store dependency = %+v, want 2 edges", storeDep)
+	}
+
+	mg, err = store.ModuleGraph(ctx, graph.ModuleGraphOptions{Dir: filepath.Join(wsDir, "app")})
+	if err != nil {
+		t.Fatalf("ModuleGraph failed: %v", err)
+	}
+	if len(mg.Packages) != 2 || len(mg.Dependencies) != 1 {
+		t.Errorf("module graph under app = %+v, want 2 packages and 1 dependency", mg)
+	}
+}
+
+func TestIntegration_HeuristicEdges(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	for _, dir := range []string{"cmd", "store", "other", "extra"} {
+		if err := os.MkdirAll(filepath.Join(wsDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createFile(t, wsDir, "go.mod", "module example.com/shop\n")
+	createFile(t, wsDir, "cmd/main.go", `package main
+
+import "example.com/shop/store"
+
+func main() {
+	store.Open()
+	run()
+	helper()
+	Close()
+}
+
+func run() {}
+`)
+	createFile(t, wsDir, "cmd/helper.go", "package main\n\nfunc helper() {}\n")
+	createFile(t, wsDir, "store/store.go", "package store\n\nfunc Open() {}\n")
+	createFile(t, wsDir, "other/open.go", "package other\n\nfunc Open() {}\n\nfunc Close() {}\n")
+	createFile(t, wsDir, "extra/close.go", "package extra\n\nfunc Close() {}\n")
+	createFile(t, wsDir, "cmd/run.py", "def run():\n    pass\n")
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	nodes, err := scn.Scan(ctx, wsDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+	edges, err := store.HeuristicEdges(ctx, nodes)
+	if err != nil {
+		t.Fatalf("HeuristicEdges failed: %v", err)
+	}
+	if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+
+	calls := func() map[string]*graph.Edge {
+		t.Helper()
+		sub, err := store.Subgraph(ctx, graph.SubgraphOptions{Symbol: "main.main", Relations: []string{graph.RelationCalls}})
+		if err != nil {
+			t.Fatalf("Subgraph failed: %v", err)
+		}
+		byID := make(map[string]*graph.Node)
+		for _, n := range sub.Nodes {
+			byID[n.ID] = n
+		}
+		result := make(map[string]*graph.Edge)
+		for _, e := range sub.Edges {
+			target := byID[e.TargetID]
+			result[strings.TrimPrefix(target.FilePath, wsDir+string(filepath.Separator))+":"+target.Name] = e
+		}
+		return result
+	}
+
+	// The imported package beats an unrelated one, the caller's file beats
+	// its package, Python definitions are never candidates, and an ambiguous
+	// call splits its confidence.
+	got := calls()
+	for _, tc := range []struct {
+		callee     string
+		line       int
+		confidence float64
+	}{
+		{"store/store.go:Open", 6, 0.8},
+		{"cmd/main.go:run", 7, 0.9},
+		{"cmd/helper.go:helper", 8, 0.7},
+		{"other/open.go:Close", 9, 0.25},
+		{"extra/close.go:Close", 9, 0.25},
+	} {
+		e, ok := got[tc.callee]
+		if !ok {
+			t.Errorf("no calls edge to %s", tc.callee)
+			continue
+		}
+		if e.Line != tc.line || e.Provenance != graph.ProvenanceHeuristic || e.Confidence != tc.confidence {
+			t.Errorf("edge to %s = line %d, %s %.2f; want line %d, heuristic %.2f",
+				tc.callee, e.Line, e.Provenance, e.Confidence, tc.line, tc.confidence)
+		}
+	}
+	if len(got) != 5 {
+		t.Errorf("got %d calls edges, want 5: %v", len(got), got)
+	}
+
+	// An LSP edge upgrades the heuristic one, and re-running the heuristic
+	// does not downgrade it.
+	e := got["store/store.go:Open"]
+	lspEdge := &graph.Edge{SourceID: e.SourceID, TargetID: e.TargetID, Relation: graph.RelationCalls, Line: 6, Provenance: graph.ProvenanceLSP, Confidence: 1}
+	if err := store.UpsertEdge(ctx, lspEdge); err != nil {
+		t.Fatalf("UpsertEdge failed: %v", err)
+	}
+	if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+	if e := calls()["store/store.go:Open"]; e.Provenance != graph.ProvenanceLSP || e.Confidence != 1 {
+		t.Errorf("edge to Open after LSP = %s %.2f, want lsp 1.00", e.Provenance, e.Confidence)
+	}
+}
+
+func TestIntegration_TypeHierarchy(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "animals.py", `class Animal:
+    def speak(self):
+        pass
+
+    def eat(self):
+        pass
+
+
+class Dog(Animal):
+    def speak(self):
+        pass
+
+
+class Puppy(Dog, metaclass=Meta):
+    def speak(self):
+        pass
+
+    def eat(self):
+        pass
+`)
+	createFile(t, wsDir, "shapes.ts", `interface Drawable {
+  draw(): void;
+}
+
+interface Shape extends Drawable {}
+
+class Base {
+  area(): number { return 0; }
+}
+
+class Square extends Base implements Shape {
+  area(): number { return 1; }
+  draw(): void {}
+}
+`)
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	nodes, err := scn.Scan(ctx, wsDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+	edges, err := store.HeuristicEdges(ctx, nodes)
+	if err != nil {
+		t.Fatalf("HeuristicEdges failed: %v", err)
+	}
+	if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+	overrides, err := store.OverrideEdges(ctx, nodes)
+	if err != nil {
+		t.Fatalf("OverrideEdges failed: %v", err)
+	}
+	if err := store.BulkUpsertEdges(ctx, overrides); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+
+	qualified := make(map[string]string)
+	for _, n := range nodes {
+		qualified[n.ID] = n.QualifiedName
+	}
+	sub, err := store.Subgraph(ctx, graph.SubgraphOptions{
+		Relations: []string{graph.RelationExtends, graph.RelationImplements, graph.RelationOverrides},
+	})
+	if err != nil {
+		t.Fatalf("Subgraph failed: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, e := range sub.Edges {
+		if e.Provenance != graph.ProvenanceHeuristic {
+			t.Errorf("edge %+v is not heuristic", e)
+		}
+		got[qualified[e.SourceID]+" "+e.Relation+" "+qualified[e.TargetID]] = true
+	}
+	// Puppy.eat skips Dog, which does not define eat, and overrides
+	// Animal.eat.
+	for _, want := range []string{
+		"animals.Dog extends animals.Animal",
+		"animals.Puppy extends animals.Dog",
+		"animals.Dog.speak overrides animals.Animal.speak",
+		"animals.Puppy.speak overrides animals.Dog.speak",
+		"animals.Puppy.eat overrides animals.Animal.eat",
+		"shapes.Shape extends shapes.Drawable",
+		"shapes.Square extends shapes.Base",
+		"shapes.Square implements shapes.Shape",
+		"shapes.Square.area overrides shapes.Base.area",
+	} {
+		if !got[want] {
+			t.Errorf("missing edge %q in %v", want, got)
+		}
+	}
+	if got["animals.Puppy.speak overrides animals.Animal.speak"] {
+		t.Error("Puppy.speak overrides Animal.speak, want only the nearest base method")
+	}
+
+	roots, err := store.TypeHierarchy(ctx, "Dog", 0)
+	if err != nil {
+		t.Fatalf("TypeHierarchy failed: %v", err)
+	}
+	if len(roots) != 1 {
+		t.Fatalf("got %d roots for Dog, want 1", len(roots))
+	}
+	dog := roots[0]
+	if len(dog.Supertypes) != 1 || dog.Supertypes[0].Name != "Animal" || dog.Supertypes[0].Relation != graph.RelationExtends {
+		t.Errorf("Dog supertypes = %+v, want Animal", dog.Supertypes)
+	}
+	if len(dog.Subtypes) != 1 || dog.Subtypes[0].Name != "Puppy" {
+		t.Errorf("Dog subtypes = %+v, want Puppy", dog.Subtypes)
+	}
+
+	roots, err = store.TypeHierarchy(ctx, "Drawable", 1)
+	if err != nil {
+		t.Fatalf("TypeHierarchy failed: %v", err)
+	}
+	if len(roots) != 1 || len(roots[0].Subtypes) != 1 || roots[0].Subtypes[0].Name != "Shape" {
+		t.Fatalf("Drawable hierarchy = %+v, want Shape below it", roots)
+	}
+	if shape := roots[0].Subtypes[0]; len(shape.Subtypes) != 0 {
+		t.Errorf("max_depth 1 expanded Shape to %+v", shape.Subtypes)
+	}
+}
+
+func TestIntegration_GoImplementsSurviveReindex(t *testing.T) {
+	// Editing either side of an implementation stores only the edited
+	// definition again. The implements edge is found again when the
+	// implementing type changes, and kept, as an inbound edge, when the
+	// interface does.
+	t.Run("heuristic", func(t *testing.T) {
+		wsDir := t.TempDir()
+		createFile(t, wsDir, "reader.ts", "export interface Reader {\n  read(): string;\n}\n")
+		createFile(t, wsDir, "file.ts", "import { Reader } from \"./reader\";\n\nexport class File implements Reader {\n  read(): string {\n    return \"\";\n  }\n}\n")
+
+		checkImplementsSurvive(t, wsDir, lsp.NewLocalService(), []implementsEdit{
+			{"file.ts", "File", "import { Reader } from \"./reader\";\n\n// File reads from disk.\nexport class File implements Reader {\n  read(): string {\n    return \"data\";\n  }\n}\n"},
+			{"reader.ts", "Reader", "export interface Reader {\n  // read returns the content.\n  read(): string;\n}\n"},
+		})
+	})
+
+	// Without a language server, a Go type implements the interfaces whose
+	// methods it all declares.
+	t.Run("go heuristic", func(t *testing.T) {
+		if isGoplsAvailable() {
+			t.Skip("gopls available; the gopls case covers Go")
+		}
+		wsDir := t.TempDir()
+		createFile(t, wsDir, "go.mod", "module example.com/files\n\ngo 1.21\n")
+		createFile(t, wsDir, "reader.go", "package files\n\ntype Reader interface {\n\tRead() error\n}\n")
+		createFile(t, wsDir, "file.go", "package files\n\ntype File struct{}\n\nfunc (f *File) Read() error { return nil }\n")
+
+		checkImplementsSurvive(t, wsDir, lsp.NewLocalService(), []implementsEdit{
+			{"file.go", "File", "package files\n\ntype File struct {\n\tname string\n}\n\nfunc (f *File) Read() error { return nil }\n"},
+			{"file.go", "File.Read", "package files\n\ntype File struct {\n\tname string\n}\n\nfunc (f *File) Read() error {\n\treturn nil\n}\n"},
+			{"reader.go", "Reader", "package files\n\ntype Reader interface {\n\t// Read reads.\n\tRead() error\n}\n"},
+		})
+	})
+
+	t.Run("gopls", func(t *testing.T) {
+		if !isGoplsAvailable() {
+			t.Skip("gopls not available, skipping")
+		}
+		wsDir := t.TempDir()
+		createFile(t, wsDir, "go.mod", "module example.com/files\n\ngo 1.21\n")
+		createFile(t, wsDir, "reader.go", "package files\n\ntype Reader interface {\n\tRead() error\n}\n")
+		createFile(t, wsDir, "file.go", "package files\n\ntype File struct{}\n\nfunc (f *File) Read() error { return nil }\n")
+		// The language server's workspace root is the working directory.
+		t.Chdir(wsDir)
+		svc := lsp.NewService()
+		defer svc.Shutdown()
+
+		checkImplementsSurvive(t, wsDir, svc, []implementsEdit{
+			{"file.go", "File", "package files\n\ntype File struct {\n\tname string\n}\n\nfunc (f *File) Read() error { return nil }\n"},
+			{"reader.go", "Reader", "package files\n\ntype Reader interface {\n\t// Read reads.\n\tRead() error\n}\n"},
+		})
+	})
+}
+
+// implementsEdit replaces the content of file, changing the definition
+// named, relative to the file's module or package, by changed.
+type implementsEdit struct {
+	file, changed, content string
+}
+
+// checkImplementsSurvive indexes the workspace wsDir, in which File
+// implements Reader, with svc, then makes each edit in turn, indexing the
+// workspace again, and checks that the implements edge is still stored.
+func checkImplementsSurvive(t *testing.T, wsDir string, svc *lsp.Service, edits []implementsEdit) {
+	t.Helper()
+	database, err := db.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	defer scn.Close()
+	coord := indexer.New()
+	go coord.Run(ctx)
+	srv := server.New(scn, store, svc, coord, "")
+
+	index := func() {
+		t.Helper()
+		if _, err := srv.Index(ctx, wsDir, false); err != nil {
+			t.Fatalf("Index failed: %v", err)
+		}
+	}
+	implements := func() bool {
+		t.Helper()
+		reached, err := store.FindDependencies(ctx, "File", graph.TraversalOptions{
+			MaxDepth:  1,
+			Relations: []string{graph.RelationImplements},
+		})
+		if err != nil {
+			t.Fatalf("FindDependencies failed: %v", err)
+		}
+		for _, r := range reached {
+			if r.Node.Name == "Reader" {
+				return true
+			}
+		}
+		return false
+	}
+	// hash returns the stored hash of a definition in the file, which
+	// changes when the definition is stored again.
+	hash := func(file, name string) string {
+		t.Helper()
+		nodes, err := store.GetSymbolsInFile(ctx, filepath.Join(wsDir, file))
+		if err != nil {
+			t.Fatalf("GetSymbolsInFile failed: %v", err)
+		}
+		for _, n := range nodes {
+			if strings.HasSuffix(n.QualifiedName, "."+name) {
+				return n.Hash
+			}
+		}
+		t.Fatalf("no definition of %s in %s", name, file)
+		return ""
+	}
+
+	index()
+	if !implements() {
+		t.Fatal("no implements edge from File to Reader after the initial index")
+	}
+
+	for _, e := range edits {
+		before := hash(e.file, e.changed)
+		createFile(t, wsDir, e.file, e.content)
+		index()
+		if hash(e.file, e.changed) == before {
+			t.Fatalf("editing %s did not change %s", e.file, e.changed)
+		}
+		if !implements() {
+			t.Errorf("implements edge from File to Reader lost after editing %s", e.file)
+		}
+	}
+}
+
+func TestIntegration_SymbolKinds(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "shapes.go", `package shapes
+
+type Shape interface{ Area() float64 }
+
+type Square struct{ side float64 }
+
+type ID string
+
+const MaxSides = 4
+
+func (s Square) Area() float64 { return s.side * s.side }
+
+func NewSquare() Square { return Square{} }
+`)
+	createFile(t, wsDir, "models.py", `class User:
+    @property
+    def name(self):
+        return ""
+
+    def save(self):
+        helper()
+
+
+def helper():
+    pass
+`)
+	createFile(t, wsDir, "app.ts", `enum Color { Red }
+interface Named { name: string }
+type Alias = Named;
+class Widget {
+  render() {}
+}
+const SCALE = 2;
+`)
+	createFile(t, wsDir, "app.js", `const Handler = class {};
+const handle = () => 1;
+const LIMIT = 10;
+let count = 0;
+`)
+	createFile(t, wsDir, "mod.lua", "local M = {}\nfunction M.run() end\nfunction M:stop() end\nreturn M\n")
+	createFile(t, wsDir, "geom.zig", `pub const max_sides: u32 = 8;
+var sides: u32 = 0;
+const Point = struct { x: i32 };
+const Dir = enum { up };
+`)
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	nodes, err := scn.Scan(ctx, wsDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+
+	for qualified, want := range map[string]string{
+		"shapes.Shape":       graph.SymbolInterface,
+		"shapes.Square":      graph.SymbolStruct,
+		"shapes.ID":          graph.SymbolClass,
+		"shapes.Square.Area": graph.SymbolMethod,
+		"shapes.NewSquare":   graph.SymbolFunction,
+		"shapes.MaxSides":    graph.SymbolConstant,
+		"models.User":        graph.SymbolClass,
+		"models.User.name":   graph.SymbolMethod,
+		"models.User.save":   graph.SymbolMethod,
+		"models.helper":      graph.SymbolFunction,
+		"app.Color":          graph.SymbolEnum,
+		"app.Named":          graph.SymbolInterface,
+		"app.Alias":          graph.SymbolClass,
+		"app.Widget.render":  graph.SymbolMethod,
+		"app.Handler":        graph.SymbolClass,
+		"app.handle":         graph.SymbolFunction,
+		"app.LIMIT":          graph.SymbolConstant,
+		"app.count":          graph.SymbolVariable,
+		"app.SCALE":          graph.SymbolConstant,
+		"mod.M.run":          graph.SymbolFunction,
+		"mod.M:stop":         graph.SymbolMethod,
+		"geom.max_sides":     graph.SymbolConstant,
+		"geom.sides":         graph.SymbolVariable,
+		"geom.Point":         graph.SymbolStruct,
+		"geom.Dir":           graph.SymbolEnum,
+		"shapes.go":          graph.SymbolModule,
+	} {
+		locs, err := store.GetSymbolLocation(ctx, qualified)
+		if err != nil {
+			t.Fatalf("GetSymbolLocation(%q) failed: %v", qualified, err)
+		}
+		var got []string
+		for _, n := range locs {
+			got = append(got, n.SymbolKind)
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("symbol kind of %s = %v, want %s", qualified, got, want)
+		}
+	}
+
+	// Go types keep the node kind of the grammar; only the symbol kind
+	// tells them apart.
+	for _, qualified := range []string{"shapes.Shape", "shapes.Square", "shapes.ID"} {
+		locs, err := store.GetSymbolLocation(ctx, qualified)
+		if err != nil {
+			t.Fatalf("GetSymbolLocation(%q) failed: %v", qualified, err)
+		}
+		if len(locs) != 1 || locs[0].Kind != "type_spec" {
+			t.Errorf("kind of %s = %v, want type_spec", qualified, locs)
+		}
+	}
+
+	// Kind filters accept symbol kinds and node kinds alike. No edges are
+	// stored yet, so every symbol is unused.
+	for _, tc := range []struct {
+		kinds []string
+		want  string
+	}{
+		{[]string{graph.SymbolClass}, "Alias,Handler,ID,User,Widget"},
+		{[]string{graph.SymbolInterface, graph.SymbolStruct}, "Named,Point,Shape,Square"},
+		{[]string{graph.SymbolConstant}, "LIMIT,MaxSides,SCALE,max_sides"},
+		{[]string{"class_definition"}, "User"},
+	} {
+		files, err := store.FindUnused(ctx, graph.UnusedOptions{Kinds: tc.kinds, EntryPoints: []string{}, IncludeExported: true})
+		if err != nil {
+			t.Fatalf("FindUnused failed: %v", err)
+		}
+		var got []string
+		for _, f := range files {
+			for _, sym := range f.Symbols {
+				got = append(got, sym.Name)
+			}
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != tc.want {
+			t.Errorf("unused with kinds %v = %v, want %s", tc.kinds, got, tc.want)
+		}
+	}
+	for kind, want := range map[string]int{graph.SymbolStruct: 1, graph.SymbolInterface: 0} {
+		results, err := store.SearchSymbols(ctx, "Square", graph.SearchOptions{Kinds: []string{kind}})
+		if err != nil {
+			t.Fatalf("SearchSymbols failed: %v", err)
+		}
+		if len(results) != want {
+			t.Errorf("search for Square with kind %s = %d results, want %d", kind, len(results), want)
+		}
+	}
+
+	// Traversals pass through nodes of other kinds but only report the
+	// requested ones: helper is reached through the method save.
+	edges, err := store.HeuristicEdges(ctx, nodes)
+	if err != nil {
+		t.Fatalf("HeuristicEdges failed: %v", err)
+	}
+	if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+	reached, err := store.FindImpact(ctx, "models.helper", graph.TraversalOptions{Kinds: []string{graph.SymbolModule}})
+	if err != nil {
+		t.Fatalf("FindImpact failed: %v", err)
+	}
+	if len(reached) != 0 {
+		t.Errorf("FindImpact(helper, module) = %d results, want none", len(reached))
+	}
+	reached, err = store.FindImpact(ctx, "models.helper", graph.TraversalOptions{Kinds: []string{graph.SymbolMethod}})
+	if err != nil {
+		t.Fatalf("FindImpact failed: %v", err)
+	}
+	if len(reached) != 1 || reached[0].Node.QualifiedName != "models.User.save" || len(reached[0].Path) != 2 {
+		t.Errorf("FindImpact(helper, method) = %+v, want save with a two-step path", reached)
+	}
+}
+
+func TestIntegration_Containment(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "square.go", `package shapes
+
+type Shape interface {
+	Area() float64
+}
+
+type Square struct {
+	side float64
+}
+
+func (s *Square) Area() float64 { return s.side * s.side }
+`)
+	createFile(t, wsDir, "perimeter.go", `package shapes
+
+func (s *Square) Perimeter() float64 { return 4 * s.side }
+`)
+	createFile(t, wsDir, "widget.ts", `class Widget {
+  count = 0;
+  render(): void {}
+}
+`)
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	nodes, err := scn.Scan(ctx, wsDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+	edges, err := store.HeuristicEdges(ctx, nodes)
+	if err != nil {
+		t.Fatalf("HeuristicEdges failed: %v", err)
+	}
+	if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+
+	qualified := make(map[string]string)
+	for _, n := range nodes {
+		qualified[n.ID] = n.QualifiedName
+	}
+	sub, err := store.Subgraph(ctx, graph.SubgraphOptions{Relations: []string{graph.RelationContains}})
+	if err != nil {
+		t.Fatalf("Subgraph failed: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, e := range sub.Edges {
+		got[qualified[e.SourceID]+" contains "+qualified[e.TargetID]] = true
+	}
+	for _, want := range []string{
+		"square.go contains shapes.Square",
+		"shapes.Square contains shapes.Square.side",
+		"shapes.Square contains shapes.Square.Area",
+		"shapes.Shape contains shapes.Shape.Area",
+		"perimeter.go contains shapes.Square.Perimeter",
+		"widget.ts contains widget.Widget",
+		"widget.Widget contains widget.Widget.count",
+		"widget.Widget contains widget.Widget.render",
+	} {
+		if !got[want] {
+			t.Errorf("missing edge %q in %v", want, got)
+		}
+	}
+
+	// Members include Go methods declared in another file of the package.
+	squares, err := store.GetSymbolLocation(ctx, "shapes.Square")
+	if err != nil || len(squares) != 1 {
+		t.Fatalf("GetSymbolLocation(shapes.Square) = %v, %v", squares, err)
+	}
+	members, err := store.Members(ctx, squares[0])
+	if err != nil {
+		t.Fatalf("Members failed: %v", err)
+	}
+	var names []string
+	for _, m := range members {
+		names = append(names, m.Name+":"+m.SymbolKind)
+	}
+	if want := "Perimeter:method side:field Area:method"; strings.Join(names, " ") != want {
+		t.Errorf("members of Square = %v, want %s", names, want)
+	}
+
+	// Contains edges are not dependencies.
+	impact, err := store.FindImpact(ctx, "shapes.Square.side", graph.TraversalOptions{})
+	if err != nil {
+		t.Fatalf("FindImpact failed: %v", err)
+	}
+	if len(impact) != 0 {
+		t.Errorf("impact of Square.side = %v, want none", impact)
+	}
+
+	// The outline nests members under their type; a filtered-out parent
+	// leaves its members at the level above.
+	fileNodes, err := store.GetSymbolsInFile(ctx, filepath.Join(wsDir, "square.go"))
+	if err != nil {
+		t.Fatalf("GetSymbolsInFile failed: %v", err)
+	}
+	outline := graph.Outline(fileNodes, nil)
+	if len(outline) != 2 || outline[0].Name != "Shape" || outline[1].Name != "Square" {
+		t.Fatalf("outline roots = %v, want Shape and Square", outline)
+	}
+	if c := outline[1].Children; len(c) != 2 || c[0].Name != "side" || c[1].Name != "Area" {
+		t.Errorf("Square children = %v, want side and Area", c)
+	}
+	methods := graph.Outline(fileNodes, func(n *graph.Node) bool { return n.SymbolKind == graph.SymbolMethod })
+	if len(methods) != 2 || methods[0].QualifiedName != "shapes.Shape.Area" || methods[1].QualifiedName != "shapes.Square.Area" {
+		t.Errorf("method outline = %v, want both Area methods at the top level", methods)
+	}
+}
+
+func TestIntegration_SignaturesAndDocs(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "orders.go", `package orders
+
+// Order is a customer order.
+type Order struct {
+	// Total is the amount due.
+	Total float64
+}
+
+// Process charges the order
+// and ships it.
+func Process(o *Order,
+	retries int,
+) (string, error) {
+	return "", nil
+}
+
+// unrelated
+
+func helper() {}
+`)
+	createFile(t, wsDir, "users.py", `class User:
+    """A registered user.
+
+    Users can log in.
+    """
+
+    async def save(self, force: bool = False) -> None:
+        '''Persist the user.'''
+        pass
+`)
+	createFile(t, wsDir, "widget.ts", `/**
+ * A widget on screen.
+ */
+export class Widget extends Base {
+  render(target: string): void {}
+}
+`)
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	nodes, err := scn.Scan(ctx, wsDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		symbol, signature, doc string
+	}{
+		{"orders.Order", "type Order struct", "Order is a customer order."},
+		{"orders.Order.Total", "Total float64", "Total is the amount due."},
+		{"orders.Process", "func Process(o *Order, retries int) (string, error)", "Process charges the order\nand ships it."},
+		{"orders.helper", "func helper()", ""},
+		{"users.User", "class User", "A registered user.\n\nUsers can log in."},
+		{"users.User.save", "async def save(self, force: bool = False) -> None", "Persist the user."},
+		{"widget.Widget", "class Widget extends Base", "A widget on screen."},
+		{"widget.Widget.render", "render(target: string): void", ""},
+	} {
+		defs, err := store.GetSymbolLocation(ctx, tt.symbol)
+		if err != nil || len(defs) != 1 {
+			t.Fatalf("GetSymbolLocation(%s) = %v, %v", tt.symbol, defs, err)
+		}
+		if defs[0].Signature != tt.signature {
+			t.Errorf("signature of %s = %q, want %q", tt.symbol, defs[0].Signature, tt.signature)
+		}
+		if defs[0].Doc != tt.doc {
+			t.Errorf("doc of %s = %q, want %q", tt.symbol, defs[0].Doc, tt.doc)
+		}
+	}
+
+	// A language server's signature replaces the scanned one.
+	for _, n := range nodes {
+		if n.QualifiedName == "users.User.save" {
+			n.Signature = "def save(self: Self@User, force: bool = False) -> None"
+		}
+	}
+	if err := store.UpdateSignatures(ctx, nodes); err != nil {
+		t.Fatalf("UpdateSignatures failed: %v", err)
+	}
+	defs, err := store.GetSymbolLocation(ctx, "users.User.save")
+	if err != nil || len(defs) != 1 {
+		t.Fatalf("GetSymbolLocation(users.User.save) = %v, %v", defs, err)
+	}
+	if want := "def save(self: Self@User, force: bool = False) -> None"; defs[0].Signature != want {
+		t.Errorf("signature after update = %q, want %q", defs[0].Signature, want)
+	}
+}
+
+func TestIntegration_ReferenceSites(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "util.go", `package app
+
+func helper(n int) int { return n }
+`)
+	createFile(t, wsDir, "main.go", `package app
+
+func run() int {
+	a := helper(1)
+	b := helper(2)
+	return a + b
+}
+
+type Runner struct{}
+
+func (Runner) Go() int { return helper(3) }
+`)
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	index := func() {
+		t.Helper()
+		nodes, err := scn.Scan(ctx, wsDir)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+			t.Fatalf("BulkUpsertNodes failed: %v", err)
+		}
+		edges, err := store.HeuristicEdges(ctx, nodes)
+		if err != nil {
+			t.Fatalf("HeuristicEdges failed: %v", err)
+		}
+		if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+			t.Fatalf("BulkUpsertEdges failed: %v", err)
+		}
+	}
+	index()
+
+	sites := func(refs []*graph.Reference) string {
+		var got []string
+		for _, r := range refs {
+			got = append(got, fmt.Sprintf("%s:%d:%d %s <%s> in %s", filepath.Base(r.FilePath), r.Line, r.Col, r.Relation, r.Snippet, r.From.QualifiedName))
+		}
+		return strings.Join(got, "\n")
+	}
+	refs, err := store.FindReferences(ctx, "helper", graph.ReferenceOptions{})
+	if err != nil {
+		t.Fatalf("FindReferences failed: %v", err)
+	}
+	want := `main.go:4:7 calls <a := helper(1)> in app.run
+main.go:5:7 calls <b := helper(2)> in app.run
+main.go:11:33 calls <func (Runner) Go() int { return helper(3) }> in app.Runner.Go`
+	if got := sites(refs); got != want {
+		t.Errorf("references to helper:\n%s\nwant:\n%s", got, want)
+	}
+	for _, r := range refs {
+		if r.To == nil || r.To.QualifiedName != "app.helper" || r.Provenance != graph.ProvenanceHeuristic {
+			t.Errorf("reference %+v does not point to app.helper through a heuristic edge", r)
+		}
+	}
+
+	refs, err = store.FindReferences(ctx, "helper", graph.ReferenceOptions{Kinds: []string{graph.SymbolMethod}})
+	if err != nil || len(refs) != 1 || refs[0].From.QualifiedName != "app.Runner.Go" {
+		t.Errorf("references from methods = %s, %v, want the one in Runner.Go", sites(refs), err)
+	}
+	refs, err = store.FindReferences(ctx, "helper", graph.ReferenceOptions{Limit: 2})
+	if err != nil || len(refs) != 2 {
+		t.Errorf("references with limit 2 = %s, %v", sites(refs), err)
+	}
+
+	// Both directions report the uses behind the last hop.
+	impact, err := store.FindImpact(ctx, "helper", graph.TraversalOptions{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("FindImpact failed: %v", err)
+	}
+	for _, r := range impact {
+		if r.Node.QualifiedName == "app.run" {
+			if len(r.Sites) != 2 || r.Sites[0].Line != 4 || r.Sites[1].Line != 5 {
+				t.Errorf("sites of run in impact = %+v, want lines 4 and 5", r.Sites)
+			}
+		}
+	}
+	deps, err := store.FindDependencies(ctx, "app.Runner.Go", graph.TraversalOptions{MaxDepth: 1})
+	if err != nil || len(deps) != 1 {
+		t.Fatalf("FindDependencies(Runner.Go) = %v, %v", deps, err)
+	}
+	if s := deps[0].Sites; len(s) != 1 || s[0].Line != 11 || s[0].Col != 33 {
+		t.Errorf("sites of helper in dependencies = %+v, want 11:33", s)
+	}
+
+	// A rescan replaces the uses that moved or went away.
+	createFile(t, wsDir, "main.go", `package app
+
+// run returns one.
+func run() int {
+	return helper(1)
+}
+
+type Runner struct{}
+
+func (Runner) Go() int { return helper(3) }
+`)
+	index()
+	refs, err = store.FindReferences(ctx, "helper", graph.ReferenceOptions{})
+	if err != nil {
+		t.Fatalf("FindReferences failed: %v", err)
+	}
+	want = `main.go:5:9 calls <return helper(1)> in app.run
+main.go:10:33 calls <func (Runner) Go() int { return helper(3) }> in app.Runner.Go`
+	if got := sites(refs); got != want {
+		t.Errorf("references after rescan:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestIntegration_IncrementalFiles(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "util.go", `package app
+
+func helper() {}
+
+func old() {}
+`)
+	createFile(t, wsDir, "main.go", `package app
+
+func run() {
+	helper()
+	old()
+}
+`)
+	createFile(t, wsDir, "notes.txt", "not source")
+	utilPath, mainPath := filepath.Join(wsDir, "util.go"), filepath.Join(wsDir, "main.go")
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	defer scn.Close()
+	paths, err := scn.Files(ctx, wsDir)
+	if err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	if got := strings.Join(paths, ","); got != mainPath+","+utilPath {
+		t.Fatalf("Files = %s, want main.go and util.go", got)
+	}
+
+	coord := indexer.New()
+	go coord.Run(ctx)
+	srv := server.New(scn, store, lsp.NewLocalService(), coord, "")
+
+	res, err := srv.Index(ctx, wsDir, false)
+	if err != nil {
+		t.Fatalf("Index failed: %v", err)
+	}
+	if res.Scanned != 2 || res.Unchanged != 0 {
+		t.Errorf("first index scanned %d files and skipped %d, want 2 and 0", res.Scanned, res.Unchanged)
+	}
+	stored, err := store.Files(ctx)
+	if err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	prev := stored[utilPath]
+	if len(stored) != 2 || prev == nil || prev.Language != "go" || len(prev.Hash) != 64 || prev.Size == 0 || prev.ModTime == 0 {
+		t.Fatalf("stored files = %v, want util.go and main.go with size, mtime and hash", stored)
+	}
+	if impact, err := store.FindImpact(ctx, "app.old", graph.TraversalOptions{}); err != nil || names(impact) != "run@1" {
+		t.Errorf("impact of old = %s, %v, want run@1", names(impact), err)
+	}
+
+	// Without a language server the files are recorded as not enriched, and
+	// the next index asks again although nothing changed.
+	if !isGoplsAvailable() {
+		if res.LSPErr == nil || prev.Enriched {
+			t.Errorf("first index LSP error %v, util.go enriched %v, want an error and false", res.LSPErr, prev.Enriched)
+		}
+		again, err := srv.Index(ctx, wsDir, false)
+		if err != nil {
+			t.Fatalf("Index failed: %v", err)
+		}
+		if again.Scanned != 0 || again.Unchanged != 2 || again.LSPErr == nil {
+			t.Errorf("index of unenriched files scanned %d, skipped %d with LSP error %v, want 0, 2 and an error", again.Scanned, again.Unchanged, again.LSPErr)
+		}
+	}
+
+	// An untouched file is not read again: its stored hash is reused.
+	f, err := scn.Stat(utilPath, &graph.File{Size: prev.Size, ModTime: prev.ModTime, Hash: "stored"})
+	if err != nil || f.Hash != "stored" {
+		t.Errorf("Stat of an untouched file = %+v, %v, want the stored hash", f, err)
+	}
+
+	// Indexing again after an edit scans only the edited file. The call it
+	// removed no longer reaches old; the one it kept still reaches helper.
+	createFile(t, wsDir, "main.go", `package app
+
+func run() {
+	helper()
+}
+`)
+	res, err = srv.Index(ctx, wsDir, false)
+	if err != nil {
+		t.Fatalf("Index failed: %v", err)
+	}
+	if res.Scanned != 1 || res.Unchanged != 1 {
+		t.Errorf("second index scanned %d files and skipped %d, want 1 and 1", res.Scanned, res.Unchanged)
+	}
+	if impact, err := store.FindImpact(ctx, "app.old", graph.TraversalOptions{}); err != nil || len(impact) != 0 {
+		t.Errorf("impact of old after removing its call = %s, %v, want none", names(impact), err)
+	}
+	if impact, err := store.FindImpact(ctx, "app.helper", graph.TraversalOptions{}); err != nil || names(impact) != "run@1" {
+		t.Errorf("impact of helper after the edit = %s, %v, want run@1", names(impact), err)
+	}
+
+	// A forced index finds the edges of unchanged files again. A calls edge
+	// stored weaker than the call resolves now, as before a language server
+	// was installed, is upgraded by it and by nothing else.
+	run, helper := definition(t, store, "app.run"), definition(t, store, "app.helper")
+	if err := store.ClearHeuristicCalls(ctx, []*graph.Node{run}); err != nil {
+		t.Fatalf("ClearHeuristicCalls failed: %v", err)
+	}
+	err = store.BulkUpsertEdges(ctx, []*graph.Edge{{
+		SourceID: run.ID, TargetID: helper.ID, Relation: graph.RelationCalls, Line: 4,
+		Provenance: graph.ProvenanceHeuristic, Confidence: 0.2,
+	}})
+	if err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+	confidence := func() string {
+		t.Helper()
+		refs, err := store.FindReferences(ctx, "app.helper", graph.ReferenceOptions{})
+		if err != nil {
+			t.Fatalf("FindReferences failed: %v", err)
+		}
+		var out []string
+		for _, r := range refs {
+			out = append(out, fmt.Sprintf("%s %.2f", r.From.Name, r.Confidence))
+		}
+		return strings.Join(out, ",")
+	}
+	if res, err = srv.Index(ctx, wsDir, false); err != nil || res.Scanned != 0 {
+		t.Fatalf("Index = %+v, %v, want nothing scanned", res, err)
+	}
+	if got := confidence(); got != "run 0.20" {
+		t.Errorf("calls to helper after an index = %s, want the stored run 0.20", got)
+	}
+	if res, err = srv.Index(ctx, wsDir, true); err != nil || res.Scanned != 2 {
+		t.Fatalf("forced Index = %+v, %v, want both files scanned", res, err)
+	}
+	if got := confidence(); got != "run 0.70" {
+		t.Errorf("calls to helper after a forced index = %s, want run 0.70", got)
+	}
+
+	// A file gone from disk is forgotten with its nodes.
+	if err := os.Remove(utilPath); err != nil {
+		t.Fatalf("Failed to remove util.go: %v", err)
+	}
+	if _, err := srv.Index(ctx, wsDir, false); err != nil {
+		t.Fatalf("Index failed: %v", err)
+	}
+	if stored, err := store.Files(ctx); err != nil || len(stored) != 1 || stored[mainPath] == nil {
+		t.Errorf("stored files after deleting util.go = %v, %v, want main.go", stored, err)
+	}
+	if defs, _ := store.GetSymbolLocation(ctx, "app.helper"); len(defs) != 0 {
+		t.Errorf("definition of a deleted file is still stored: %v", defs)
+	}
+}
+
+func TestIntegration_IndexRefreshesDependents(t *testing.T) {
+	database, err := db.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "animals.py", "class Animal:\n    def speak(self):\n        pass\n")
+	createFile(t, wsDir, "food.py", "def feed():\n    pass\n")
+	createFile(t, wsDir, "zoo.py", `from animals import Animal
+
+
+class Dog(Animal):
+    def speak(self):
+        pass
+
+    def eat(self):
+        pass
+
+
+def run():
+    feed()
+`)
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	defer scn.Close()
+	coord := indexer.New()
+	go coord.Run(ctx)
+	srv := server.New(scn, store, lsp.NewLocalService(), coord, "")
+	index := func() {
+		t.Helper()
+		if _, err := srv.Index(ctx, wsDir, false); err != nil {
+			t.Fatalf("Index failed: %v", err)
+		}
+	}
+	calls := func() string {
+		t.Helper()
+		refs, err := store.FindReferences(ctx, "feed", graph.ReferenceOptions{})
+		if err != nil {
+			t.Fatalf("FindReferences failed: %v", err)
+		}
+		var out []string
+		for _, r := range refs {
+			out = append(out, fmt.Sprintf("%s->%s %.2f", r.From.Name, filepath.Base(r.To.FilePath), r.Confidence))
+		}
+		sort.Strings(out)
+		return strings.Join(out, ",")
+	}
+	overridden := func(method string) string {
+		t.Helper()
+		reached, err := store.FindDependencies(ctx, method, graph.TraversalOptions{
+			MaxDepth:  1,
+			Relations: []string{graph.RelationOverrides},
+		})
+		if err != nil {
+			t.Fatalf("FindDependencies failed: %v", err)
+		}
+		return names(reached)
+	}
+
+	index()
+	if got := calls(); got != "run->food.py 0.70" {
+		t.Fatalf("calls to feed = %s, want run->food.py 0.70", got)
+	}
+	if got := overridden("zoo.Dog.speak"); got != "speak@1" {
+		t.Fatalf("Dog.speak overrides %s, want Animal.speak", got)
+	}
+
+	// Another feed makes the call in zoo.py ambiguous, and a method added
+	// to Animal is overridden by Dog, although zoo.py did not change.
+	createFile(t, wsDir, "more.py", "def feed():\n    pass\n")
+	createFile(t, wsDir, "animals.py", "class Animal:\n    def speak(self):\n        pass\n\n    def eat(self):\n        pass\n")
+	index()
+	if got := calls(); got != "run->food.py 0.35,run->more.py 0.35" {
+		t.Errorf("calls to feed after adding one = %s, want both at 0.35", got)
+	}
+	if got := overridden("zoo.Dog.eat"); got != "eat@1" {
+		t.Errorf("Dog.eat overrides %q after adding Animal.eat, want Animal.eat", got)
+	}
+
+	// Deleting a file leaves the call to the other definition.
+	if err := os.Remove(filepath.Join(wsDir, "food.py")); err != nil {
+		t.Fatalf("Failed to remove food.py: %v", err)
+	}
+	index()
+	if got := calls(); got != "run->more.py 0.70" {
+		t.Errorf("calls to feed after removing food.py = %s, want run->more.py 0.70", got)
+	}
+}
+
+func TestIntegration_IncrementalReparse(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "app.go", `package app
+
+func helper() {}
+
+func other() {}
+
+func run() {
+	helper()
+}
+
+func tail() { other() }
+`)
+	path := filepath.Join(wsDir, "app.go")
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	defer scn.Close()
+	if _, err := scn.Files(ctx, wsDir); err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+
+	// reindex mirrors the watcher: store what changed and find its edges.
+	reindex := func() (string, string) {
+		t.Helper()
+		nodes, edited, err := scn.Reparse(ctx, path)
+		if err != nil {
+			t.Fatalf("Reparse failed: %v", err)
+		}
+		changed, _, err := store.UpdateFileNodes(ctx, path, nodes, edited)
+		if err != nil {
+			t.Fatalf("UpdateFileNodes failed: %v", err)
+		}
+		edges, err := store.HeuristicEdges(ctx, changed)
+		if err != nil {
+			t.Fatalf("HeuristicEdges failed: %v", err)
+		}
+		if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+			t.Fatalf("BulkUpsertEdges failed: %v", err)
+		}
+		var editedNames, changedNames []string
+		for _, n := range nodes {
+			if edited[n.ID] {
+				editedNames = append(editedNames, n.Name)
+			}
+		}
+		for _, n := range changed {
+			changedNames = append(changedNames, n.Name)
+		}
+		return strings.Join(editedNames, ","), strings.Join(changedNames, ",")
+	}
+	refs := func(symbol string) string {
+		t.Helper()
+		found, err := store.FindReferences(ctx, symbol, graph.ReferenceOptions{})
+		if err != nil {
+			t.Fatalf("FindReferences(%s) failed: %v", symbol, err)
+		}
+		var out []string
+		for _, r := range found {
+			out = append(out, fmt.Sprintf("%s@%d", r.From.Name, r.Line))
+		}
+		return strings.Join(out, ",")
+	}
+
+	// Without an earlier tree every node is stored.
+	if _, changed := reindex(); changed != "helper,other,run,tail,app.go" {
+		t.Fatalf("changed nodes of the first parse = %s, want all", changed)
+	}
+	if got := refs("helper") + ";" + refs("other"); got != "run@8;tail@11" {
+		t.Fatalf("references before the edit = %s", got)
+	}
+
+	// An edit within one line changes only the function around it and the
+	// file. The other functions keep their edges.
+	createFile(t, wsDir, "app.go", `package app
+
+func helper() {}
+
+func other() {}
+
+func run() {
+	other()
+}
+
+func tail() { other() }
+`)
+	if edited, changed := reindex(); edited != "run,app.go" || changed != edited {
+		t.Errorf("edited, changed nodes = %s, %s, want run,app.go for both", edited, changed)
+	}
+	if got := refs("helper") + ";" + refs("other"); got != ";run@8,tail@11" {
+		t.Errorf("references after the edit = %s, want none to helper and run@8,tail@11 to other", got)
+	}
+
+	// An added line moves the functions below it, which keep their edges,
+	// moved along.
+	createFile(t, wsDir, "app.go", `package app
+
+func helper() {}
+
+func other() {}
+
+func run() {
+	helper()
+	other()
+}
+
+func tail() { other() }
+`)
+	if _, changed := reindex(); changed != "run,app.go" {
+		t.Errorf("changed nodes after adding a line = %s, want run,app.go", changed)
+	}
+	if got := refs("helper") + ";" + refs("other"); got != "run@8;run@9,tail@12" {
+		t.Errorf("references after adding a line = %s", got)
+	}
+	defs, _ := store.GetSymbolLocation(ctx, "app.tail")
+	if len(defs) != 1 || defs[0].LineStart != 12 {
+		t.Fatalf("moved definition = %v, want tail at line 12", defs)
+	}
+	if sites, err := store.GetCallSites(ctx, defs[0].ID); err != nil || len(sites) != 1 || sites[0].Line != 12 {
+		t.Errorf("call sites of the moved tail = %v, %v, want other() at line 12", sites, err)
+	}
+
+	// Without the kept tree, as after a restart, the nodes' hashes tell what
+	// changed: moving tail down a line changes only the file.
+	scn.Forget(path)
+	createFile(t, wsDir, "app.go", `package app
+
+func helper() {}
+
+func other() {}
+
+func run() {
+	helper()
+	other()
+}
+
+
+func tail() { other() }
+`)
+	if edited, changed := reindex(); edited != "" || changed != "app.go" {
+		t.Errorf("edited, changed nodes without a kept tree = %s, %s, want none and app.go", edited, changed)
+	}
+	if got := refs("other"); got != "run@9,tail@13" {
+		t.Errorf("references to other without a kept tree = %s, want run@9,tail@13", got)
+	}
+
+	// Removing a function deletes its node.
+	createFile(t, wsDir, "app.go", `package app
+
+func helper() {}
+
+func run() {
+	helper()
+}
+`)
+	reindex()
+	if defs, _ := store.GetSymbolLocation(ctx, "app.other"); len(defs) != 0 {
+		t.Errorf("removed definition is still stored: %v", defs)
+	}
+	if got := refs("helper"); got != "run@6" {
+		t.Errorf("references after removing functions = %s, want run@6", got)
+	}
+}
+
+func TestIntegration_DependentEdges(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "main.go", `package app
+
+func run() {
+	helper()
+}
+`)
+	createFile(t, wsDir, "util.go", `package app
+
+func helper() {}
+
+type Order struct{}
+`)
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	defer scn.Close()
+	nodes, err := scn.Scan(ctx, wsDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+	edges, err := store.HeuristicEdges(ctx, nodes)
+	if err != nil {
+		t.Fatalf("HeuristicEdges failed: %v", err)
+	}
+	if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+
+	// reindex mirrors the watcher: the file's changed nodes get their edges
+	// again, and so do the calls elsewhere to names defined or removed in it.
+	reindex := func(name string) []*graph.Node {
+		t.Helper()
+		path := filepath.Join(wsDir, name)
+		scanned, edited, err := scn.Reparse(ctx, path)
+		if err != nil {
+			t.Fatalf("Reparse failed: %v", err)
+		}
+		changed, removed, err := store.UpdateFileNodes(ctx, path, scanned, edited)
+		if err != nil {
+			t.Fatalf("UpdateFileNodes failed: %v", err)
+		}
+		edges, err := store.HeuristicEdges(ctx, changed)
+		if err != nil {
+			t.Fatalf("HeuristicEdges failed: %v", err)
+		}
+		if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+			t.Fatalf("BulkUpsertEdges failed: %v", err)
+		}
+		if _, err := store.RefreshDependents(ctx, changed, removed); err != nil {
+			t.Fatalf("RefreshDependents failed: %v", err)
+		}
+		return changed
+	}
+	refs := func(symbol string) string {
+		t.Helper()
+		found, err := store.FindReferences(ctx, symbol, graph.ReferenceOptions{})
+		if err != nil {
+			t.Fatalf("FindReferences(%s) failed: %v", symbol, err)
+		}
+		var out []string
+		for _, r := range found {
+			out = append(out, fmt.Sprintf("%s@%d->%s %.2f", r.From.Name, r.Line, filepath.Base(r.To.FilePath), r.Confidence))
+		}
+		sort.Strings(out)
+		return strings.Join(out, ",")
+	}
+
+	want := refs("helper")
+	if !strings.HasPrefix(want, "run@4->util.go ") {
+		t.Fatalf("references to helper = %s, want run@4 in util.go", want)
+	}
+	full := strings.TrimPrefix(want, "run@4->util.go ")
+
+	// A second definition in the package makes the call ambiguous, although
+	// the file calling it did not change.
+	createFile(t, wsDir, "extra.go", `package app
+
+func helper() {}
+`)
+	reindex("extra.go")
+	got := refs("helper")
+	if !strings.HasPrefix(got, "run@4->extra.go ") || !strings.Contains(got, ",run@4->util.go ") || strings.Contains(got, full) {
+		t.Errorf("references to helper after adding one = %s, want run@4 to both with less confidence than %s", got, full)
+	}
+
+	// Removing the first definition leaves the call to the second alone.
+	createFile(t, wsDir, "util.go", `package app
+
+type Order struct{}
+`)
+	reindex("util.go")
+	if got := refs("helper"); got != "run@4->extra.go "+full {
+		t.Errorf("references to helper after removing one = %s, want run@4->extra.go %s", got, full)
+	}
+
+	// The definitions an edited function calls are those whose references a
+	// language server is asked for again; the type it only names is not.
+	createFile(t, wsDir, "main.go", `package app
+
+func run() {
+	var o Order
+	_ = o
+	helper()
+}
+`)
+	changed := reindex("main.go")
+	mentioned, err := store.MentionedNodes(ctx, changed)
+	if err != nil {
+		t.Fatalf("MentionedNodes failed: %v", err)
+	}
+	var names []string
+	for _, n := range mentioned {
+		names = append(names, n.Name+" in "+filepath.Base(n.FilePath))
+	}
+	if got := strings.Join(names, ","); got != "helper in extra.go" {
+		t.Errorf("mentioned definitions = %s, want helper", got)
+	}
+	if got := refs("helper"); got != "run@6->extra.go "+full {
+		t.Errorf("references to helper after editing the caller = %s, want run@6", got)
+	}
+}
+
+func TestIntegration_ParallelScanIsDeterministic(t *testing.T) {
+	ws := t.TempDir()
+	writeSyntheticWorkspace(t, ws, 120)
+	ctx := context.Background()
+
+	scan := func(workers int) []string {
+		t.Helper()
+		scn, err := scanner.New()
+		if err != nil {
+			t.Fatalf("Failed to init scanner: %v", err)
+		}
+		defer scn.Close()
+		scn.SetWorkers(workers)
+		nodes, err := scn.Scan(ctx, ws)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		var out []string
+		for _, n := range nodes {
+			out = append(out, fmt.Sprintf("%s %s %d:%d parent=%s calls=%d", n.QualifiedName, n.SymbolKind, n.LineStart, n.LineEnd, n.ParentID, len(n.Calls)))
+		}
+		return out
+	}
+
+	want := scan(1)
+	if len(want) == 0 {
+		t.Fatal("Scan found no nodes")
+	}
+	for _, workers := range []int{4, 16} {
+		// Twice, since worker scheduling differs between runs.
+		for run := 0; run < 2; run++ {
+			if got := scan(workers); strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("Scan with %d workers differs from a sequential scan", workers)
+			}
+		}
+	}
+
+	// ScanFiles returns each file's nodes in the order of the paths given.
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	defer scn.Close()
+	paths, err := scn.Files(ctx, ws)
+	if err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	paths = append(paths[:3:3], filepath.Join(ws, "missing.go"))
+	results := scn.ScanFiles(ctx, paths)
+	if len(results) != len(paths) || results[3] != nil {
+		t.Fatalf("ScanFiles returned %d results for %d paths, want one per path and nil for the missing file", len(results), len(paths))
+	}
+	for i, nodes := range results[:3] {
+		if len(nodes) == 0 || nodes[len(nodes)-1].FilePath != paths[i] {
+			t.Errorf("result %d does not hold the nodes of %s", i, paths[i])
+		}
+	}
+}
+
+func TestIntegration_DeleteCascadesEdges(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	callerPath := filepath.Join(wsDir, "caller.go")
+	calleePath := filepath.Join(wsDir, "callee.go")
+	createFile(t, wsDir, "caller.go", "package main\n")
+	createFile(t, wsDir, "callee.go", "package main\n")
+
+	nodes := []*graph.Node{
+		{ID: "caller", Name: "Caller", QualifiedName: "main.Caller", Kind: "function_declaration", FilePath: callerPath, LineStart: 1, LineEnd: 3},
+		{ID: "callee", Name: "Callee", QualifiedName: "main.Callee", Kind: "function_declaration", FilePath: calleePath, LineStart: 1, LineEnd: 3},
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+	if err := store.UpsertEdge(ctx, &graph.Edge{SourceID: "caller", TargetID: "callee", Relation: graph.RelationReferences}); err != nil {
+		t.Fatalf("UpsertEdge failed: %v", err)
+	}
+
+	if err := store.DeleteNodesByFile(ctx, calleePath); err != nil {
+		t.Fatalf("DeleteNodesByFile failed: %v", err)
+	}
+	if err := os.Remove(callerPath); err != nil {
+		t.Fatal(err)
+	}
+
+	impacted, err := store.FindImpact(ctx, "Callee", graph.TraversalOptions{})
+	if err != nil {
+		t.Fatalf("FindImpact failed: %v", err)
+	}
+	if len(impacted) != 0 {
+		t.Errorf("Expected no dependents after deleting Callee, got %d", len(impacted))
+	}
+
+	report, err := store.CheckIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+	if len(report.OrphanedEdges) != 0 {
+		t.Errorf("Expected no orphaned edges, got %d", len(report.OrphanedEdges))
+	}
+	if len(report.MissingFiles) != 1 || report.MissingFiles[0] != callerPath {
+		t.Errorf("Expected missing file %s, got %v", callerPath, report.MissingFiles)
+	}
+}
+
+func TestIntegration_FindImpactBounds(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+	seedLayeredGraph(t, store)
+
+	all, err := store.FindImpact(ctx, "Repo", graph.TraversalOptions{})
+	if err != nil {
+		t.Fatalf("FindImpact failed: %v", err)
+	}
+	if got := names(all); got != "handler@2,service@1,sqlRepo@1" {
+		t.Errorf("Unbounded impact = %s", got)
+	}
+	for _, r := range all {
+		if r.Node.Name != "handler" {
+			continue
+		}
+		var chain []string
+		for _, step := range r.Path {
+			chain = append(chain, step.Name+":"+step.Relation)
+		}
+		if got := strings.Join(chain, " "); got != "Repo: service:references handler:calls" {
+			t.Errorf("Path to handler = %s", got)
+		}
+	}
+
+	shallow, err := store.FindImpact(ctx, "Repo", graph.TraversalOptions{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("FindImpact failed: %v", err)
+	}
+	if got := names(shallow); got != "service@1,sqlRepo@1" {
+		t.Errorf("Depth-1 impact = %s", got)
+	}
+
+	refsOnly, err := store.FindImpact(ctx, "Repo", graph.TraversalOptions{Relations: []string{graph.RelationReferences}})
+	if err != nil {
+		t.Fatalf("FindImpact failed: %v", err)
+	}
+	if got := names(refsOnly); got != "service@1" {
+		t.Errorf("References-only impact = %s", got)
+	}
+
+	limited, err := store.FindImpact(ctx, "Repo", graph.TraversalOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("FindImpact failed: %v", err)
+	}
+	if len(limited) != 1 || limited[0].Distance != 1 {
+		t.Errorf("Expected a single nearest result, got %s", names(limited))
+	}
+}
+
+func TestIntegration_FindDependencies(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+	seedLayeredGraph(t, store)
+
+	deps, err := store.FindDependencies(ctx, "handler", graph.TraversalOptions{})
+	if err != nil {
+		t.Fatalf("FindDependencies failed: %v", err)
+	}
+	if got := names(deps); got != "Repo@2,service@1" {
+		t.Errorf("Dependencies of handler = %s", got)
+	}
+
+	callsOnly, err := store.FindDependencies(ctx, "app.handler", graph.TraversalOptions{Relations: []string{graph.RelationCalls}})
+	if err != nil {
+		t.Fatalf("FindDependencies failed: %v", err)
+	}
+	if got := names(callsOnly); got != "service@1" {
+		t.Errorf("Call dependencies of handler = %s", got)
+	}
+}
+
+func TestIntegration_FindPaths(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+	seedLayeredGraph(t, store)
+
+	// Add a longer detour: handler --calls--> audit --calls--> service
+	if err := store.UpsertNode(ctx, &graph.Node{ID: "audit", Name: "audit", QualifiedName: "app.audit", Kind: "function_declaration", FilePath: "/ws/app.go", LineStart: 50, LineEnd: 55}); err != nil {
+		t.Fatalf("UpsertNode failed: %v", err)
+	}
+	if err := store.BulkUpsertEdges(ctx, []*graph.Edge{
+		{SourceID: "handler", TargetID: "audit", Relation: graph.RelationCalls},
+		{SourceID: "audit", TargetID: "service", Relation: graph.RelationCalls},
+	}); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+
+	render := func(p []graph.PathStep) string {
+		var parts []string
+		for _, step := range p {
+			parts = append(parts, fmt.Sprintf("%s:%d", step.Name, step.Line))
+		}
+		return strings.Join(parts, " -> ")
+	}
+
+	shortest, err := store.FindPaths(ctx, "handler", "Repo", graph.PathOptions{})
+	if err != nil {
+		t.Fatalf("FindPaths failed: %v", err)
+	}
+	if len(shortest) != 1 || render(shortest[0]) != "handler:1 -> service:11 -> Repo:21" {
+		t.Errorf("Unexpected shortest path: %v", shortest)
+	}
+
+	all, err := store.FindPaths(ctx, "handler", "Repo", graph.PathOptions{MaxPaths: 5})
+	if err != nil {
+		t.Fatalf("FindPaths failed: %v", err)
+	}
+	if len(all) != 2 || render(all[1]) != "handler:1 -> audit:50 -> service:11 -> Repo:21" {
+		t.Errorf("Expected two paths, detour last, got %d", len(all))
+	}
+
+	bounded, err := store.FindPaths(ctx, "handler", "Repo", graph.PathOptions{MaxPaths: 5, MaxLength: 2})
+	if err != nil {
+		t.Fatalf("FindPaths failed: %v", err)
+	}
+	if len(bounded) != 1 {
+		t.Errorf("Expected max_length 2 to drop the detour, got %d paths", len(bounded))
+	}
+
+	callsOnly, err := store.FindPaths(ctx, "handler", "Repo", graph.PathOptions{Relations: []string{graph.RelationCalls}})
+	if err != nil {
+		t.Fatalf("FindPaths failed: %v", err)
+	}
+	if len(callsOnly) != 0 {
+		t.Errorf("Expected no calls-only path to Repo, got %d", len(callsOnly))
+	}
+}
+
+func TestIntegration_FindCycles(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	// api/a <-> svc/b form a symbol cycle that also links the api and svc
+	// packages; svc/c -> svc/d -> svc/e -> svc/c is a larger cycle inside svc.
+	files := map[string]string{"a": "/ws/api/a.py", "b": "/ws/svc/b.py", "c": "/ws/svc/c.py", "d": "/ws/svc/d.py", "e": "/ws/svc/e.py", "f": "/ws/lib/f.py"}
+	for id, path := range files {
+		if err := store.UpsertNode(ctx, &graph.Node{ID: id, Name: id, QualifiedName: id, Kind: "function_definition", FilePath: path, LineStart: 1, LineEnd: 2}); err != nil {
+			t.Fatalf("UpsertNode failed: %v", err)
+		}
+	}
+	if err := store.BulkUpsertEdges(ctx, []*graph.Edge{
+		{SourceID: "a", TargetID: "b", Relation: graph.RelationCalls},
+		{SourceID: "b", TargetID: "a", Relation: graph.RelationReferences},
+		{SourceID: "c", TargetID: "d", Relation: graph.RelationCalls},
+		{SourceID: "d", TargetID: "e", Relation: graph.RelationCalls},
+		{SourceID: "e", TargetID: "c", Relation: graph.RelationCalls},
+		{SourceID: "e", TargetID: "f", Relation: graph.RelationCalls},
+	}); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+
+	symbolCycles, err := store.FindSymbolCycles(ctx, graph.CycleOptions{})
+	if err != nil {
+		t.Fatalf("FindSymbolCycles failed: %v", err)
+	}
+	if len(symbolCycles) != 2 || symbolCycles[0].Size != 3 || symbolCycles[1].Size != 2 {
+		t.Fatalf("Expected cycles of size 3 and 2, got %+v", symbolCycles)
+	}
+	if len(symbolCycles[0].ClosingEdges) != 1 || symbolCycles[0].ClosingEdges[0].Source != "e" || symbolCycles[0].ClosingEdges[0].Target != "c" {
+		t.Errorf("Expected e -> c to close the c/d/e loop, got %+v", symbolCycles[0].ClosingEdges)
+	}
+
+	callsOnly, err := store.FindSymbolCycles(ctx, graph.CycleOptions{Relations: []string{graph.RelationCalls}})
+	if err != nil {
+		t.Fatalf("FindSymbolCycles failed: %v", err)
+	}
+	if len(callsOnly) != 1 {
+		t.Errorf("Expected only the c/d/e cycle over calls, got %d", len(callsOnly))
+	}
+
+	packageCycles, err := store.FindPackageCycles(ctx, graph.CycleOptions{})
+	if err != nil {
+		t.Fatalf("FindPackageCycles failed: %v", err)
+	}
+	if len(packageCycles) != 1 || strings.Join(packageCycles[0].Packages, ",") != "/ws/api,/ws/svc" {
+		t.Fatalf("Expected one api/svc package cycle, got %+v", packageCycles)
+	}
+	if len(packageCycles[0].ClosingEdges) != 1 || packageCycles[0].ClosingEdges[0].Via == "" {
+		t.Errorf("Expected a closing edge with a symbol-level example, got %+v", packageCycles[0].ClosingEdges)
+	}
+}
+
+func TestIntegration_FindUnused(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	seedLayeredGraph(t, store)
+
+	dir := t.TempDir()
+	createFile(t, dir, "util.ts", "export function formatDate() {}\nfunction pad() {}\n")
+	tsPath := filepath.Join(dir, "util.ts")
+
+	extra := []*graph.Node{
+		{ID: "main", Name: "main", QualifiedName: "main.main", FilePath: "/ws/cmd/main.go", LineStart: 3, LineEnd: 5},
+		{ID: "Exported", Name: "Exported", QualifiedName: "app.Exported", FilePath: "/ws/app.go", LineStart: 50, LineEnd: 52},
+		{ID: "legacyHelper", Name: "legacyHelper", QualifiedName: "app.legacyHelper", FilePath: "/ws/app.go", LineStart: 60, LineEnd: 62},
+		{ID: "recurse", Name: "recurse", QualifiedName: "app.recurse", FilePath: "/ws/app.go", LineStart: 70, LineEnd: 75},
+		{ID: "TestHandler", Name: "TestHandler", QualifiedName: "app.TestHandler", FilePath: "/ws/app_test.go", LineStart: 1, LineEnd: 4},
+		{ID: "formatDate", Name: "formatDate", QualifiedName: "util.formatDate", FilePath: tsPath, LineStart: 1, LineEnd: 1},
+		{ID: "pad", Name: "pad", QualifiedName: "util.pad", FilePath: tsPath, LineStart: 2, LineEnd: 2},
+	}
+	for _, n := range extra {
+		n.Kind = "function_declaration"
+	}
+	if err := store.BulkUpsertNodes(ctx, extra); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+	// A recursive call does not make a symbol used.
+	if err := store.BulkUpsertEdges(ctx, []*graph.Edge{{SourceID: "recurse", TargetID: "recurse", Relation: graph.RelationCalls}}); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+
+	// Files are reported in path order; the temp dir sorts before /ws.
+	// sqlRepo implements Repo, which service uses, so it is used too.
+	unused := func(opts graph.UnusedOptions) string {
+		t.Helper()
+		files, err := store.FindUnused(ctx, opts)
+		if err != nil {
+			t.Fatalf("FindUnused failed: %v", err)
+		}
+		var got []string
+		for _, f := range files {
+			for _, sym := range f.Symbols {
+				got = append(got, fmt.Sprintf("%s:%s@%s", filepath.Base(f.FilePath), sym.Name, sym.Lines))
+			}
+		}
+		return strings.Join(got, ",")
+	}
+
+	if got, want := unused(graph.UnusedOptions{ExcludePatterns: []string{"legacy*"}}), "util.ts:pad@2-2,app.go:handler@1-5,app.go:recurse@70-75"; got != want {
+		t.Errorf("Default report:\n got  %s\n want %s", got, want)
+	}
+	if got, want := unused(graph.UnusedOptions{IncludeExported: true, IncludeTests: true, EntryPoints: []string{}}), "util.ts:formatDate@1-1,util.ts:pad@2-2,app.go:handler@1-5,app.go:Exported@50-52,app.go:legacyHelper@60-62,app.go:recurse@70-75,app_test.go:TestHandler@1-4,main.go:main@3-5"; got != want {
+		t.Errorf("Full report:\n got  %s\n want %s", got, want)
+	}
+	if got := unused(graph.UnusedOptions{Kinds: []string{"class_declaration"}}); got != "" {
+		t.Errorf("Expected no unused classes, got %s", got)
+	}
+	if _, err := store.FindUnused(ctx, graph.UnusedOptions{ExcludePatterns: []string{"["}}); err == nil {
+		t.Error("Expected an error for a malformed pattern")
+	}
+}
+
+func TestIntegration_FindUnusedKeepsOverrides(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	wsDir := t.TempDir()
+	createFile(t, wsDir, "animals.py", `class Animal:
+    def speak(self):
+        pass
+
+class Dog(Animal):
+    def speak(self):
+        return "woof"
+
+class Shape:
+    def area(self):
+        pass
+
+class Square(Shape):
+    def area(self):
+        return 1
+
+def talk(animal: Animal):
+    return animal
+`)
+
+	scn, err := scanner.New()
+	if err != nil {
+		t.Fatalf("Failed to init scanner: %v", err)
+	}
+	defer scn.Close()
+	nodes, err := scn.Scan(ctx, wsDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+	edges, err := store.HeuristicEdges(ctx, nodes)
+	if err != nil {
+		t.Fatalf("HeuristicEdges failed: %v", err)
+	}
+	if err := store.BulkUpsertEdges(ctx, edges); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+	overrides, err := store.OverrideEdges(ctx, nodes)
+	if err != nil {
+		t.Fatalf("OverrideEdges failed: %v", err)
+	}
+	// A language server resolves a call through the base type, such as
+	// animal.speak(), to the base method only.
+	ids := make(map[string]string)
+	for _, n := range nodes {
+		ids[n.QualifiedName] = n.ID
+	}
+	call := &graph.Edge{
+		SourceID: ids["animals.talk"], TargetID: ids["animals.Animal.speak"],
+		Relation: graph.RelationCalls, Line: 18, Provenance: graph.ProvenanceLSP, Confidence: 1,
+	}
+	if err := store.BulkUpsertEdges(ctx, append(overrides, call)); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+
+	// Dog.speak runs wherever Animal.speak is called; nothing calls
+	// Shape.area, so Square.area is as unused as talk. Shape.area and the
+	// base classes are targets of overrides and extends edges.
+	files, err := store.FindUnused(ctx, graph.UnusedOptions{IncludeExported: true, Kinds: []string{graph.SymbolFunction, graph.SymbolMethod}})
+	if err != nil {
+		t.Fatalf("FindUnused failed: %v", err)
+	}
+	var got []string
+	for _, f := range files {
+		for _, sym := range f.Symbols {
+			got = append(got, sym.QualifiedName)
+		}
+	}
+	if strings.Join(got, ",") != "animals.Square.area,animals.talk" {
+		t.Errorf("unused = %v, want animals.Square.area and animals.talk", got)
+	}
+}
+
+func TestIntegration_SearchSymbols(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	var nodes []*graph.Node
+	for i, sym := range []struct{ name, qualified, kind, path string }{
+		{"HTTPRequestHandler", "api.HTTPRequestHandler", "type_spec", "/ws/api/handler.go"},
+		{"handleRequest", "api.Server.handleRequest", "method_declaration", "/ws/api/server.go"},
+		{"parse_config_file", "config.parse_config_file", "function_definition", "/ws/config.py"},
+		{"Request", "api.Request", "type_spec", "/ws/api/types.go"},
+		{"processOrder", "orders.processOrder", "function_declaration", "/ws/orders/orders.ts"},
+	} {
+		nodes = append(nodes, &graph.Node{
+			ID: sym.qualified, Name: sym.name, QualifiedName: sym.qualified, Kind: sym.kind,
+			FilePath: sym.path, LineStart: i + 1, LineEnd: i + 1,
+		})
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+
+	search := func(query string, opts graph.SearchOptions) []string {
+		t.Helper()
+		results, err := store.SearchSymbols(ctx, query, opts)
+		if err != nil {
+			t.Fatalf("SearchSymbols(%q) failed: %v", query, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Node.Name+":"+r.Match)
+		}
+		return got
+	}
+
+	tests := []struct {
+		query string
+		opts  graph.SearchOptions
+		want  string
+	}{
+		{"request", graph.SearchOptions{}, "Request:exact,handleRequest:words,HTTPRequestHandler:words"},
+		{"PROCESS", graph.SearchOptions{}, "processOrder:prefix"},
+		{"req hand", graph.SearchOptions{}, "HTTPRequestHandler:words,handleRequest:fuzzy"},
+		{"config_file", graph.SearchOptions{}, "parse_config_file:words"},
+		{"uestHand", graph.SearchOptions{}, "HTTPRequestHandler:substring"},
+		{"proccessOrdr", graph.SearchOptions{}, "processOrder:fuzzy"},
+		{"server handle", graph.SearchOptions{}, "handleRequest:words"},
+		{"request", graph.SearchOptions{Kinds: []string{"method_declaration"}}, "handleRequest:words"},
+		{"request", graph.SearchOptions{PathGlob: "*/api/types.go"}, "Request:exact"},
+		{"p", graph.SearchOptions{Language: "python"}, "parse_config_file:prefix"},
+		{"request", graph.SearchOptions{Limit: 1}, "Request:exact"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(search(tt.query, tt.opts), ","); got != tt.want {
+			t.Errorf("SearchSymbols(%q, %+v) = %s, want %s", tt.query, tt.opts, got, tt.want)
+		}
+	}
+
+	// The index follows deletes and renames.
+	if err := store.DeleteNodesByFile(ctx, "/ws/orders/orders.ts"); err != nil {
+		t.Fatalf("DeleteNodesByFile failed: %v", err)
+	}
+	if got := search("processOrder", graph.SearchOptions{}); len(got) != 0 {
+		t.Errorf("Expected deleted symbol to be gone from search, got %v", got)
+	}
+	renamed := *nodes[3]
+	renamed.Name = "Envelope"
+	if err := store.UpsertNode(ctx, &renamed); err != nil {
+		t.Fatalf("UpsertNode failed: %v", err)
+	}
+	if got := strings.Join(search("envelope", graph.SearchOptions{}), ","); got != "Envelope:exact" {
+		t.Errorf("Expected renamed symbol to be found, got %s", got)
+	}
+
+	if _, err := store.SearchSymbols(ctx, "  ", graph.SearchOptions{}); err == nil {
+		t.Error("Expected an error for an empty query")
+	}
+	if _, err := store.SearchSymbols(ctx, "x", graph.SearchOptions{Language: "cobol"}); err == nil {
+		t.Error("Expected an error for an unknown language")
+	}
+}
+
+func TestIntegration_ExportGraph(t *testing.T) {
+	tmpDbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.New(tmpDbPath)
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	defer database.Close()
+	store := graph.NewStore(database)
+	ctx := context.Background()
+
+	seedLayeredGraph(t, store)
+	if err := store.UpsertNode(ctx, &graph.Node{ID: "util", Name: "util", QualifiedName: "lib.util", Kind: "function_declaration", FilePath: "/ws/lib/util.go", LineStart: 1, LineEnd: 2}); err != nil {
+		t.Fatalf("UpsertNode failed: %v", err)
+	}
+
+	whole, err := store.Subgraph(ctx, graph.SubgraphOptions{})
+	if err != nil {
+		t.Fatalf("Subgraph failed: %v", err)
+	}
+	if len(whole.Nodes) != 5 || len(whole.Edges) != 3 {
+		t.Errorf("Expected the whole graph (5 nodes, 3 edges), got %d nodes and %d edges", len(whole.Nodes), len(whole.Edges))
+	}
+
+	// One hop around service reaches handler and Repo but not sqlRepo.
+	around, err := store.Subgraph(ctx, graph.SubgraphOptions{Symbol: "service"})
+	if err != nil {
+		t.Fatalf("Subgraph failed: %v", err)
+	}
+	var got []string
+	for _, n := range around.Nodes {
+		got = append(got, n.Name)
+	}
+	if strings.Join(got, ",") != "handler,service,Repo" || len(around.Edges) != 2 {
+		t.Errorf("Expected handler,service,Repo with 2 edges, got %v with %d edges", got, len(around.Edges))
+	}
+
+	inDir, err := store.Subgraph(ctx, graph.SubgraphOptions{Dir: "/ws/lib"})
+	if err != nil {
+		t.Fatalf("Subgraph failed: %v", err)
+	}
+	if len(inDir.Nodes) != 1 || inDir.Nodes[0].Name != "util" {
+		t.Errorf("Expected only util under /ws/lib, got %+v", inDir.Nodes)
+	}
+
+	wants := map[string][]string{
+		export.FormatDOT:     {"digraph codemap {", "subgraph cluster_0 {", `label="/ws"`, `"handler" -> "service" [label="calls"];`},
+		export.FormatGraphML: {"<graphml", `<key id="relation" for="edge"`, `<data key="label">/ws/lib</data>`, `<edge source="handler" target="service">`},
+		export.FormatMermaid: {"flowchart LR", `subgraph c0["/ws"]`, `n0 -->|"calls"| n1`},
+		export.FormatJSON:    {`"nodes": [`, `"relation": "implements"`, `"label": "/ws/lib"`},
+	}
+	for format, fragments := range wants {
+		var sb strings.Builder
+		if err := export.Write(&sb, whole, export.Options{Format: format, Cluster: export.ClusterPackage}); err != nil {
+			t.Fatalf("Write(%s) failed: %v", format, err)
+		}
+		for _, f := range fragments {
+			if !strings.Contains(sb.String(), f) {
+				t.Errorf("%s output is missing %q:\n%s", format, f, sb.String())
+			}
+		}
+	}
+
+	if err := export.Write(&strings.Builder{}, whole, export.Options{Format: "svg"}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+// seedLayeredGraph stores:
+// handler --calls--> service --references--> Repo <--implements-- sqlRepo
+func seedLayeredGraph(t *testing.T, store *graph.Store) {
+	ctx := context.Background()
+	var nodes []*graph.Node
+	for i, name := range []string{"handler", "service", "Repo", "sqlRepo"} {
+		nodes = append(nodes, &graph.Node{
+			ID: name, Name: name, QualifiedName: "app." + name, Kind: "function_declaration",
+			FilePath: "/ws/app.go", LineStart: i*10 + 1, LineEnd: i*10 + 5,
+		})
+	}
+	if err := store.BulkUpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("BulkUpsertNodes failed: %v", err)
+	}
+	if err := store.BulkUpsertEdges(ctx, []*graph.Edge{
+		{SourceID: "handler", TargetID: "service", Relation: graph.RelationCalls},
+		{SourceID: "service", TargetID: "Repo", Relation: graph.RelationReferences},
+		{SourceID: "sqlRepo", TargetID: "Repo", Relation: graph.RelationImplements},
+	}); err != nil {
+		t.Fatalf("BulkUpsertEdges failed: %v", err)
+	}
+}
+
+// names renders traversal results as sorted "name@distance" pairs.
+func names(reached []*graph.Reached) string {
+	var out []string
+	for _, r := range reached {
+		out = append(out, fmt.Sprintf("%s@%d", r.Node.Name, r.Distance))
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
+
+// definition returns the stored definition with the given qualified name.
+func definition(t *testing.T, store *graph.Store, qualified string) *graph.Node {
+	t.Helper()
+	defs, err := store.GetSymbolLocation(context.Background(), qualified)
+	if err != nil || len(defs) != 1 {
+		t.Fatalf("GetSymbolLocation(%s) = %v, %v, want one definition", qualified, defs, err)
+	}
+	return defs[0]
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codemap/internal/scanner"
+)
+
+// writeSyntheticWorkspace fills dir with Go, Python and TypeScript files,
+// spread over packages of 50, each with a few functions, a type with
+// methods and calls between them.
+func writeSyntheticWorkspace(tb testing.TB, dir string, files int) {
+	tb.Helper()
+	for i := 0; i < files; i++ {
+		pkg := filepath.Join(dir, fmt.Sprintf("pkg%03d", i/50))
+		if err := os.MkdirAll(pkg, 0755); err != nil {
+			tb.Fatal(err)
+		}
+		var name, content string
+		switch i % 3 {
+		case 0:
+			name = fmt.Sprintf("file%d.go", i)
+			content = fmt.Sprintf(`package pkg
+
+// Service%[1]d does work.
+type Service%[1]d struct {
+	name  string
+	count int
+}
+
+func NewService%[1]d(name string) *Service%[1]d {
+	return &Service%[1]d{name: name}
+}
+
+func (s *Service%[1]d) Run(n int) int {
+	for i := 0; i < n; i++ {
+		s.count += helper%[1]d(i)
+	}
+	return s.count
+}
+
+func helper%[1]d(i int) int { return i * 2 }
+`, i)
+		case 1:
+			name = fmt.Sprintf("file%d.py", i)
+			content = fmt.Sprintf(`import os
+
+
+class Service%[1]d:
+    """Does work."""
+
+    limit = 10
+
+    def __init__(self, name):
+        self.name = name
+
+    def run(self, n):
+        return sum(helper%[1]d(i) for i in range(n))
+
+
+def helper%[1]d(i):
+    return os.path.join(str(i), "x")
+`, i)
+		default:
+			name = fmt.Sprintf("file%d.ts", i)
+			content = fmt.Sprintf(`export interface Runner%[1]d {
+  run(n: number): number;
+}
+
+export class Service%[1]d implements Runner%[1]d {
+  private count = 0;
+
+  run(n: number): number {
+    for (let i = 0; i < n; i++) {
+      this.count += helper%[1]d(i);
+    }
+    return this.count;
+  }
+}
+
+export const helper%[1]d = (i: number): number => i * 2;
+`, i)
+		}
+		if err := os.WriteFile(filepath.Join(pkg, name), []byte(content), 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkScan scans a synthetic workspace of 3000 files with a single
+// worker and with one per CPU.
+func BenchmarkScan(b *testing.B) {
+	ws := b.TempDir()
+	writeSyntheticWorkspace(b, ws, 3000)
+	ctx := context.Background()
+
+	for _, workers := range []int{1, 0} {
+		name := fmt.Sprintf("workers=%d", workers)
+		if workers == 0 {
+			name = "workers=cpus"
+		}
+		b.Run(name, func(b *testing.B) {
+			scn, err := scanner.New()
+			if err != nil {
+				b.Fatalf("Failed to init scanner: %v", err)
+			}
+			defer scn.Close()
+			scn.SetWorkers(workers)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				nodes, err := scn.Scan(ctx, ws)
+				if err != nil {
+					b.Fatalf("Scan failed: %v", err)
+				}
+				if len(nodes) == 0 {
+					b.Fatal("Scan found no nodes")
+				}
+			}
+		})
+	}
+}
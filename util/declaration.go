@@ -0,0 +1,14 @@
+package util
+
+import "strings"
+
+var (
+	innerSpace    = strings.NewReplacer("( ", "(", "[ ", "[", " )", ")", " ]", "]")
+	trailingComma = strings.NewReplacer(",)", ")", ",]", "]")
+)
+
+// OneLine puts a declaration spanning several lines on one line, dropping
+// the spaces and trailing commas left inside brackets.
+func OneLine(s string) string {
+	return trailingComma.Replace(innerSpace.Replace(strings.Join(strings.Fields(s), " ")))
+}
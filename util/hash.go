@@ -6,9 +6,10 @@ import (
 	"fmt"
 )
 
-// GenerateNodeID creates a deterministic hash for a node based on file path and symbol name.
-func GenerateNodeID(filePath, symbolName string) string {
-	input := fmt.Sprintf("%s:%s", filePath, symbolName)
+// GenerateNodeID creates a deterministic hash for a node based on file path and
+// qualified symbol name.
+func GenerateNodeID(filePath, qualifiedName string) string {
+	input := fmt.Sprintf("%s:%s", filePath, qualifiedName)
 	hash := sha256.Sum256([]byte(input))
 	return hex.EncodeToString(hash[:])
 }
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SplitIdentifier breaks an identifier into lowercase words at separators and
+// case changes: "HTTPRequestHandler" gives http, request, handler, and
+// "parse_json_v2" gives parse, json, v2. Digits stay with the preceding word.
+func SplitIdentifier(s string) []string {
+	var words []string
+	runes := []rune(s)
+	start := -1
+	flush := func(end int) {
+		if start >= 0 && end > start {
+			words = append(words, strings.ToLower(string(runes[start:end])))
+		}
+		start = -1
+	}
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := runes[i-1]
+		switch {
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			// fooBar, utf8Decode
+			flush(i)
+			start = i
+		case unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			// HTTPServer: the last capital starts the next word.
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(runes))
+	return words
+}
+
+// SearchTerms returns the space-separated words of a symbol's qualified name
+// (or its name, if it has none), as stored in the symbol search index.
+func SearchTerms(name, qualifiedName string) string {
+	if qualifiedName == "" {
+		qualifiedName = name
+	}
+	return strings.Join(SplitIdentifier(qualifiedName), " ")
+}